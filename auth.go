@@ -0,0 +1,185 @@
+package gointegration
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/btm6084/gojson"
+)
+
+// SecurityScheme represents an API authentication scheme, as declared in the securityDefinitions of a
+// Swagger 2.0 document or the components.securitySchemes of an OpenAPI 3.x document.
+type SecurityScheme struct {
+	// Type is one of "basic", "bearer", or "apiKey". OAuth2 and OpenID Connect schemes are treated as "bearer".
+	Type string `json:"type"`
+
+	// In and Name describe where an apiKey is sent: the header, query, or cookie with the given name.
+	In   string `json:"in"`
+	Name string `json:"name"`
+}
+
+// Credential holds the secret used to satisfy a SecurityScheme. Basic schemes use Username and Password,
+// while bearer and apiKey schemes use Token.
+type Credential struct {
+	Username string
+	Password string
+	Token    string
+}
+
+// WithAuth sets the credential used for the security scheme with the given name. Exec will automatically
+// add the credential to the request of any route that declares the scheme as a security requirement.
+func (sc *Client) WithAuth(scheme string, cred Credential) *Client {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.Credentials == nil {
+		sc.Credentials = make(map[string]Credential)
+	}
+	sc.Credentials[scheme] = cred
+
+	return sc
+}
+
+// SetBasicAuth sets the Authorization header of every request to use Basic Authentication with the given
+// username and password, regardless of the security requirements declared by the route.
+func (sc *Client) SetBasicAuth(username, password string) *Client {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.Authorization = "Basic " + basicAuth(username, password)
+
+	return sc
+}
+
+// SetBearerToken sets the Authorization header of every request to the given Bearer token, regardless of the
+// security requirements declared by the route.
+func (sc *Client) SetBearerToken(token string) *Client {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.Authorization = "Bearer " + token
+
+	return sc
+}
+
+// parseSecuritySchemes returns the security schemes declared in the given document, keyed by name.
+func (rr *refResolver) parseSecuritySchemes(doc document) (map[string]SecurityScheme, error) {
+	defs := doc.reader.Get("securityDefinitions")
+	if doc.reader.KeyExists("openapi") {
+		defs = doc.reader.Get("components").Get("securitySchemes")
+	}
+
+	out := make(map[string]SecurityScheme, len(defs.Keys))
+	for i, def := range members(defs) {
+		_, def, err := rr.resolve(doc, &def)
+		if err != nil {
+			return nil, err
+		}
+
+		s := SecurityScheme{
+			Type: def.GetString("type"),
+			In:   def.GetString("in"),
+			Name: def.GetString("name"),
+		}
+
+		switch s.Type {
+		case "http":
+			s.Type = strings.ToLower(def.GetString("scheme"))
+		case "oauth2", "openIdConnect":
+			s.Type = "bearer"
+		}
+
+		out[defs.Keys[i]] = s
+	}
+
+	return out, nil
+}
+
+// parseSecurity returns the security requirements in the given security node. Each requirement is the set of scheme
+// names that must all be satisfied, and any one requirement is sufficient.
+func parseSecurity(security *gojson.JSONReader) [][]string {
+	out := [][]string{}
+	for _, req := range members(security) {
+		out = append(out, append([]string(nil), req.Keys...))
+	}
+
+	return out
+}
+
+// requestAuth is the set of credentials to add to a request, by location.
+type requestAuth struct {
+	headers map[string]string
+	query   []string
+	cookies []*http.Cookie
+}
+
+// authFor returns the credentials to add to a request for the given route, using the first of its security
+// requirements for which every scheme has a credential set. The Client's Authorization is used otherwise.
+func (sc *Client) authFor(route Route) (requestAuth, error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	auth := requestAuth{headers: make(map[string]string)}
+	if sc.Authorization != "" {
+		auth.headers["Authorization"] = sc.Authorization
+	}
+
+	if len(route.Security) == 0 {
+		return auth, nil
+	}
+
+	for _, requirement := range route.Security {
+		satisfied := true
+		for _, name := range requirement {
+			if _, isset := sc.Credentials[name]; !isset {
+				satisfied = false
+				break
+			}
+		}
+
+		if !satisfied {
+			continue
+		}
+
+		for _, name := range requirement {
+			scheme, isset := sc.SecuritySchemes[name]
+			if !isset {
+				return auth, fmt.Errorf("Exec: Security scheme '%s' is not defined", name)
+			}
+
+			cred := sc.Credentials[name]
+
+			switch scheme.Type {
+			case "basic":
+				auth.headers["Authorization"] = "Basic " + basicAuth(cred.Username, cred.Password)
+			case "bearer":
+				auth.headers["Authorization"] = "Bearer " + cred.Token
+			case "apiKey":
+				switch scheme.In {
+				case "header":
+					auth.headers[scheme.Name] = cred.Token
+				case "query":
+					auth.query = append(auth.query, fmt.Sprintf("%s=%s", scheme.Name, url.QueryEscape(cred.Token)))
+				case "cookie":
+					auth.cookies = append(auth.cookies, &http.Cookie{Name: scheme.Name, Value: url.QueryEscape(cred.Token)})
+				}
+			default:
+				return auth, fmt.Errorf("Exec: Security scheme '%s' has unsupported type '%s'", name, scheme.Type)
+			}
+		}
+
+		return auth, nil
+	}
+
+	return auth, nil
+}
+
+// basicAuth returns the base64 encoding of the given username and password, as used in Basic Authorization.
+func basicAuth(username, password string) string {
+	req := http.Request{Header: make(http.Header)}
+	req.SetBasicAuth(username, password)
+
+	return strings.TrimPrefix(req.Header.Get("Authorization"), "Basic ")
+}
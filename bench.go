@@ -0,0 +1,102 @@
+package gointegration
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// BenchmarkExec benchmarks the operation at the given path specifier, making b.N requests with the given params, one
+// after another. Refer to Benchmark.
+func (sc *Client) BenchmarkExec(b *testing.B, specifier string, params map[string]interface{}) LoadStats {
+	return Benchmark(b, func() ClientResponse {
+		return sc.Exec(specifier, params)
+	})
+}
+
+// BenchmarkExecParallel benchmarks the operation at the given path specifier, making b.N requests with the given
+// params from parallel goroutines, as set by b.SetParallelism. Refer to BenchmarkParallel.
+func (sc *Client) BenchmarkExecParallel(b *testing.B, specifier string, params map[string]interface{}) LoadStats {
+	return BenchmarkParallel(b, func() ClientResponse {
+		return sc.Exec(specifier, params)
+	})
+}
+
+// Benchmark benchmarks the request made by the given function, such as a call to ExecRaw, calling it b.N times, one
+// after another, for use with `go test -bench`. The request is made once first, outside of the timing, to fail the
+// benchmark early if it can't be made, and to set the bytes per op from the size of its response, so that throughput
+// is reported in MB/s. The p50, p95, and p99 latencies of the requests are reported with the benchmark, which fails
+// if any request failed to be made or received a 5xx status. The stats of the requests are returned.
+func Benchmark(b *testing.B, exec func() ClientResponse) LoadStats {
+	b.Helper()
+	warmUp(b, exec)
+
+	results := make([]ClientResponse, 0, b.N)
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		results = append(results, loadResult(exec()))
+	}
+	elapsed := time.Since(start)
+	b.StopTimer()
+
+	return reportBenchmark(b, results, elapsed)
+}
+
+// BenchmarkParallel behaves as Benchmark, calling the given function from parallel goroutines with b.RunParallel, so
+// the function must be safe for concurrent use, as Exec on a configured Client is, hooks included.
+func BenchmarkParallel(b *testing.B, exec func() ClientResponse) LoadStats {
+	b.Helper()
+	warmUp(b, exec)
+
+	var mu sync.Mutex
+	var results []ClientResponse
+
+	b.ResetTimer()
+	start := time.Now()
+	b.RunParallel(func(pb *testing.PB) {
+		// Each goroutine keeps its own results, merged once it is done, so as not to contend on every request.
+		var local []ClientResponse
+		for pb.Next() {
+			local = append(local, loadResult(exec()))
+		}
+
+		mu.Lock()
+		results = append(results, local...)
+		mu.Unlock()
+	})
+	elapsed := time.Since(start)
+	b.StopTimer()
+
+	return reportBenchmark(b, results, elapsed)
+}
+
+// warmUp makes the request made by the given function once, failing the benchmark if it can't be made, and sets the
+// bytes per op from the size of its response.
+func warmUp(b *testing.B, exec func() ClientResponse) {
+	b.Helper()
+
+	resp := exec()
+	if resp.Error != nil {
+		b.Fatalf("benchmark request failed: %s", resp.Error.Error())
+	}
+
+	b.SetBytes(resp.BytesReceived)
+}
+
+// reportBenchmark reports the latency percentiles of the given results with the benchmark, failing it if any request
+// failed, and returns their stats.
+func reportBenchmark(b *testing.B, results []ClientResponse, elapsed time.Duration) LoadStats {
+	b.Helper()
+
+	stats := loadStats(results, elapsed)
+	b.ReportMetric(float64(stats.P50.Nanoseconds()), "p50-ns")
+	b.ReportMetric(float64(stats.P95.Nanoseconds()), "p95-ns")
+	b.ReportMetric(float64(stats.P99.Nanoseconds()), "p99-ns")
+
+	if stats.ErrorRate > 0 {
+		b.Errorf("%.2f%% of benchmark requests failed: %s", stats.ErrorRate*100, stats.failures())
+	}
+
+	return stats
+}
@@ -0,0 +1,50 @@
+package gointegration
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+)
+
+// streamedBodyKey is the context key marking a request whose body was given as an io.Reader, to be streamed as it is
+// sent rather than held in memory.
+type streamedBodyKey struct{}
+
+// withStreamedBody marks the request with the given context as having a body given as an io.Reader.
+func withStreamedBody(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamedBodyKey{}, true)
+}
+
+// streamed returns true if the given request has a body given as an io.Reader which can't be replayed, and so must
+// never be buffered. Readers which hold their contents in memory, such as a *bytes.Reader, can be replayed.
+func streamed(req *http.Request) bool {
+	s, _ := req.Context().Value(streamedBodyKey{}).(bool)
+	return s && req.GetBody == nil
+}
+
+// gzipStream returns a copy of the given request whose streamed body is gzipped as it is sent. As the length of the
+// compressed body is unknown, it is sent with chunked transfer encoding.
+func gzipStream(req *http.Request) *http.Request {
+	pr, pw := io.Pipe()
+	body := req.Body
+
+	go func() {
+		defer body.Close()
+
+		gz := gzip.NewWriter(pw)
+		_, err := io.Copy(gz, body)
+		if err == nil {
+			err = gz.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	out := req.Clone(req.Context())
+	out.Header.Set("Content-Encoding", "gzip")
+	out.ContentLength = -1
+	out.Body = pr
+	out.GetBody = nil
+
+	return out
+}
@@ -0,0 +1,248 @@
+package gointegration
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResponseCache holds the responses to GET requests, so that identical GET requests made later, such as for reference
+// data fetched by many subtests, are served from memory rather than the service. Attach one to a Client with
+// CacheResponses. It is safe for concurrent use, and may be shared by several Clients.
+type ResponseCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+	hits    int
+	misses  int
+}
+
+// cacheEntry is a response held by a ResponseCache, for a request to the given path. The body is held as it was
+// received, before any content coding is reversed, so that it is read as any other response is.
+type cacheEntry struct {
+	path          string
+	status        int
+	proto         string
+	header        http.Header
+	trailer       http.Header
+	contentLength int64
+	body          []byte
+	expires       time.Time
+}
+
+// NewResponseCache returns an empty ResponseCache, whose responses expire after the given time to live. A ttl of 0
+// or less keeps responses for as long as the cache is in use.
+func NewResponseCache(ttl time.Duration) *ResponseCache {
+	return &ResponseCache{ttl: ttl, entries: make(map[string]cacheEntry)}
+}
+
+// WithResponseCache attaches a new ResponseCache, with the given time to live, to the Client. Refer to CacheResponses.
+func WithResponseCache(ttl time.Duration) Option {
+	return func(sc *Client) error {
+		sc.CacheResponses(NewResponseCache(ttl))
+		return nil
+	}
+}
+
+// CacheResponses attaches the given ResponseCache to the Client, replacing any already attached; nil detaches it. Only
+// successful responses to GET requests are cached, under their method, URL, and headers, as set once middlewares have
+// run, along with the cookies of the cookie jar of the http.Client, so that requests with different credentials are
+// cached apart. Requests with an unsafe method, such as POST or DELETE, invalidate the responses cached for their
+// path, whatever their query, as they may change what it returns. Cache headers sent by the service are ignored.
+//
+// A response served from the cache has Cached set, and no Attempts or Timings; it doesn't wait for the rate limit,
+// and isn't logged or recorded in HAR files or Metrics, as no request was made, though assertions on it are recorded
+// as usual. The cache is shared with clones of the Client, so that it spans a suite.
+func (sc *Client) CacheResponses(cache *ResponseCache) *Client {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.cache = cache
+
+	return sc
+}
+
+// currentCache returns the ResponseCache attached to the Client, if any.
+func (sc *Client) currentCache() *ResponseCache {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	return sc.cache
+}
+
+// Clear removes every response from the cache.
+func (c *ResponseCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]cacheEntry)
+}
+
+// Stats returns the number of requests served from the cache, and the number which were not cached and so were made.
+func (c *ResponseCache) Stats() (hits, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hits, c.misses
+}
+
+// cacheable returns true if the response to the given request may be served from, or stored in, the cache. Requests
+// whose bodies are read as a stream, such as by ExecSSE or ExecStream, aren't cached.
+func cacheable(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+
+	_, sse := req.Context().Value(bodyReaderKey{}).(func(io.Reader) ([]byte, error))
+	_, sink := req.Context().Value(bodySinkKey{}).(*bodySink)
+
+	return !sse && !sink
+}
+
+// cacheKey returns the key the response to the given request is cached under: its method, URL, and headers, leaving
+// out the trace context, which differs for every request, and the cookies the given jar, if any, adds to it. The
+// request must be as it is sent, once middlewares have run, so that the headers they set are part of the key.
+func cacheKey(req *http.Request, jar http.CookieJar) string {
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		if name == "Traceparent" || name == "Tracestate" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(req.Method + " " + req.URL.String() + "\n")
+	for _, name := range names {
+		b.WriteString(name + ": " + strings.Join(req.Header[name], ", ") + "\n")
+	}
+
+	if jar != nil {
+		cookies := jar.Cookies(req.URL)
+		pairs := make([]string, len(cookies))
+		for i, c := range cookies {
+			pairs[i] = c.Name + "=" + c.Value
+		}
+		sort.Strings(pairs)
+		b.WriteString("Jar: " + strings.Join(pairs, "; ") + "\n")
+	}
+
+	return b.String()
+}
+
+// get returns a response to the given request, sent with the given cookie jar, built from the response cached for
+// it, and true if there is one.
+func (c *ResponseCache) get(req *http.Request, jar http.CookieJar) (*http.Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := cacheKey(req, jar)
+	entry, ok := c.entries[key]
+	if ok && !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	c.hits++
+
+	return entry.response(req), true
+}
+
+// store caches the given response to the given GET request, sent with the given cookie jar, if it succeeded, or, for
+// a request with an unsafe method, removes the responses cached for its path. The body of a response which is cached
+// is read, so the response returned in its place must be used instead.
+func (c *ResponseCache) store(req *http.Request, jar http.CookieJar, res *http.Response) *http.Response {
+	switch req.Method {
+	case http.MethodHead, http.MethodOptions:
+		return res
+	case http.MethodGet:
+	default:
+		c.invalidate(req.URL.Path)
+		return res
+	}
+
+	if !cacheable(req) || res.StatusCode < 200 || res.StatusCode > 299 {
+		return res
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		// The body is given as it was read, with its error, for doRequest to report.
+		res.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{err}))
+		return res
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	entry := cacheEntry{
+		path:          req.URL.Path,
+		status:        res.StatusCode,
+		proto:         res.Proto,
+		header:        res.Header.Clone(),
+		trailer:       res.Trailer.Clone(),
+		contentLength: res.ContentLength,
+		body:          body,
+	}
+	if c.ttl > 0 {
+		entry.expires = time.Now().Add(c.ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[cacheKey(req, jar)] = entry
+
+	return res
+}
+
+// invalidate removes the responses cached for requests to the given path.
+func (c *ResponseCache) invalidate(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.entries {
+		if entry.path == path {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// response returns a response to the given request, as the one the entry was cached from, with a copy of its headers
+// and body, so that the entry can't be changed through it.
+func (e cacheEntry) response(req *http.Request) *http.Response {
+	res := &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.status, http.StatusText(e.status)),
+		StatusCode:    e.status,
+		Proto:         e.proto,
+		Header:        e.header.Clone(),
+		Trailer:       e.trailer.Clone(),
+		ContentLength: e.contentLength,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.body)),
+		Request:       req,
+	}
+	res.ProtoMajor, res.ProtoMinor, _ = http.ParseHTTPVersion(e.proto)
+
+	return res
+}
+
+// errReader is an io.Reader which fails with its error.
+type errReader struct {
+	err error
+}
+
+// Read returns the error of the errReader.
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,150 @@
+package gointegration
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// cp1252 maps the bytes 0x80 to 0x9F of windows-1252 to the runes they stand for. The rest of windows-1252 matches
+// ISO-8859-1, and so Unicode. The bytes 0x81, 0x8D, 0x8F, 0x90, and 0x9D are undefined, and left as C1 controls.
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// charsets holds the decoders of the charsets which bodies are transcoded to UTF-8 from, keyed by their labels.
+var charsets = map[string]func(body []byte) []byte{
+	"iso-8859-1":   decodeLatin1,
+	"iso8859-1":    decodeLatin1,
+	"iso_8859-1":   decodeLatin1,
+	"latin1":       decodeLatin1,
+	"l1":           decodeLatin1,
+	"windows-1252": decodeCP1252,
+	"cp1252":       decodeCP1252,
+	"x-cp1252":     decodeCP1252,
+	"utf-16":       decodeUTF16,
+	"utf-16be":     func(body []byte) []byte { return decodeUTF16With(binary.BigEndian, body) },
+	"utf-16le":     func(body []byte) []byte { return decodeUTF16With(binary.LittleEndian, body) },
+}
+
+// WithRawCharset leaves the Body of responses in the charset they were sent in. Refer to Client.RawCharset.
+func WithRawCharset() Option {
+	return func(sc *Client) error {
+		sc.RawCharset = true
+		return nil
+	}
+}
+
+// decodeCharset returns the given body transcoded to UTF-8 from the charset named by the given Content-Type header,
+// along with the label of the charset. The body is returned as-is, with an empty label, if the header names no
+// charset, or one which is UTF-8, US-ASCII, or unknown.
+func decodeCharset(body []byte, contentType string) ([]byte, string) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return body, ""
+	}
+
+	label := strings.ToLower(strings.TrimSpace(params["charset"]))
+	decode, ok := charsets[label]
+	if !ok {
+		return body, ""
+	}
+
+	return decode(body), label
+}
+
+// decodeLatin1 transcodes the given ISO-8859-1 text to UTF-8, in which each byte is the rune of the same value.
+func decodeLatin1(body []byte) []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(body))
+
+	for _, b := range body {
+		buf.WriteRune(rune(b))
+	}
+
+	return buf.Bytes()
+}
+
+// decodeCP1252 transcodes the given windows-1252 text to UTF-8.
+func decodeCP1252(body []byte) []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(body))
+
+	for _, b := range body {
+		if b >= 0x80 && b <= 0x9F {
+			buf.WriteRune(cp1252[b-0x80])
+			continue
+		}
+		buf.WriteRune(rune(b))
+	}
+
+	return buf.Bytes()
+}
+
+// decodeUTF16 transcodes the given UTF-16 text to UTF-8, in the byte order given by its byte order mark, or big
+// endian if it has none.
+func decodeUTF16(body []byte) []byte {
+	if bytes.HasPrefix(body, []byte{0xFF, 0xFE}) {
+		return decodeUTF16With(binary.LittleEndian, body)
+	}
+
+	return decodeUTF16With(binary.BigEndian, body)
+}
+
+// decodeUTF16With transcodes the given UTF-16 text, in the given byte order, to UTF-8, dropping any byte order mark.
+// A trailing odd byte, or an unpaired surrogate, becomes the replacement character.
+func decodeUTF16With(order binary.ByteOrder, body []byte) []byte {
+	units := make([]uint16, 0, len(body)/2)
+	for i := 0; i+1 < len(body); i += 2 {
+		units = append(units, order.Uint16(body[i:]))
+	}
+
+	if len(units) > 0 && units[0] == 0xFEFF {
+		units = units[1:]
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(body))
+
+	for _, r := range utf16.Decode(units) {
+		buf.WriteRune(r)
+	}
+
+	if len(body)%2 == 1 {
+		buf.WriteRune(utf8.RuneError)
+	}
+
+	return buf.Bytes()
+}
+
+// xmlCharsetReader returns a CharsetReader for encoding/xml which transcodes documents whose encoding declaration
+// names one of the charsets to UTF-8. Documents declared as US-ASCII, which UTF-8 extends, are read as-is, as are
+// documents which were transcoded already, as the Body of a response whose Content-Type named its charset is,
+// whatever their declaration says.
+func xmlCharsetReader(transcoded bool) func(label string, input io.Reader) (io.Reader, error) {
+	return func(label string, input io.Reader) (io.Reader, error) {
+		label = strings.ToLower(strings.TrimSpace(label))
+		if transcoded || label == "us-ascii" || label == "ascii" {
+			return input, nil
+		}
+
+		decode, ok := charsets[label]
+		if !ok {
+			return nil, fmt.Errorf("unsupported encoding '%s'", label)
+		}
+
+		body, err := ioutil.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+
+		return bytes.NewReader(decode(body)), nil
+	}
+}
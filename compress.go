@@ -0,0 +1,72 @@
+package gointegration
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// gzipRequestsKey is the context key under which a per-request GzipRequests override is stored.
+type gzipRequestsKey struct{}
+
+// WithGzipRequests gzips the body of every request. Refer to Client.GzipRequests.
+func WithGzipRequests() Option {
+	return func(sc *Client) error {
+		sc.GzipRequests = true
+		return nil
+	}
+}
+
+// gzipRequests returns whether the body of the request with the given context should be gzipped, given the
+// GzipRequests setting of the Client.
+func gzipRequests(ctx context.Context, gzipRequests bool) bool {
+	if gzip, ok := ctx.Value(gzipRequestsKey{}).(bool); ok {
+		return gzip
+	}
+
+	return gzipRequests
+}
+
+// gzipRequest returns a copy of the given request with its body gzipped, and its Content-Encoding set to match. The
+// request is returned as-is if it has no body, or its body has a Content-Encoding already.
+func gzipRequest(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" {
+		return req, nil
+	}
+
+	if streamed(req) {
+		return gzipStream(req), nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(body); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	compressed := buf.Bytes()
+
+	// The request is copied, so that the uncompressed body is what the caller, and any retry, sees.
+	out := req.Clone(req.Context())
+	out.Header.Set("Content-Encoding", "gzip")
+	out.ContentLength = int64(len(compressed))
+	out.Body = ioutil.NopCloser(bytes.NewReader(compressed))
+	out.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(compressed)), nil
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return out, nil
+}
@@ -0,0 +1,154 @@
+package gointegration
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// coverage counts the requests made for each operation in the swagger doc, keyed by path specifier. Once examples
+// is made, by RecordPostmanExamples, the params of the last request for each operation are kept as its example.
+type coverage struct {
+	mu       sync.Mutex
+	counts   map[string]int
+	examples map[string]map[string]interface{}
+}
+
+// CoverageReport describes which operations in the swagger doc have had requests made for them by Exec and its
+// variants, as path specifiers such as "users.Get". Requests made by ExecRaw don't count towards coverage.
+type CoverageReport struct {
+	Covered   []string
+	Uncovered []string
+
+	// Requests is the number of requests made for each covered operation.
+	Requests map[string]int
+}
+
+// Percent returns the percentage of operations which are covered. A swagger doc with no operations is fully covered.
+func (r CoverageReport) Percent() float64 {
+	total := len(r.Covered) + len(r.Uncovered)
+	if total == 0 {
+		return 100
+	}
+
+	return 100 * float64(len(r.Covered)) / float64(total)
+}
+
+// String returns a summary of the coverage, listing every uncovered operation.
+func (r CoverageReport) String() string {
+	out := fmt.Sprintf("%.1f%% of operations covered (%d of %d)", r.Percent(), len(r.Covered), len(r.Covered)+len(r.Uncovered))
+	if len(r.Uncovered) > 0 {
+		out += "\nuncovered:\n\t" + strings.Join(r.Uncovered, "\n\t")
+	}
+
+	return out
+}
+
+// recordCoverage counts a request with the given params for the operation with the given tag and id.
+func (sc *Client) recordCoverage(tag, id string, params map[string]interface{}) {
+	sc.mu.Lock()
+	if sc.coverage == nil {
+		sc.coverage = &coverage{counts: make(map[string]int)}
+	}
+	cov := sc.coverage
+	sc.mu.Unlock()
+
+	cov.mu.Lock()
+	defer cov.mu.Unlock()
+
+	cov.counts[specifier(tag, id)]++
+
+	if cov.examples != nil {
+		cov.examples[specifier(tag, id)] = exampleParams(params)
+	}
+}
+
+// exampleParams returns a copy of the given params to keep as an example. Readers are left out, as they are consumed
+// by the request and can't be exported, and byte slices are copied, as the caller may reuse them.
+func exampleParams(params map[string]interface{}) map[string]interface{} {
+	example := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		switch val := v.(type) {
+		case io.Reader:
+			continue
+		case []byte:
+			v = append([]byte(nil), val...)
+		}
+		example[k] = v
+	}
+
+	return example
+}
+
+// example returns the params of the last request made for the operation with the given path specifier, if any.
+func (sc *Client) example(spec string) map[string]interface{} {
+	sc.mu.RLock()
+	cov := sc.coverage
+	sc.mu.RUnlock()
+
+	if cov == nil {
+		return nil
+	}
+
+	cov.mu.Lock()
+	defer cov.mu.Unlock()
+
+	return cov.examples[spec]
+}
+
+// specifier returns the path specifier for the operation with the given tag and id.
+func specifier(tag, id string) string {
+	if tag == "default" {
+		return id
+	}
+
+	return tag + "." + id
+}
+
+// CoverageReport returns the coverage of the swagger doc by the requests made so far, by this Client and its clones.
+func (sc *Client) CoverageReport() CoverageReport {
+	sc.mu.RLock()
+	cov := sc.coverage
+	sc.mu.RUnlock()
+
+	counts := map[string]int{}
+	if cov != nil {
+		cov.mu.Lock()
+		for spec, n := range cov.counts {
+			counts[spec] = n
+		}
+		cov.mu.Unlock()
+	}
+
+	r := CoverageReport{Requests: make(map[string]int)}
+	for tag, routes := range sc.Endpoints {
+		for id := range routes {
+			spec := specifier(tag, id)
+			if n := counts[spec]; n > 0 {
+				r.Covered = append(r.Covered, spec)
+				r.Requests[spec] = n
+				continue
+			}
+			r.Uncovered = append(r.Uncovered, spec)
+		}
+	}
+
+	sort.Strings(r.Covered)
+	sort.Strings(r.Uncovered)
+
+	return r
+}
+
+// ExpectCoverage asserts that at least the given percentage of operations in the swagger doc are covered, listing
+// the uncovered operations otherwise. It is typically called after every other test has run.
+func (sc *Client) ExpectCoverage(t *testing.T, percent float64) *Client {
+	r := sc.CoverageReport()
+	assert.True(t, r.Percent() >= percent, fmt.Sprintf("expected at least %.1f%% of operations to be covered, got %s", percent, r))
+
+	return sc
+}
@@ -0,0 +1,62 @@
+package gointegration
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// WithCurlOnFailure adds the equivalent curl command of the request to the failure message of the first failed
+// assertion on each response. Refer to Client.CurlOnFailure.
+func WithCurlOnFailure() Option {
+	return func(sc *Client) error {
+		sc.CurlOnFailure = true
+		return nil
+	}
+}
+
+// Curl returns a curl command which makes the same request as the one the response is for, with its method, URL,
+// headers, and body, so that it can be reproduced outside the test. It returns "" if the request wasn't made.
+func (c ClientResponse) Curl() string {
+	if c.request == nil {
+		return ""
+	}
+
+	return curlCommand(c.request, nil, nil)
+}
+
+// curlCommand returns the curl command for the given request, with the values of the given secret headers and query
+// params redacted.
+func curlCommand(req *http.Request, secret, secretQuery map[string]bool) string {
+	parts := []string{"curl", "-X", req.Method, shellQuote(redactURL(req.URL, secretQuery))}
+
+	if req.Host != "" && req.Host != req.URL.Host {
+		parts = append(parts, "-H", shellQuote("Host: "+req.Host))
+	}
+
+	names := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		for _, v := range req.Header[k] {
+			if secret[http.CanonicalHeaderKey(k)] {
+				v = "<redacted>"
+			}
+			parts = append(parts, "-H", shellQuote(k+": "+v))
+		}
+	}
+
+	if body := requestBody(req); body != "" {
+		parts = append(parts, "--data-raw", shellQuote(body))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// shellQuote quotes the given string for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
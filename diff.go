@@ -0,0 +1,334 @@
+package gointegration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// decodeJSON decodes the given JSON document, with numbers normalized to float64 so that they compare by value.
+func decodeJSON(data []byte) (interface{}, error) {
+	var value interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return normalizeJSON(value), nil
+}
+
+// toJSONValue converts the given expected value to a decoded JSON value. Strings and byte slices are decoded as
+// JSON documents, and any other value is round-tripped through json.Marshal, so that structs and maps compare
+// the same way as the body they are compared to.
+func toJSONValue(expected interface{}) (interface{}, error) {
+	switch val := expected.(type) {
+	case string:
+		return decodeJSON([]byte(val))
+	case []byte:
+		return decodeJSON(val)
+	}
+
+	data, err := json.Marshal(expected)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeJSON(data)
+}
+
+// jsonDiff returns the differences between the expected and actual decoded JSON values, one per line, in the form
+// "`key`: message". Keys matching any of the ignored paths, and everything beneath them, are skipped.
+func jsonDiff(key string, expected, actual interface{}, ignore []string) []string {
+	if ignored(key, ignore) {
+		return nil
+	}
+
+	path := key
+	if path == "" {
+		path = "(root)"
+	}
+
+	switch exp := expected.(type) {
+	case map[string]interface{}:
+		act, ok := actual.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("`%s`: expected %s, got %s", path, jsonType(expected), jsonString(actual))}
+		}
+
+		keys := make([]string, 0, len(exp)+len(act))
+		for k := range exp {
+			keys = append(keys, k)
+		}
+		for k := range act {
+			if _, isset := exp[k]; !isset {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		var out []string
+		for _, k := range keys {
+			child := joinKey(key, k)
+			e, inExpected := exp[k]
+			a, inActual := act[k]
+
+			switch {
+			case ignored(child, ignore):
+			case !inActual:
+				out = append(out, fmt.Sprintf("`%s`: missing, expected %s", child, jsonString(e)))
+			case !inExpected:
+				out = append(out, fmt.Sprintf("`%s`: unexpected %s", child, jsonString(a)))
+			default:
+				out = append(out, jsonDiff(child, e, a, ignore)...)
+			}
+		}
+		return out
+
+	case []interface{}:
+		act, ok := actual.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("`%s`: expected %s, got %s", path, jsonType(expected), jsonString(actual))}
+		}
+
+		var out []string
+		for i := 0; i < len(exp) || i < len(act); i++ {
+			child := joinKey(key, fmt.Sprint(i))
+			switch {
+			case ignored(child, ignore):
+			case i >= len(act):
+				out = append(out, fmt.Sprintf("`%s`: missing, expected %s", child, jsonString(exp[i])))
+			case i >= len(exp):
+				out = append(out, fmt.Sprintf("`%s`: unexpected %s", child, jsonString(act[i])))
+			default:
+				out = append(out, jsonDiff(child, exp[i], act[i], ignore)...)
+			}
+		}
+		return out
+	}
+
+	if !jsonEqual(expected, actual) {
+		return []string{fmt.Sprintf("`%s`: expected %s, got %s", path, jsonString(expected), jsonString(actual))}
+	}
+
+	return nil
+}
+
+// ignored returns true if the given key is, or is beneath, one of the given paths. A path segment of "*" matches
+// any single segment, so "items.*.id" ignores the id of every element of items.
+func ignored(key string, paths []string) bool {
+	if key == "" {
+		return false
+	}
+
+	segments := strings.Split(key, ".")
+
+	for _, p := range paths {
+		pattern := strings.Split(p, ".")
+		if len(pattern) > len(segments) {
+			continue
+		}
+
+		match := true
+		for i, seg := range pattern {
+			if seg != "*" && seg != segments[i] {
+				match = false
+				break
+			}
+		}
+
+		if match {
+			return true
+		}
+	}
+
+	return false
+}
+
+// diffContext is the number of unchanged lines shown around each change in a line diff.
+const diffContext = 3
+
+// maxDiffCells bounds the work done to find the shortest line diff. Beyond it, the differing lines are shown as a
+// single change, which is still correct, just not minimal.
+const maxDiffCells = 4000000
+
+// bodyDiff describes the differences between an expected and actual body, one per line. JSON bodies are compared
+// structurally and their differences listed by path; anything else is compared line by line. It returns nil if the
+// bodies are equal, or if both are a single line, in which case they are best shown side by side.
+func bodyDiff(expected, actual string) []string {
+	if expected == actual {
+		return nil
+	}
+
+	if json.Valid([]byte(expected)) && json.Valid([]byte(actual)) {
+		exp, _ := decodeJSON([]byte(expected))
+		act, _ := decodeJSON([]byte(actual))
+		if diff := jsonDiff("", exp, act, nil); len(diff) > 0 {
+			return diff
+		}
+	}
+
+	if !strings.Contains(expected, "\n") && !strings.Contains(actual, "\n") {
+		return nil
+	}
+
+	return lineDiff(expected, actual)
+}
+
+// valueDiff describes the differences between an expected and actual value at the given key, by path. It returns nil
+// if either value is a scalar, in which case they are best shown side by side.
+func valueDiff(key string, expected, actual interface{}) []string {
+	if !composite(expected) || !composite(actual) {
+		return nil
+	}
+
+	exp, err := toJSONValue(expected)
+	if err != nil {
+		return nil
+	}
+	act, err := toJSONValue(actual)
+	if err != nil {
+		return nil
+	}
+
+	if diff := jsonDiff(key, exp, act, nil); len(diff) > 0 {
+		return diff
+	}
+
+	// The values hold the same JSON, so they can only differ by type, such as []string and []interface{}.
+	if key == "" {
+		key = "(root)"
+	}
+
+	return []string{fmt.Sprintf("`%s`: values are equal as JSON, but expected %T, got %T", key, expected, actual)}
+}
+
+// composite returns true if the given value is a map, slice, array, or struct.
+func composite(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+
+	switch reflect.Indirect(reflect.ValueOf(value)).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		return true
+	}
+
+	return false
+}
+
+// diffLine is a single line of a line diff, marked ' ' if unchanged, '-' if only expected, or '+' if only actual.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// lineDiff returns a unified diff of the expected and actual text, showing diffContext lines around each change.
+func lineDiff(expected, actual string) []string {
+	lines := diffLines(strings.Split(expected, "\n"), strings.Split(actual, "\n"))
+
+	// Show only the changed lines and the context around them.
+	show := make([]bool, len(lines))
+	for i, l := range lines {
+		if l.op == ' ' {
+			continue
+		}
+		for j := i - diffContext; j <= i+diffContext; j++ {
+			if j >= 0 && j < len(lines) {
+				show[j] = true
+			}
+		}
+	}
+
+	out := []string{"--- expected", "+++ actual"}
+	expLine, actLine := 1, 1
+	for i, l := range lines {
+		if show[i] && (i == 0 || !show[i-1]) {
+			out = append(out, fmt.Sprintf("@@ expected line %d, actual line %d @@", expLine, actLine))
+		}
+		if show[i] {
+			out = append(out, string(l.op)+l.text)
+		}
+
+		if l.op != '+' {
+			expLine++
+		}
+		if l.op != '-' {
+			actLine++
+		}
+	}
+
+	return out
+}
+
+// diffLines returns the shortest edit script turning the lines of a into the lines of b, found by their longest
+// common subsequence.
+func diffLines(a, b []string) []diffLine {
+	// Lines common to the start and end of both are unchanged, and needn't be searched.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var out []diffLine
+	for _, l := range a[:prefix] {
+		out = append(out, diffLine{' ', l})
+	}
+
+	x, y := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(x)*len(y) > maxDiffCells {
+		for _, l := range x {
+			out = append(out, diffLine{'-', l})
+		}
+		for _, l := range y {
+			out = append(out, diffLine{'+', l})
+		}
+	} else {
+		// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:].
+		lcs := make([][]int, len(x)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(y)+1)
+		}
+		for i := len(x) - 1; i >= 0; i-- {
+			for j := len(y) - 1; j >= 0; j-- {
+				if x[i] == y[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < len(x) || j < len(y) {
+			switch {
+			case i < len(x) && j < len(y) && x[i] == y[j]:
+				out = append(out, diffLine{' ', x[i]})
+				i++
+				j++
+			case j >= len(y) || (i < len(x) && lcs[i+1][j] >= lcs[i][j+1]):
+				out = append(out, diffLine{'-', x[i]})
+				i++
+			default:
+				out = append(out, diffLine{'+', y[j]})
+				j++
+			}
+		}
+	}
+
+	for _, l := range a[len(a)-suffix:] {
+		out = append(out, diffLine{' ', l})
+	}
+
+	return out
+}
@@ -0,0 +1,119 @@
+package gointegration
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// checksums holds the hash functions by which ExpectChecksum can check a body.
+var checksums = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// Filename returns the name of the file the response is for, from its Content-Disposition header, such as
+// "report.csv" for `attachment; filename="report.csv"`. A filename* parameter is preferred, as it may carry a name
+// which is not ASCII. The name is empty if the header is missing, or names no file.
+func (c ClientResponse) Filename() string {
+	_, params, err := mime.ParseMediaType(c.Header("Content-Disposition"))
+	if err != nil {
+		return ""
+	}
+
+	// ParseMediaType decodes filename* into filename, in place of the plain parameter.
+	return params["filename"]
+}
+
+// ExpectFilename asserts that the Content-Disposition header of the response names the given file.
+func (c ClientResponse) ExpectFilename(t *testing.T, filename string) ClientResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if _, ok := c.header("Content-Disposition"); !ok {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected file '%s', got no Content-Disposition header", filename))
+		return c
+	}
+
+	actual := c.Filename()
+	assert.Equal(c.reporter(t), filename, actual, fmt.Sprintf("expected file '%s', got '%s' instead", filename, actual))
+
+	return c
+}
+
+// ExpectChecksum asserts that the digest of the body of the response, by the given algorithm, is the given hex encoded
+// digest. The algorithm is one of "md5", "sha1", "sha256", or "sha512".
+func (c ClientResponse) ExpectChecksum(t *testing.T, algorithm, digest string) ClientResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	h, ok := checksums[strings.ToLower(algorithm)]
+	if !ok {
+		assert.Fail(c.reporter(t), fmt.Sprintf("unknown checksum algorithm '%s'", algorithm))
+		return c
+	}
+
+	sum := h()
+	sum.Write(c.BodyBytes)
+	actual := hex.EncodeToString(sum.Sum(nil))
+
+	assert.True(c.reporter(t), strings.EqualFold(digest, actual), fmt.Sprintf("expected body with %s digest '%s', got '%s' instead", algorithm, digest, actual))
+
+	return c
+}
+
+// ExpectSizeBetween asserts that the body of the response is at least min and at most max bytes, after any content
+// coding is reversed, such as for a generated export whose exact size varies.
+func (c ClientResponse) ExpectSizeBetween(t *testing.T, min, max int) ClientResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	size := len(c.BodyBytes)
+	assert.True(c.reporter(t), size >= min && size <= max, fmt.Sprintf("expected body of %d to %d bytes, got %d bytes instead", min, max, size))
+
+	return c
+}
+
+// SaveTo writes the body of the response to the file at the given path, creating its directory if need be, such as to
+// keep a downloaded file for inspection. If the path is an existing directory, the file is written within it, under
+// the name from the Content-Disposition header.
+func (c ClientResponse) SaveTo(path string) error {
+	if c.Error != nil {
+		return fmt.Errorf("SaveTo: %s", c.Error.Error())
+	}
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		name := filepath.Base(c.Filename())
+		if name == "." || name == string(filepath.Separator) {
+			return fmt.Errorf("SaveTo: %s is a directory, and the response names no file", path)
+		}
+		path = filepath.Join(path, name)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("SaveTo: %s", err.Error())
+	}
+
+	if err := ioutil.WriteFile(path, c.BodyBytes, 0644); err != nil {
+		return fmt.Errorf("SaveTo: %s", err.Error())
+	}
+
+	return nil
+}
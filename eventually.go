@@ -0,0 +1,58 @@
+package gointegration
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Eventually calls attempt, which makes a request and its assertions, every interval until the assertions pass or the
+// timeout expires, for eventually-consistent APIs and asynchronous jobs. The assertions must be made in soft mode, so
+// that failed attempts are retried rather than failing the test:
+//
+//	gointegration.Eventually(t, 30*time.Second, time.Second, func() gointegration.ClientResponse {
+//		return client.Exec("jobs.Get", params).Soft().ExpectStatus(t, 200)
+//	})
+//
+// An attempt passes if its request succeeded and none of its assertions failed. If no attempt passes in time, the test
+// fails with the failures of the last attempt. The response from the last attempt is returned.
+func Eventually(t *testing.T, timeout, interval time.Duration, attempt func() ClientResponse) ClientResponse {
+	return eventually(t, timeout, interval, attempt)
+}
+
+// EventuallyJSON behaves as Eventually, for requests returning a JSONResponse.
+func EventuallyJSON(t *testing.T, timeout, interval time.Duration, attempt func() JSONResponse) JSONResponse {
+	var last JSONResponse
+	eventually(t, timeout, interval, func() ClientResponse {
+		last = attempt()
+		return last.ClientResponse
+	})
+
+	return last
+}
+
+// eventually implements Eventually.
+func eventually(t *testing.T, timeout, interval time.Duration, attempt func() ClientResponse) ClientResponse {
+	deadline := time.Now().Add(timeout)
+
+	for attempts := 1; ; attempts++ {
+		resp := attempt()
+		if resp.Error == nil && resp.soft.pending() == 0 {
+			return resp
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			if resp.Error != nil {
+				assert.Fail(t, fmt.Sprintf("expected request to succeed within %s, but attempt %d failed with error `%v`", timeout, attempts, resp.Error))
+				return resp
+			}
+
+			assert.Fail(t, fmt.Sprintf("expected assertions to pass within %s, but attempt %d failed", timeout, attempts))
+			return resp.AssertAll(t)
+		}
+
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,126 @@
+package gointegration
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/spf13/cast"
+)
+
+// formFile is a file to be uploaded in a multipart body. The value may be an io.Reader, a []byte holding the
+// contents of the file, or a string holding the path to the file on disk.
+type formFile struct {
+	field string
+	value interface{}
+}
+
+// formFields converts a map of form field values into url.Values, expanding slices and arrays into one value
+// per element. Values which are an io.Reader are returned as files to be uploaded. The bool return is false if
+// the value is not a map.
+func formFields(val interface{}) (url.Values, []formFile, bool) {
+	if v, ok := val.(url.Values); ok {
+		return v, nil, true
+	}
+
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Map {
+		return nil, nil, false
+	}
+
+	out := make(url.Values)
+	var files []formFile
+	for _, k := range rv.MapKeys() {
+		key := cast.ToString(k.Interface())
+		for _, v := range expand(rv.MapIndex(k).Interface()) {
+			if _, ok := v.(io.Reader); ok {
+				files = append(files, formFile{field: key, value: v})
+				continue
+			}
+			out.Add(key, cast.ToString(v))
+		}
+	}
+
+	return out, files, true
+}
+
+// prefersMultipart returns true if the given media types accept multipart/form-data but not url-encoded forms.
+func prefersMultipart(consumes []string) bool {
+	return stringInSlice(multipartContentType, consumes) && !stringInSlice(formContentType, consumes)
+}
+
+// multipartBody builds a multipart/form-data body from the given fields and files, returning the body along
+// with the Content-Type header, which carries the boundary.
+func multipartBody(fields url.Values, files []formFile) ([]byte, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	// Write fields in a stable order so that request bodies are reproducible.
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range fields[k] {
+			if err := w.WriteField(k, v); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+
+	for _, f := range files {
+		if err := writeFile(w, f); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+// writeFile writes a single file part to the multipart writer. The filename is taken from the path or the
+// file's name where there is one, otherwise the name of the field is used.
+func writeFile(w *multipart.Writer, f formFile) error {
+	var r io.Reader
+	filename := f.field
+
+	switch v := f.value.(type) {
+	case []byte:
+		r = bytes.NewReader(v)
+	case string:
+		file, err := os.Open(v)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		r = file
+		filename = filepath.Base(v)
+	case io.Reader:
+		r = v
+		if named, ok := v.(interface{ Name() string }); ok {
+			filename = filepath.Base(named.Name())
+		}
+	default:
+		return fmt.Errorf("unsupported value of type %T for file '%s'", f.value, f.field)
+	}
+
+	part, err := w.CreateFormFile(f.field, filename)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(part, r)
+	return err
+}
@@ -1,9 +1,15 @@
 module github.com/btm6084/gointegration
 
-go 1.12
+go 1.24
 
 require (
+	github.com/andybalholm/brotli v1.1.0
 	github.com/btm6084/gojson v0.0.0-20190528182933-04c0929b1e82
 	github.com/spf13/cast v1.3.0
 	github.com/stretchr/testify v1.3.0
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+)
@@ -0,0 +1,137 @@
+package gointegration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// graphQLRequest is the body of a GraphQL query sent over HTTP.
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+// ExecGraphQL POSTs the given GraphQL query or mutation, and its variables, if any, to the GraphQLPath of the Client,
+// returning the response as a JSONResponse. Variables may be given as Variable, to be resolved from the variable
+// store. As GraphQL services usually respond with 200 OK even when the query fails, use ExpectNoGraphQLErrors to
+// assert that it succeeded, in addition to ExpectStatus.
+func (sc *Client) ExecGraphQL(query string, variables map[string]interface{}) JSONResponse {
+	resolved, err := sc.resolveVariables(variables)
+	if err != nil {
+		return toJSONResponse(ClientResponse{Error: fmt.Errorf("ExecGraphQL: %s", err.Error())})
+	}
+
+	return sc.ExecRawJSON(http.MethodPost, sc.GraphQLPath, RequestOptions{
+		Body:    graphQLRequest{Query: query, Variables: resolved},
+		Headers: map[string]string{"Accept": "application/json"},
+	})
+}
+
+// graphQLDataKey returns the key of the given key within the data of a GraphQL response. An empty key is the data itself.
+func graphQLDataKey(key string) string {
+	if key == "" {
+		return "data"
+	}
+
+	return "data." + key
+}
+
+// ExpectGraphQLData asserts the value at the given key within the data of a GraphQL response will match the given
+// value, as in ExpectValue. The key "user.name" refers to "data.user.name".
+func (c JSONResponse) ExpectGraphQLData(t *testing.T, key string, value interface{}) JSONResponse {
+	return c.ExpectValue(t, graphQLDataKey(key), value)
+}
+
+// ExpectGraphQLDataType asserts the data type at the given key within the data of a GraphQL response will match the
+// given JSON data type, as in ExpectType.
+func (c JSONResponse) ExpectGraphQLDataType(t *testing.T, key, typ string) JSONResponse {
+	return c.ExpectType(t, graphQLDataKey(key), typ)
+}
+
+// ExpectNoGraphQLErrors asserts a GraphQL response has no errors, listing their messages if it does.
+func (c JSONResponse) ExpectNoGraphQLErrors(t *testing.T) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if !c.isGraphQLResponse() {
+		assert.Fail(c.reporter(t), "expected a GraphQL response, got a body which is not a JSON object with data or errors")
+		return c
+	}
+
+	if messages := c.graphQLErrors("message"); len(messages) > 0 {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected no GraphQL errors, got:\n\t%s", strings.Join(messages, "\n\t")))
+	}
+
+	return c
+}
+
+// ExpectGraphQLError asserts a GraphQL response has an error with the given message.
+func (c JSONResponse) ExpectGraphQLError(t *testing.T, message string) JSONResponse {
+	return c.expectGraphQLError(t, "message", message)
+}
+
+// ExpectGraphQLErrorCode asserts a GraphQL response has an error with the given code in its extensions, such as
+// "UNAUTHENTICATED", as is conventional for GraphQL servers.
+func (c JSONResponse) ExpectGraphQLErrorCode(t *testing.T, code string) JSONResponse {
+	return c.expectGraphQLError(t, "extensions.code", code)
+}
+
+// expectGraphQLError asserts a GraphQL response has an error with the given value at the given key.
+func (c JSONResponse) expectGraphQLError(t *testing.T, key, value string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if !c.isGraphQLResponse() {
+		assert.Fail(c.reporter(t), "expected a GraphQL response, got a body which is not a JSON object with data or errors")
+		return c
+	}
+
+	values := c.graphQLErrors(key)
+	for _, v := range values {
+		if v == value {
+			return c
+		}
+	}
+
+	if len(values) == 0 {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected a GraphQL error with %s `%s`, got no errors", key, value))
+		return c
+	}
+
+	assert.Fail(c.reporter(t), fmt.Sprintf("expected a GraphQL error with %s `%s`, got:\n\t%s", key, value, strings.Join(values, "\n\t")))
+
+	return c
+}
+
+// isGraphQLResponse returns true if the body of the response is JSON with data or errors, as a GraphQL response has,
+// rather than some other body, such as the HTML error page of a gateway.
+func (c JSONResponse) isGraphQLResponse() bool {
+	if c.Reader == nil || !json.Valid(c.BodyBytes) {
+		return false
+	}
+
+	return c.Reader.KeyExists("data") || c.Reader.KeyExists("errors")
+}
+
+// graphQLErrors returns the value at the given key of each error of a GraphQL response, or an empty string for an
+// error without one.
+func (c JSONResponse) graphQLErrors(key string) []string {
+	if !c.Reader.KeyExists("errors") {
+		return nil
+	}
+
+	errs := c.Reader.Get("errors")
+	values := []string{}
+	for _, e := range members(errs) {
+		values = append(values, e.GetString(key))
+	}
+
+	return values
+}
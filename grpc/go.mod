@@ -0,0 +1,23 @@
+module github.com/btm6084/gointegration/grpc
+
+go 1.26.0
+
+require (
+	github.com/btm6084/gointegration v0.0.0-00010101000000-000000000000
+	github.com/btm6084/gojson v0.0.0-20190528182933-04c0929b1e82
+	google.golang.org/grpc v1.84.0
+	google.golang.org/protobuf v1.36.12
+)
+
+require (
+	github.com/andybalholm/brotli v1.1.0 // indirect
+	github.com/spf13/cast v1.3.0 // indirect
+	github.com/stretchr/testify v1.12.1 // indirect
+	go.yaml.in/yaml/v3 v3.0.5 // indirect
+	golang.org/x/net v0.59.0 // indirect
+	golang.org/x/sys v0.48.0 // indirect
+	golang.org/x/text v0.42.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20260706201446-f0a921348800 // indirect
+)
+
+replace github.com/btm6084/gointegration => ../
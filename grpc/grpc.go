@@ -0,0 +1,522 @@
+// Package grpc invokes the unary methods of gRPC services through a gointegration Client, so that they can be tested
+// with the same assertions as REST services. It is kept apart from gointegration so that clients which don't test
+// gRPC services don't depend on the gRPC and protobuf modules.
+package grpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btm6084/gointegration"
+	"github.com/btm6084/gojson"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Code is the status code of a gRPC call.
+type Code int
+
+// The gRPC status codes.
+const (
+	OK Code = iota
+	Canceled
+	Unknown
+	InvalidArgument
+	DeadlineExceeded
+	NotFound
+	AlreadyExists
+	PermissionDenied
+	ResourceExhausted
+	FailedPrecondition
+	Aborted
+	OutOfRange
+	Unimplemented
+	Internal
+	Unavailable
+	DataLoss
+	Unauthenticated
+)
+
+// codeNames are the names of the gRPC status codes, by code.
+var codeNames = []string{
+	"OK", "Canceled", "Unknown", "InvalidArgument", "DeadlineExceeded", "NotFound", "AlreadyExists",
+	"PermissionDenied", "ResourceExhausted", "FailedPrecondition", "Aborted", "OutOfRange", "Unimplemented",
+	"Internal", "Unavailable", "DataLoss", "Unauthenticated",
+}
+
+// String returns the name of the code, such as "NotFound".
+func (c Code) String() string {
+	if c >= 0 && int(c) < len(codeNames) {
+		return codeNames[c]
+	}
+
+	return fmt.Sprintf("Code(%d)", int(c))
+}
+
+// reflectionServices are the paths of the ServerReflectionInfo methods of the versions of the gRPC server reflection
+// service, in the order they are tried.
+var reflectionServices = []string{
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+}
+
+// Client invokes the unary methods of a gRPC service by their full names, such as "grpc.health.v1.Health/Check",
+// with payloads given as in the proto3 JSON mapping. Messages are described by the descriptors given to
+// LoadDescriptorSet, or otherwise by those fetched from the service with server reflection.
+//
+// Calls are made through the gointegration.Client the Client was created from, with its Authorization,
+// IdentityHeader, variables, middleware, hooks, and recorders, over HTTP/2.
+type Client struct {
+	client  *gointegration.Client
+	address string
+	http    *http.Client
+
+	mu       sync.Mutex
+	registry *protoRegistry
+}
+
+// Response is the response of a gRPC call, whose response message is the body of its JSONResponse, as in the proto3
+// JSON mapping. Unset fields are given their default values, except message fields and those with presence. Code
+// and Message are the gRPC status of the call. The JSONResponse has an Error if the call failed without a gRPC
+// status, such as by connection error, but not if the call returned an error status.
+type Response struct {
+	gointegration.JSONResponse
+	Code    Code
+	Message string
+}
+
+// NewClient returns a Client for the gRPC service at the given address, such as "localhost:50051", or at the
+// Hostname and Port of the given gointegration.Client, if the address is empty. Calls are made over TLS if its Scheme
+// is https, and otherwise over cleartext HTTP/2.
+func NewClient(sc *gointegration.Client, address string) (*Client, error) {
+	if address != "" {
+		_, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("NewClient: invalid address %s: %s", address, err.Error())
+		}
+		if _, err := strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("NewClient: invalid address %s: invalid port %s", address, port)
+		}
+	}
+
+	rt := sc.Client.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	// Calls are made over HTTP/2 only, with the transport of the Client as configured, such as its dialer and TLS.
+	if t, ok := rt.(*http.Transport); ok {
+		t = t.Clone()
+		t.Protocols = new(http.Protocols)
+		t.Protocols.SetHTTP2(true)
+		t.Protocols.SetUnencryptedHTTP2(true)
+		rt = t
+	}
+
+	return &Client{
+		client:   sc,
+		address:  address,
+		http:     &http.Client{Transport: rt, Timeout: sc.Client.Timeout},
+		registry: newProtoRegistry(),
+	}, nil
+}
+
+// options returns the options for a call with the given headers and encoded messages, made to the address of the
+// Client with its HTTP/2 http.Client.
+func (g *Client) options(opts gointegration.RequestOptions, headers map[string]string, body []byte) gointegration.RequestOptions {
+	opts.Headers = map[string]string{"TE": "trailers"}
+	for k, v := range headers {
+		opts.Headers[k] = v
+	}
+
+	opts.Body = body
+	opts.ContentType = "application/grpc"
+	opts.HTTPClient = g.http
+	if opts.Address == "" {
+		opts.Address = g.address
+	}
+
+	return opts
+}
+
+// LoadDescriptorSet loads the message and service descriptors of the FileDescriptorSet at the given path, as
+// written by protoc --include_imports --descriptor_set_out, for services which don't support server reflection.
+func (g *Client) LoadDescriptorSet(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("LoadDescriptorSet: %s", err.Error())
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.registry.addFileDescriptorSet(data); err != nil {
+		return fmt.Errorf("LoadDescriptorSet: %s", err.Error())
+	}
+
+	return nil
+}
+
+// Invoke calls the unary method with the given full name, such as "grpc.health.v1.Health/Check", with the given
+// payload as its request message. Payload values may be given as gointegration.Variable, to be resolved from the
+// variable store.
+func (g *Client) Invoke(method string, payload map[string]interface{}) Response {
+	return g.InvokeWithOptions(method, payload, gointegration.RequestOptions{})
+}
+
+// InvokeWithOptions behaves as Invoke, additionally applying the given options to the call. Headers are sent as the
+// metadata of the call, and the Timeout is sent as its deadline. Body and ContentType are not used.
+func (g *Client) InvokeWithOptions(method string, payload map[string]interface{}, opts gointegration.RequestOptions) Response {
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	fail := func(err error) Response {
+		return Response{JSONResponse: toJSONResponse(gointegration.ClientResponse{Error: fmt.Errorf("Invoke: %s", err.Error())})}
+	}
+
+	m, err := g.method(ctx, method)
+	if err != nil {
+		return fail(err)
+	}
+
+	resolved, err := g.client.ResolveVariables(payload)
+	if err != nil {
+		return fail(err)
+	}
+	if resolved == nil {
+		resolved = map[string]interface{}{}
+	}
+
+	data, err := json.Marshal(resolved)
+	if err != nil {
+		return fail(err)
+	}
+
+	msg, err := m.codec.encode(m.desc.Input(), data)
+	if err != nil {
+		return fail(err)
+	}
+
+	headers := map[string]string{}
+	if deadline, isset := ctx.Deadline(); isset {
+		headers["grpc-timeout"] = fmt.Sprintf("%dm", time.Until(deadline).Milliseconds())
+	}
+	for k, v := range opts.Headers {
+		headers[k] = v
+	}
+
+	path := "/" + string(m.desc.Parent().FullName()) + "/" + string(m.desc.Name())
+	return g.response(g.client.ExecRawContext(ctx, http.MethodPost, path, g.options(opts, headers, frameMessage(msg))), m)
+}
+
+// unaryMethod is a unary method of a gRPC service, and the codec for its messages.
+type unaryMethod struct {
+	desc  protoreflect.MethodDescriptor
+	codec protoCodec
+}
+
+// method returns the method with the given full name, fetching its descriptors with server reflection if they have
+// not been loaded.
+func (g *Client) method(ctx context.Context, fullName string) (unaryMethod, error) {
+	fullName = strings.TrimPrefix(fullName, "/")
+
+	i := strings.LastIndex(fullName, "/")
+	if i < 0 {
+		i = strings.LastIndex(fullName, ".")
+	}
+	if i < 0 {
+		return unaryMethod{}, fmt.Errorf("invalid method %s, expected a full name such as package.Service/Method", fullName)
+	}
+	service, name := fullName[:i], fullName[i+1:]
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, isset := g.registry.service(service); !isset {
+		if err := g.reflect(ctx, service); err != nil {
+			return unaryMethod{}, err
+		}
+	}
+
+	s, isset := g.registry.service(service)
+	if !isset {
+		return unaryMethod{}, fmt.Errorf("unknown service %s", service)
+	}
+
+	m := s.Methods().ByName(protoreflect.Name(name))
+	if m == nil {
+		return unaryMethod{}, fmt.Errorf("unknown method %s of service %s", name, service)
+	}
+
+	if m.IsStreamingClient() || m.IsStreamingServer() {
+		return unaryMethod{}, fmt.Errorf("method %s is streaming, and only unary methods are supported", fullName)
+	}
+
+	return unaryMethod{desc: m, codec: protoCodec{types: g.registry.types}}, nil
+}
+
+// reflect loads the descriptors of the file declaring the given symbol, and of the files it imports, from the
+// server reflection service. The caller must hold the lock.
+func (g *Client) reflect(ctx context.Context, symbol string) error {
+	// Request the file declaring the symbol, then any imports the responses didn't include.
+	requests := []*reflectionpb.ServerReflectionRequest{{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: symbol},
+	}}
+
+	requested := map[string]bool{}
+	for len(requests) > 0 {
+		files, err := g.reflectionInfo(ctx, requests)
+		if err != nil {
+			return fmt.Errorf("server reflection of %s: %s", symbol, err.Error())
+		}
+
+		for _, file := range files {
+			if err := g.registry.addFile(file); err != nil {
+				return fmt.Errorf("server reflection of %s: %s", symbol, err.Error())
+			}
+		}
+
+		requests = nil
+		for _, dep := range g.registry.missing() {
+			if requested[dep] {
+				return fmt.Errorf("server reflection of %s returned no file %s", symbol, dep)
+			}
+
+			requested[dep] = true
+			requests = append(requests, &reflectionpb.ServerReflectionRequest{
+				MessageRequest: &reflectionpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep},
+			})
+		}
+	}
+
+	if err := g.registry.build(); err != nil {
+		return fmt.Errorf("server reflection of %s: %s", symbol, err.Error())
+	}
+
+	return nil
+}
+
+// reflectionInfo sends the given ServerReflectionRequests to the server reflection service, returning the encoded
+// file descriptors of the responses. The v1 service is used if the server has it, and v1alpha otherwise, whose
+// messages are encoded the same. The calls are made with ExecRawQuiet, as they are not part of the test.
+func (g *Client) reflectionInfo(ctx context.Context, requests []*reflectionpb.ServerReflectionRequest) ([][]byte, error) {
+	var body []byte
+	for _, r := range requests {
+		msg, err := proto.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, frameMessage(msg)...)
+	}
+
+	var resp gointegration.ClientResponse
+	var code Code
+	var message string
+	for _, path := range reflectionServices {
+		resp = g.client.ExecRawQuiet(ctx, http.MethodPost, path, g.options(gointegration.RequestOptions{}, nil, body))
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+
+		var err error
+		if code, message, err = readStatus(resp); err != nil {
+			return nil, err
+		}
+		if code != Unimplemented {
+			break
+		}
+	}
+
+	if code != OK {
+		return nil, fmt.Errorf("%s: %s", code, message)
+	}
+
+	messages, err := readMessages(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var files [][]byte
+	for _, msg := range messages {
+		var r reflectionpb.ServerReflectionResponse
+		if err := proto.Unmarshal(msg, &r); err != nil {
+			return nil, err
+		}
+
+		if e := r.GetErrorResponse(); e != nil {
+			return nil, fmt.Errorf("%s: %s", Code(e.GetErrorCode()), e.GetErrorMessage())
+		}
+
+		files = append(files, r.GetFileDescriptorResponse().GetFileDescriptorProto()...)
+	}
+
+	return files, nil
+}
+
+// response returns the Response of the given call of the given method, decoding its response message.
+func (g *Client) response(resp gointegration.ClientResponse, m unaryMethod) Response {
+	if resp.Error != nil {
+		return Response{JSONResponse: toJSONResponse(resp)}
+	}
+
+	code, message, err := readStatus(resp)
+	if err != nil {
+		resp.Error = fmt.Errorf("Invoke: %s", err.Error())
+		return Response{JSONResponse: toJSONResponse(resp)}
+	}
+
+	body := ""
+	if code == OK {
+		messages, err := readMessages(resp)
+		if err == nil && len(messages) != 1 {
+			err = fmt.Errorf("expected 1 response message, got %d", len(messages))
+		}
+
+		if err == nil {
+			body, err = m.codec.decode(m.desc.Output(), messages[0])
+		}
+
+		if err != nil {
+			resp.Error = fmt.Errorf("Invoke: %s", err.Error())
+			return Response{JSONResponse: toJSONResponse(resp), Code: code, Message: message}
+		}
+	}
+
+	resp.Body = body
+
+	return Response{JSONResponse: toJSONResponse(resp), Code: code, Message: message}
+}
+
+// toJSONResponse wraps a ClientResponse in a JSONResponse, loading the body into the Reader.
+func toJSONResponse(resp gointegration.ClientResponse) gointegration.JSONResponse {
+	reader, _ := gojson.NewJSONReader([]byte(resp.Body))
+
+	return gointegration.JSONResponse{
+		ClientResponse: resp,
+		Reader:         reader,
+	}
+}
+
+// frameMessage returns the given encoded message framed as a gRPC length-prefixed message, uncompressed.
+func frameMessage(msg []byte) []byte {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+
+	return append(frame, msg...)
+}
+
+// readMessages returns the length-prefixed messages of the body of the given response.
+func readMessages(resp gointegration.ClientResponse) ([][]byte, error) {
+	body := []byte(resp.Body)
+
+	var messages [][]byte
+	for len(body) > 0 {
+		if len(body) < 5 {
+			return nil, fmt.Errorf("response message is truncated")
+		}
+
+		size := binary.BigEndian.Uint32(body[1:5])
+		if uint32(len(body)-5) < size {
+			return nil, fmt.Errorf("response message is truncated")
+		}
+
+		msg := body[5 : 5+size]
+		if body[0] == 1 {
+			encoding := http.Header(resp.HeaderValues).Get("grpc-encoding")
+			if encoding != "gzip" {
+				return nil, fmt.Errorf("unsupported message encoding %s", encoding)
+			}
+
+			r, err := gzip.NewReader(bytes.NewReader(msg))
+			if err != nil {
+				return nil, err
+			}
+			if msg, err = ioutil.ReadAll(r); err != nil {
+				return nil, err
+			}
+		}
+
+		messages = append(messages, msg)
+		body = body[5+size:]
+	}
+
+	return messages, nil
+}
+
+// readStatus returns the gRPC status of the given response, from its trailers, or from its headers, for a response
+// without a body.
+func readStatus(resp gointegration.ClientResponse) (Code, string, error) {
+	if resp.StatusCode != http.StatusOK {
+		return 0, "", fmt.Errorf("expected a gRPC response, got HTTP status %d", resp.StatusCode)
+	}
+
+	trailers := http.Header(resp.Trailers)
+	if trailers.Get("grpc-status") == "" {
+		trailers = http.Header(resp.HeaderValues)
+	}
+
+	status := trailers.Get("grpc-status")
+	if status == "" {
+		return 0, "", fmt.Errorf("expected a gRPC response, got no grpc-status")
+	}
+
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid grpc-status %s", status)
+	}
+
+	// The message is percent-encoded.
+	message := trailers.Get("grpc-message")
+	if unescaped, err := url.PathUnescape(message); err == nil {
+		message = unescaped
+	}
+
+	return Code(code), message, nil
+}
+
+// ExpectCode asserts the call returned the given gRPC status code.
+func (c Response) ExpectCode(t *testing.T, code Code) Response {
+	c.Expect(t, func(gointegration.JSONResponse) error {
+		if c.Code == code {
+			return nil
+		}
+
+		if c.Message != "" {
+			return fmt.Errorf("expected gRPC status %s, got %s instead: %s", code, c.Code, c.Message)
+		}
+		return fmt.Errorf("expected gRPC status %s, got %s instead", code, c.Code)
+	})
+
+	return c
+}
+
+// ExpectMessage asserts the call returned a gRPC status with the given message.
+func (c Response) ExpectMessage(t *testing.T, message string) Response {
+	c.Expect(t, func(gointegration.JSONResponse) error {
+		if c.Message == message {
+			return nil
+		}
+
+		return fmt.Errorf("expected gRPC status message '%s', got '%s' instead", message, c.Message)
+	})
+
+	return c
+}
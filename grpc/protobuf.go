@@ -0,0 +1,166 @@
+package grpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// protoRegistry holds the file descriptors loaded from descriptor sets and server reflection, keyed by file name, and
+// the files and types built from them. Files may be added in any order, as they are only built once their imports
+// have been added too.
+type protoRegistry struct {
+	protos map[string]*descriptorpb.FileDescriptorProto
+	files  *protoregistry.Files
+	types  *dynamicpb.Types
+}
+
+// newProtoRegistry returns an empty protoRegistry.
+func newProtoRegistry() *protoRegistry {
+	files := new(protoregistry.Files)
+	return &protoRegistry{
+		protos: make(map[string]*descriptorpb.FileDescriptorProto),
+		files:  files,
+		types:  dynamicpb.NewTypes(files),
+	}
+}
+
+// addFileDescriptorSet adds the files of the given encoded FileDescriptorSet, and builds them.
+func (reg *protoRegistry) addFileDescriptorSet(data []byte) error {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("invalid descriptor set: %s", err.Error())
+	}
+
+	for _, fd := range set.File {
+		reg.protos[fd.GetName()] = fd
+	}
+
+	return reg.build()
+}
+
+// addFile adds the given encoded FileDescriptorProto, without building it.
+func (reg *protoRegistry) addFile(data []byte) error {
+	fd := new(descriptorpb.FileDescriptorProto)
+	if err := proto.Unmarshal(data, fd); err != nil {
+		return fmt.Errorf("invalid file descriptor: %s", err.Error())
+	}
+
+	reg.protos[fd.GetName()] = fd
+	return nil
+}
+
+// imports returns the names of the files imported by the files added, which haven't been added themselves.
+func (reg *protoRegistry) imports() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, fd := range reg.protos {
+		for _, dep := range fd.Dependency {
+			if _, isset := reg.protos[dep]; isset || seen[dep] {
+				continue
+			}
+
+			seen[dep] = true
+			names = append(names, dep)
+		}
+	}
+
+	return names
+}
+
+// missing returns the names of the imports which must still be added. The well known types, such as
+// google/protobuf/timestamp.proto, are never missing, as they are built in.
+func (reg *protoRegistry) missing() []string {
+	var names []string
+	for _, name := range reg.imports() {
+		if !builtIn(name) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// builtIn returns true if the file with the given name is linked into the binary, as the well known types are.
+func builtIn(name string) bool {
+	_, err := protoregistry.GlobalFiles.FindFileByPath(name)
+	return err == nil
+}
+
+// build builds the files and types of every file added so far, using the built in files for imports which weren't.
+func (reg *protoRegistry) build() error {
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, fd := range reg.protos {
+		set.File = append(set.File, fd)
+	}
+	for _, name := range reg.imports() {
+		if fd, err := protoregistry.GlobalFiles.FindFileByPath(name); err == nil {
+			set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+		}
+	}
+
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return err
+	}
+
+	reg.files = files
+	reg.types = dynamicpb.NewTypes(files)
+	return nil
+}
+
+// service returns the service with the given full name, such as "grpc.health.v1.Health", if it has been loaded.
+func (reg *protoRegistry) service(name string) (protoreflect.ServiceDescriptor, bool) {
+	d, err := reg.files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, false
+	}
+
+	s, ok := d.(protoreflect.ServiceDescriptor)
+	return s, ok
+}
+
+// protoCodec converts messages of the types it resolves between the proto3 JSON mapping and the protobuf encoding.
+type protoCodec struct {
+	types *dynamicpb.Types
+}
+
+// encode returns the protobuf encoding of the message of the given type, given as in the proto3 JSON mapping.
+func (pc protoCodec) encode(desc protoreflect.MessageDescriptor, data []byte) ([]byte, error) {
+	msg := dynamicpb.NewMessage(desc)
+	if err := (protojson.UnmarshalOptions{Resolver: pc.types}).Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", desc.FullName(), err.Error())
+	}
+
+	return proto.Marshal(msg)
+}
+
+// decode returns the message of the given type, encoded as protobuf, as in the proto3 JSON mapping. Unset fields
+// without presence are given their default values, so that assertions can be made on them.
+func (pc protoCodec) decode(desc protoreflect.MessageDescriptor, data []byte) (string, error) {
+	msg := dynamicpb.NewMessage(desc)
+	if err := (proto.UnmarshalOptions{Resolver: pc.types}).Unmarshal(data, msg); err != nil {
+		return "", fmt.Errorf("invalid %s: %s", desc.FullName(), err.Error())
+	}
+
+	out, err := protojson.MarshalOptions{EmitDefaultValues: true, Resolver: pc.types}.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+
+	// The output of protojson is deliberately unstable in its whitespace, so it is compacted.
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, out); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
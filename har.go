@@ -0,0 +1,305 @@
+package gointegration
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HARRecorder records every request made by the Clients it is attached to with RecordHAR, and its response, as a
+// HAR (HTTP Archive) log, which can be written by WriteHAR once a run is done. HAR files can be opened in the
+// network panel of browser dev tools, or shared with the owners of a service to show the traffic of a failed run.
+//
+// Each attempt of a retried request is recorded. Requests that fail without a response are recorded with status 0
+// and their error as _error. Headers are recorded as sent, including Authorization, so HAR files should be shared
+// with care.
+type HARRecorder struct {
+	mu      sync.Mutex
+	entries []harEntry
+}
+
+// NewHARRecorder returns an empty HARRecorder.
+func NewHARRecorder() *HARRecorder {
+	return &HARRecorder{}
+}
+
+// RecordHAR attaches the given HARRecorder to the Client, or detaches it, if nil. Clones of the Client share its
+// HARRecorder.
+func (sc *Client) RecordHAR(har *HARRecorder) *Client {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.har = har
+
+	return sc
+}
+
+// currentHAR returns the HARRecorder attached to the Client, if any.
+func (sc *Client) currentHAR() *HARRecorder {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	return sc.har
+}
+
+// Len returns the number of requests recorded so far.
+func (h *HARRecorder) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.entries)
+}
+
+// harLog is the root of a HAR file.
+type harLog struct {
+	Log struct {
+		Version string     `json:"version"`
+		Creator harCreator `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+// harCreator names the application that created a HAR file.
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// harEntry is a single request and its response.
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Error           string      `json:"_error,omitempty"`
+}
+
+// harRequest describes a request.
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harCookie    `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// harResponse describes a response.
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harCookie    `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// harNameValue is a header or query parameter.
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harCookie is a cookie sent with a request, or set by a response.
+type harCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+// harPostData is the body of a request.
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// harContent is the body of a response. Bodies that aren't valid UTF-8 are base64 encoded.
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// harTimings breaks down the time taken by a request. DNS, Connect, and SSL are -1 when the connection was reused, as
+// is SSL for a connection without TLS; Connect includes SSL. The time until the first byte was received is given as
+// wait, and the rest as receive.
+type harTimings struct {
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// harTimingsFor returns the harTimings of the given response, which took the given total number of milliseconds.
+func harTimingsFor(resp ClientResponse, total float64) harTimings {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+	if resp.Error != nil || resp.Timings.TTFB == 0 {
+		return harTimings{DNS: -1, Connect: -1, SSL: -1, Wait: total}
+	}
+
+	timings := harTimings{DNS: -1, Connect: -1, SSL: -1}
+	wait := ms(resp.Timings.TTFB)
+	if !resp.Timings.ConnReused {
+		timings.DNS = ms(resp.Timings.DNSLookup)
+		timings.Connect = ms(resp.Timings.Connect + resp.Timings.TLSHandshake)
+		if resp.Timings.TLSHandshake > 0 {
+			timings.SSL = ms(resp.Timings.TLSHandshake)
+		}
+		wait -= timings.DNS + timings.Connect
+	}
+
+	timings.Wait = math.Max(wait, 0)
+	timings.Receive = math.Max(total-ms(resp.Timings.TTFB), 0)
+
+	return timings
+}
+
+// record records the given request, started at the given time, and its response.
+func (h *HARRecorder) record(req *http.Request, resp ClientResponse, start time.Time) {
+	elapsed := resp.RequestDuration
+	if resp.Error != nil {
+		elapsed = time.Since(start)
+	}
+	ms := float64(elapsed) / float64(time.Millisecond)
+
+	entry := harEntry{
+		StartedDateTime: start.Format("2006-01-02T15:04:05.000Z07:00"),
+		Time:            ms,
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: req.Proto,
+			Cookies:     []harCookie{},
+			Headers:     harHeaders(req.Header),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+		},
+		Response: harResponse{
+			Status:      resp.StatusCode,
+			StatusText:  resp.Status,
+			HTTPVersion: resp.Proto,
+			Cookies:     []harCookie{},
+			Headers:     harHeaders(resp.HeaderValues),
+			Content:     harBody(resp.Body, http.Header(resp.HeaderValues).Get("Content-Type")),
+			RedirectURL: http.Header(resp.HeaderValues).Get("Location"),
+			HeadersSize: -1,
+			BodySize:    resp.BytesReceived,
+		},
+		Timings: harTimingsFor(resp, ms),
+	}
+
+	if entry.Request.HTTPVersion == "" {
+		entry.Request.HTTPVersion = "HTTP/1.1"
+	}
+
+	if resp.Error != nil {
+		entry.Error = resp.Error.Error()
+	}
+
+	for _, c := range req.Cookies() {
+		entry.Request.Cookies = append(entry.Request.Cookies, harCookie{Name: c.Name, Value: c.Value})
+	}
+
+	for _, c := range resp.Cookies {
+		cookie := harCookie{Name: c.Name, Value: c.Value, Path: c.Path, Domain: c.Domain, HTTPOnly: c.HttpOnly, Secure: c.Secure}
+		if !c.Expires.IsZero() {
+			cookie.Expires = c.Expires.Format(time.RFC3339)
+		}
+		entry.Response.Cookies = append(entry.Response.Cookies, cookie)
+	}
+
+	query := req.URL.Query()
+	for _, k := range sortedKeys(query) {
+		for _, v := range query[k] {
+			entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{Name: k, Value: v})
+		}
+	}
+
+	if body := requestBody(req); body != "" {
+		entry.Request.PostData = &harPostData{MimeType: req.Header.Get("Content-Type"), Text: body}
+		entry.Request.BodySize = len(body)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries = append(h.entries, entry)
+}
+
+// harHeaders returns the given headers as HAR name/value pairs, sorted by name.
+func harHeaders(header map[string][]string) []harNameValue {
+	out := []harNameValue{}
+	for _, k := range sortedKeys(header) {
+		for _, v := range header[k] {
+			out = append(out, harNameValue{Name: k, Value: v})
+		}
+	}
+
+	return out
+}
+
+// harBody returns the given response body, with the given Content-Type, as HAR content.
+func harBody(body, contentType string) harContent {
+	content := harContent{Size: len(body), MimeType: contentType, Text: body}
+	if !utf8.ValidString(body) {
+		content.Text = base64.StdEncoding.EncodeToString([]byte(body))
+		content.Encoding = "base64"
+	}
+
+	return content
+}
+
+// sortedKeys returns the keys of the given map, sorted.
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// WriteHAR writes the recorded requests to the file at the given path as a HAR 1.2 log.
+func (h *HARRecorder) WriteHAR(path string) error {
+	h.mu.Lock()
+	out := harLog{}
+	out.Log.Version = "1.2"
+	out.Log.Creator = harCreator{Name: "gointegration", Version: "1.0"}
+	out.Log.Entries = append([]harEntry{}, h.entries...)
+	h.mu.Unlock()
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("WriteHAR: %s", err.Error())
+	}
+
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("WriteHAR: %s", err.Error())
+	}
+
+	return nil
+}
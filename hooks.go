@@ -0,0 +1,286 @@
+package gointegration
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Hook is a function run by the Client around its requests, such as to obtain an auth token, seed data, or clean up
+// created resources. A hook is given a copy of the Client, whose requests don't run hooks, so that a hook can make
+// requests without running itself. The copy shares the routes and connection pool of the Client, which a hook must
+// not change. The changes a hook makes to the Authorization, Credentials, and variables of the
+// copy, such as a token it obtained, are applied to the Client once it returns.
+type Hook func(c *Client) error
+
+// AfterHook is a Hook run after a request, with its response.
+type AfterHook func(c *Client, resp ClientResponse) error
+
+// BeforeAll adds a hook which is run once, before the first request made by the Client or the first Scenario run
+// against it. If it fails, every request made by the Client fails with its error.
+func (sc *Client) BeforeAll(h Hook) *Client {
+	sc.hookMu.Lock()
+	defer sc.hookMu.Unlock()
+
+	sc.beforeAll = append(sc.beforeAll, h)
+
+	return sc
+}
+
+// AfterAll adds a hook which is run by Close, such as from TestMain once every test has run.
+func (sc *Client) AfterAll(h Hook) *Client {
+	sc.hookMu.Lock()
+	defer sc.hookMu.Unlock()
+
+	sc.afterAll = append(sc.afterAll, h)
+
+	return sc
+}
+
+// BeforeEach adds a hook which is run before each request is built, so that changes it makes to the Client, such as
+// a refreshed Authorization, apply to the request. If it fails, the request is not made, and its error is returned.
+func (sc *Client) BeforeEach(h Hook) *Client {
+	sc.hookMu.Lock()
+	defer sc.hookMu.Unlock()
+
+	sc.beforeEach = append(sc.beforeEach, h)
+
+	return sc
+}
+
+// AfterEach adds a hook which is run after each request, with its response. If it fails, and the response had no
+// error, the response returned has the hook's error.
+func (sc *Client) AfterEach(h AfterHook) *Client {
+	sc.hookMu.Lock()
+	defer sc.hookMu.Unlock()
+
+	sc.afterEach = append(sc.afterEach, h)
+
+	return sc
+}
+
+// Close runs the AfterAll hooks, in the reverse of the order they were added, returning the first error. Every hook
+// is run, even if an earlier one fails.
+func (sc *Client) Close() error {
+	if sc.hooked {
+		return nil
+	}
+
+	sc.hookMu.Lock()
+	hooks := append([]Hook(nil), sc.afterAll...)
+	sc.hookMu.Unlock()
+
+	var first error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := sc.runHook(hooks[i]); err != nil && first == nil {
+			first = fmt.Errorf("AfterAll: %s", err.Error())
+		}
+	}
+
+	return first
+}
+
+// runBeforeHooks runs the BeforeAll hooks, if they haven't been run, then the BeforeEach hooks.
+func (sc *Client) runBeforeHooks() error {
+	if sc.hooked {
+		return nil
+	}
+
+	if err := sc.runBeforeAll(); err != nil {
+		return err
+	}
+
+	sc.hookMu.Lock()
+	hooks := append([]Hook(nil), sc.beforeEach...)
+	sc.hookMu.Unlock()
+
+	for _, h := range hooks {
+		if err := sc.runHook(h); err != nil {
+			return fmt.Errorf("BeforeEach: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// runBeforeAll runs the BeforeAll hooks if they haven't been run, returning their error, if any, on every call.
+// Requests made while they are running wait for them to finish.
+func (sc *Client) runBeforeAll() error {
+	sc.beforeAllMu.Lock()
+	defer sc.beforeAllMu.Unlock()
+
+	sc.hookMu.Lock()
+	if sc.ranBeforeAll {
+		defer sc.hookMu.Unlock()
+		return sc.beforeAllErr
+	}
+	hooks := append([]Hook(nil), sc.beforeAll...)
+	sc.hookMu.Unlock()
+
+	var err error
+	for _, h := range hooks {
+		if err = sc.runHook(h); err != nil {
+			err = fmt.Errorf("BeforeAll: %s", err.Error())
+			break
+		}
+	}
+
+	sc.hookMu.Lock()
+	sc.ranBeforeAll = true
+	sc.beforeAllErr = err
+	sc.hookMu.Unlock()
+
+	return err
+}
+
+// ensureBeforeAll runs the BeforeAll hooks if they haven't been run, as for a Scenario.
+func (sc *Client) ensureBeforeAll() error {
+	if sc.hooked {
+		return nil
+	}
+
+	return sc.runBeforeAll()
+}
+
+// runAfterEach runs the AfterEach hooks with the given response, returning the response with the first error from
+// a hook if it had none.
+func (sc *Client) runAfterEach(resp ClientResponse) ClientResponse {
+	if sc.hooked {
+		return resp
+	}
+
+	sc.hookMu.Lock()
+	hooks := append([]AfterHook(nil), sc.afterEach...)
+	sc.hookMu.Unlock()
+
+	for _, h := range hooks {
+		err := sc.runHook(func(c *Client) error {
+			return h(c, resp)
+		})
+		if err != nil && resp.Error == nil {
+			resp.Error = fmt.Errorf("AfterEach: %s", err.Error())
+		}
+	}
+
+	return resp
+}
+
+// runHook runs the given hook with a copy of the Client whose requests don't run hooks, so that a hook can make
+// requests without running itself, while requests made concurrently by others run their hooks as usual. Once the
+// hook returns, the changes it made to the Authorization, Credentials, and variables of the copy are applied to the
+// Client, such as a token obtained by a BeforeAll hook.
+func (sc *Client) runHook(h Hook) error {
+	c := sc.hookClient()
+
+	before := c.hookState()
+	err := h(c)
+	sc.applyHookState(before, c.hookState())
+
+	return err
+}
+
+// hookClient returns the copy of the Client given to a hook. Unlike a Clone, which is made on every hook run, it
+// shares the routes, security schemes, and http.Client of the Client, which don't change once it is built, and
+// only copies what a hook may change through its setters: the Authorization, Credentials, variables, middleware,
+// and profile headers. It has no hooks, and its requests don't run any.
+func (sc *Client) hookClient() *Client {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	sc.hookMu.Lock()
+	c := *sc
+	sc.hookMu.Unlock()
+
+	c.mu = new(sync.RWMutex)
+	c.hookMu = new(sync.Mutex)
+	c.beforeAllMu = new(sync.Mutex)
+	c.hooked = true
+	c.beforeAll, c.afterAll, c.beforeEach, c.afterEach = nil, nil, nil, nil
+
+	c.Credentials = make(map[string]Credential, len(sc.Credentials))
+	for name, cred := range sc.Credentials {
+		c.Credentials[name] = cred
+	}
+
+	c.variables = make(map[string]interface{}, len(sc.variables))
+	for name, val := range sc.variables {
+		c.variables[name] = val
+	}
+
+	c.middleware = append([]Middleware(nil), sc.middleware...)
+
+	if sc.profileHeaders != nil {
+		c.profileHeaders = make(map[string]string, len(sc.profileHeaders))
+		for k, v := range sc.profileHeaders {
+			c.profileHeaders[k] = v
+		}
+	}
+
+	return &c
+}
+
+// hookState is the state of a Client which the changes made by a hook are applied to.
+type hookState struct {
+	authorization string
+	credentials   map[string]Credential
+	variables     map[string]interface{}
+}
+
+// hookState returns a copy of the state of the Client which a hook may change.
+func (sc *Client) hookState() hookState {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	state := hookState{
+		authorization: sc.Authorization,
+		credentials:   make(map[string]Credential, len(sc.Credentials)),
+		variables:     make(map[string]interface{}, len(sc.variables)),
+	}
+	for name, cred := range sc.Credentials {
+		state.credentials[name] = cred
+	}
+	for name, val := range sc.variables {
+		state.variables[name] = val
+	}
+
+	return state
+}
+
+// applyHookState applies to the Client what changed from the given state before a hook ran to the given state after
+// it, leaving changes made meanwhile by others, such as by concurrent requests, in place.
+func (sc *Client) applyHookState(before, after hookState) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if after.authorization != before.authorization {
+		sc.Authorization = after.authorization
+	}
+
+	for name, cred := range after.credentials {
+		if old, isset := before.credentials[name]; !isset || old != cred {
+			if sc.Credentials == nil {
+				sc.Credentials = make(map[string]Credential)
+			}
+			sc.Credentials[name] = cred
+		}
+	}
+	for name := range before.credentials {
+		if _, isset := after.credentials[name]; !isset {
+			delete(sc.Credentials, name)
+		}
+	}
+
+	for name, val := range after.variables {
+		if old, isset := before.variables[name]; !isset || !reflect.DeepEqual(old, val) {
+			if sc.variables == nil {
+				sc.variables = make(map[string]interface{})
+			}
+			sc.variables[name] = val
+		}
+	}
+	for name := range before.variables {
+		if _, isset := after.variables[name]; !isset {
+			delete(sc.variables, name)
+		}
+	}
+}
@@ -2,11 +2,16 @@ package gointegration
 
 import (
 	"bytes"
+	"compress/flate"
 	"compress/gzip"
+	"compress/zlib"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -14,12 +19,27 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/andybalholm/brotli"
 	"github.com/btm6084/gojson"
 	"github.com/spf13/cast"
 )
 
+var (
+	// ErrTimeout is wrapped by the Error of a ClientResponse when a request did not complete before its deadline or timeout.
+	ErrTimeout = errors.New("request timed out")
+
+	// ErrCanceled is wrapped by the Error of a ClientResponse when the context of a request was cancelled.
+	ErrCanceled = errors.New("request canceled")
+
+	// ErrConnection is wrapped by the Error of a ClientResponse when the connection for a request failed before a
+	// response was received, such as when it was refused or reset.
+	ErrConnection = errors.New("connection failed")
+)
+
 var (
 	// To facilitate the ability to pass multiple query parameters with the same name,
 	// parameters can be named as "paramName{number}"
@@ -32,10 +52,30 @@ var (
 	defaultHost     = "localhost"
 	defaultPort     = 4080
 	defaultTimeout  = 0
+
+	defaultGraphQLPath = "/graphql"
+
+	defaultMaxRetries       = 0
+	defaultRetryBackoff     = 0
+	defaultRetryStatusCodes = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+	formContentType      = "application/x-www-form-urlencoded"
+	multipartContentType = "multipart/form-data"
 )
 
 // Client parses a swagger.json document and exposes an interface for creating
 // API calls to the endpoint specified.
+//
+// A Client is safe for concurrent use, such as by parallel subtests, once it has been configured: Exec and its
+// variants, the variable store, and the setters for auth and middleware may be called from multiple goroutines.
+// Every request runs the hooks, whether or not others are running them, and waits for the BeforeAll hooks to finish.
+// Exported fields must not be changed while requests are being made; use Clone to give a test its own Client
+// to configure instead. A Client must be created by one of the Build functions, as its zero value has no locks.
+//
+// Clone copies every field, so a field added here is copied unless Clone says otherwise. Maps and slices which may be
+// changed after the Client is built are copied, rather than shared, and the copy gets locks of its own. The AfterAll
+// hooks are deliberately left out, as they are run by Close on the original, as are profileFromEnv and profileGiven,
+// which only matter while BuildClientWithOptions is building the original.
 type Client struct {
 	FollowRedirects bool
 	Hostname        string
@@ -44,10 +84,150 @@ type Client struct {
 	Scheme          string
 	Endpoints       map[string]Endpoints
 
+	// RejectEmptyRequired causes required parameters given as nil or an empty string to be treated as not provided.
+	RejectEmptyRequired bool
+
+	// BasePath is prepended to the path of every route. It defaults to the basePath of a Swagger 2.0 document,
+	// or the path of the first server of an OpenAPI 3.x document, unless a profile sets it. Servers holds the URLs
+	// of those servers.
+	BasePath string
+	Servers  []string
+
+	// title is the title of the swagger doc, or the name of the Postman collection, the Client was built from.
+	title string
+
+	// SecuritySchemes are the authentication schemes declared in the swagger doc, keyed by name.
+	// Credentials are the secrets used to satisfy them, as set by WithAuth.
+	SecuritySchemes map[string]SecurityScheme
+	Credentials     map[string]Credential
+
+	// Authorization, when set, is sent as the Authorization header of every request, as set by SetBasicAuth
+	// or SetBearerToken. Credentials for a route's security requirements take precedence.
+	Authorization string
+
+	// profileHeaders are sent with every request which doesn't set them itself, as set by UseProfile.
+	// profileFromEnv is set once the profile named by PROFILE has been used, and profileGiven once WithProfile
+	// has, so that BuildClientWithOptions can use the latter in place of the former.
+	profileHeaders map[string]string
+	profileFromEnv bool
+	profileGiven   bool
+
 	// Time is in MS
 	Timeout int
 
+	// MaxRetries is the number of times a request is retried after a connection error or a response
+	// with one of the RetryStatusCodes. RetryBackoff is the time to wait between attempts.
+	MaxRetries       int
+	RetryBackoff     time.Duration
+	RetryStatusCodes []int
+
+	// RetryBackoffMultiplier, when greater than 1, multiplies the wait after each attempt, for exponential backoff.
+	// RetryMaxBackoff, when set, caps the wait between attempts.
+	RetryBackoffMultiplier float64
+	RetryMaxBackoff        time.Duration
+
+	// Retryable, when set, decides whether a response is retried, in place of RetryStatusCodes and the default
+	// of retrying connection errors.
+	Retryable func(resp ClientResponse) bool
+
+	// OnRequest, when set, is called with each request before it is made.
+	OnRequest func(req *http.Request)
+
+	// OnResponse, when set, is called with each request and its response once the response is assembled,
+	// including when the request failed.
+	OnResponse func(req *http.Request, resp ClientResponse)
+
+	// LogLevel sets how much of each request is logged, as set by LOG_LEVEL. Logf writes each log line, and
+	// defaults to log.Printf.
+	LogLevel LogLevel
+	Logf     func(format string, args ...interface{})
+
+	// CurlOnFailure, as set by CURL_ON_FAILURE, adds the equivalent curl command of the request to the failure
+	// message of the first failed assertion on each response, with the values of secret headers and query params
+	// redacted.
+	CurlOnFailure bool
+
+	// Tracing, as set by TRACING or WithTracing, sends a W3C trace context in the traceparent header of every request,
+	// so that the traffic of the tests can be followed through the service in a tracing backend. Spans are created
+	// by the Tracer, when set, such as to export them with OpenTelemetry. The trace ID of each request is recorded in
+	// ClientResponse.TraceID, and given with the first failed assertion on the response. OnSpan, when set, is called
+	// with the span of each request once it is done.
+	Tracing bool
+	Tracer  Tracer
+	OnSpan  func(span Span)
+
+	// GraphQLPath is the path of the GraphQL endpoint queried by ExecGraphQL, as set by GRAPHQL_PATH. It defaults to
+	// /graphql, and, as with ExecRaw, is not prefixed with the BasePath.
+	GraphQLPath string
+
+	// GzipRequests, as set by GZIP_REQUESTS or WithGzipRequests, gzips the body of every request, setting its
+	// Content-Encoding to gzip, to check that the service accepts compressed uploads. Bodies with a Content-Encoding
+	// already are sent as-is. Hooks, middlewares, and recordings see the body uncompressed.
+	GzipRequests bool
+
+	// RawCharset, as set by WithRawCharset, leaves the Body of responses in the charset they were sent in, rather than
+	// transcoding it to UTF-8 from the charset named by their Content-Type.
+	RawCharset bool
+
+	// Templates, as set by WithTemplates, renders the template placeholders in string param values, the paths given
+	// to ExecRaw, and the bodies given to ExecSOAP, such as "{{.userID}}", with the variable store as data. Strings
+	// are otherwise sent as-is, so that payloads which contain "{{", such as Mustache templates, are left alone.
+	Templates bool
+
+	// SOAP12, as set by WithSOAP12, makes ExecSOAP send SOAP 1.2 envelopes, rather than SOAP 1.1.
+	SOAP12 bool
+
 	Client *http.Client
+
+	// checkRedirect is the CheckRedirect of the http.Client given to setHTTPClient, before it was wrapped.
+	checkRedirect func(req *http.Request, via []*http.Request) error
+
+	// mu guards the Authorization and Credentials, the middleware, the variables, and the recorders against
+	// concurrent use.
+	mu *sync.RWMutex
+
+	// middleware wraps every request made by the Client, as added by Use.
+	middleware []Middleware
+
+	// variables holds the values referred to by Variable params, as set by SetVariable or CaptureAs.
+	variables map[string]interface{}
+
+	// The hooks run around requests, as added by BeforeAll, AfterAll, BeforeEach, and AfterEach. ranBeforeAll and
+	// beforeAllErr record the outcome of the BeforeAll hooks, and beforeAllMu is held while they run, so that
+	// requests wait for them. hooked is set on the copy of the Client given to a hook, whose requests don't run hooks.
+	hookMu       *sync.Mutex
+	beforeAllMu  *sync.Mutex
+	beforeAll    []Hook
+	afterAll     []Hook
+	beforeEach   []Hook
+	afterEach    []AfterHook
+	ranBeforeAll bool
+	beforeAllErr error
+	hooked       bool
+
+	// coverage counts the requests made for each operation, and is shared with clones.
+	coverage *coverage
+
+	// recorder records requests and the outcome of assertions on their responses, as attached by Record.
+	recorder *Recorder
+
+	// har records every request and response as a HAR log, as attached by RecordHAR.
+	har *HARRecorder
+
+	// pact records the interactions of a Pact contract, as attached by RecordPact.
+	pact *Pact
+
+	// metrics counts requests and their latencies, as attached by RecordMetrics.
+	metrics *Metrics
+
+	// cache serves identical GET requests from memory, as attached by CacheResponses.
+	cache *ResponseCache
+
+	// rateLimiter limits the rate of requests, as set by RATE_LIMIT, RATE_LIMIT_BURST, or SetRateLimit.
+	rateLimiter *rateLimiter
+
+	// resolver resolves references within the loaded swagger doc, such as those in response schemas.
+	resolver *refResolver
 }
 
 // Endpoints is a collection of swagger endpoints
@@ -55,11 +235,26 @@ type Endpoints map[string]Route
 
 // Route represents an API path.
 type Route struct {
-	ID         string               `json:"id"`
-	Method     string               `json:"method"`
-	Parameters map[string]ParamSpec `json:"parameters"`
-	Path       string               `json:"path"`
-	Produces   []string             `json:"produces"`
+	Consumes   []string                `json:"consumes"`
+	ID         string                  `json:"id"`
+	Method     string                  `json:"method"`
+	Parameters map[string]ParamSpec    `json:"parameters"`
+	Path       string                  `json:"path"`
+	Produces   []string                `json:"produces"`
+	Responses  map[string]ResponseSpec `json:"responses"`
+	Security   [][]string              `json:"security"`
+}
+
+// ResponseSpec represents an API response specification.
+type ResponseSpec struct {
+	Description string   `json:"description"`
+	Produces    []string `json:"produces"`
+
+	// schemas holds the declared schema for each media type. Swagger 2.0 schemas are held under "".
+	schemas map[string]schemaRef
+
+	// examples holds the declared example body for each media type.
+	examples map[string]*gojson.JSONReader
 }
 
 // ParamSpec represent API param specifications.
@@ -73,11 +268,75 @@ type ParamSpec struct {
 
 // BuildClient creates a new swagger dument from a file on the filesystem.
 func BuildClient(path string) (*Client, error) {
+	return BuildClientWithHTTPClient(path, nil)
+}
+
+// BuildClientWithHTTPClient creates a new swagger document from a file on the filesystem, making requests with the given
+// http.Client. This allows for custom transports and TLS configuration. The given client is copied, and its CheckRedirect
+// is wrapped so that FollowRedirects is still honored. If client is nil, a default client is used.
+func BuildClientWithHTTPClient(path string, client *http.Client) (*Client, error) {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
+	return newClient(data, path, client)
+}
+
+// BuildClientFromURL creates a new swagger document by fetching it over HTTP, so that tests can target the document
+// served by the running service. The given headers, such as Authorization, are sent with the request for the document,
+// which is made with the http.Client of the new Client, as configured from the environment. Refer to
+// BuildClientFromURLWithOptions to configure that client.
+func BuildClientFromURL(specURL string, headers map[string]string) (*Client, error) {
+	return BuildClientFromURLWithOptions(specURL, headers)
+}
+
+// fetch retrieves the document at the given URL with the given client, sending the given headers with the request.
+func fetch(client *http.Client, docURL string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, docURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Request for swagger document at URL %s failed with error: %s", docURL, err.Error())
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("Request for swagger document at URL %s failed with status %d", docURL, res.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read swagger document from URL %s: %s", docURL, err.Error())
+	}
+
+	return data, nil
+}
+
+// newClient creates a new Client from the given swagger document, configured from the environment.
+// The location of the document is used to resolve references to other documents.
+func newClient(data []byte, location string, client *http.Client) (*Client, error) {
+	sc := newClientFromEnv(client)
+	if err := sc.load(data, location); err != nil {
+		return nil, err
+	}
+
+	if err := sc.useEnvProfile(); err != nil {
+		return nil, err
+	}
+
+	return sc, nil
+}
+
+// newClientFromEnv creates a new Client, with no routes, configured from the environment.
+func newClientFromEnv(client *http.Client) *Client {
 	scheme := defaultScheme
 	if os.Getenv("SCHEME") != "" {
 		scheme = os.Getenv("SCHEME")
@@ -114,42 +373,246 @@ func BuildClient(path string) (*Client, error) {
 		}
 	}
 
-	sc := Client{}
+	maxRetries := defaultMaxRetries
+	if os.Getenv("MAX_RETRIES") != "" {
+		var err error
+		maxRetries, err = strconv.Atoi(os.Getenv("MAX_RETRIES"))
+		if err != nil {
+			fmt.Printf("Invalid Max Retries '%s'.\n", os.Getenv("MAX_RETRIES"))
+			maxRetries = defaultMaxRetries
+		}
+	}
+
+	// Retry Backoff should be an integer in milliseconds.
+	retryBackoff := defaultRetryBackoff
+	if os.Getenv("RETRY_BACKOFF") != "" {
+		var err error
+		retryBackoff, err = strconv.Atoi(os.Getenv("RETRY_BACKOFF"))
+		if err != nil {
+			fmt.Printf("Invalid Retry Backoff '%s'.\n", os.Getenv("RETRY_BACKOFF"))
+			retryBackoff = defaultRetryBackoff
+		}
+	}
+
+	logLevel := LogSilent
+	if os.Getenv("LOG_LEVEL") != "" {
+		var err error
+		logLevel, err = ParseLogLevel(os.Getenv("LOG_LEVEL"))
+		if err != nil {
+			fmt.Printf("Invalid Log Level '%s'.\n", os.Getenv("LOG_LEVEL"))
+			logLevel = LogSilent
+		}
+	}
+
+	// Rate Limit should be a number of requests per second, with bursts of up to Rate Limit Burst requests.
+	var rateLimit float64
+	if os.Getenv("RATE_LIMIT") != "" {
+		var err error
+		rateLimit, err = strconv.ParseFloat(os.Getenv("RATE_LIMIT"), 64)
+		if err != nil {
+			fmt.Printf("Invalid Rate Limit '%s'.\n", os.Getenv("RATE_LIMIT"))
+			rateLimit = 0
+		}
+	}
+
+	rateLimitBurst := 1
+	if os.Getenv("RATE_LIMIT_BURST") != "" {
+		var err error
+		rateLimitBurst, err = strconv.Atoi(os.Getenv("RATE_LIMIT_BURST"))
+		if err != nil {
+			fmt.Printf("Invalid Rate Limit Burst '%s'.\n", os.Getenv("RATE_LIMIT_BURST"))
+			rateLimitBurst = 1
+		}
+	}
+
+	sc := Client{mu: new(sync.RWMutex), hookMu: new(sync.Mutex), beforeAllMu: new(sync.Mutex)}
+	sc.coverage = &coverage{counts: make(map[string]int)}
 	sc.Scheme = scheme
 	sc.Hostname = host
 	sc.IdentityHeader = idHeader
 	sc.Port = port
 	sc.Timeout = timeout
+	sc.MaxRetries = maxRetries
+	sc.RetryBackoff = time.Duration(retryBackoff) * time.Millisecond
+	sc.RetryStatusCodes = append([]int(nil), defaultRetryStatusCodes...)
+	sc.LogLevel = logLevel
+	sc.CurlOnFailure, _ = strconv.ParseBool(os.Getenv("CURL_ON_FAILURE"))
+	sc.Tracing, _ = strconv.ParseBool(os.Getenv("TRACING"))
+	sc.GzipRequests, _ = strconv.ParseBool(os.Getenv("GZIP_REQUESTS"))
+	sc.SetRateLimit(rateLimit, rateLimitBurst)
+
+	sc.GraphQLPath = defaultGraphQLPath
+	if os.Getenv("GRAPHQL_PATH") != "" {
+		sc.GraphQLPath = os.Getenv("GRAPHQL_PATH")
+	}
 
-	sc.Client = &http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if sc.FollowRedirects {
-				return nil
-			}
+	if client == nil {
+		client = &http.Client{Timeout: time.Duration(sc.Timeout) * time.Millisecond}
+	}
+	sc.setHTTPClient(client)
+
+	return &sc
+}
+
+// setHTTPClient sets a copy of the given http.Client as the client used to make requests, wrapping its
+// CheckRedirect so that FollowRedirects is honored.
+func (sc *Client) setHTTPClient(client *http.Client) {
+	c := *client
+
+	checkRedirect := c.CheckRedirect
+	sc.checkRedirect = checkRedirect
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if !sc.followRedirects(req.Context()) {
 			return http.ErrUseLastResponse
-		},
-		Timeout: time.Duration(sc.Timeout) * time.Millisecond,
+		}
+		if checkRedirect != nil {
+			return checkRedirect(req, via)
+		}
+		return nil
 	}
 
-	sc.load(data)
+	sc.Client = &c
 
-	return &sc, nil
+	// Documents referred to by the loaded swagger doc are fetched with the new client too.
+	if sc.resolver != nil {
+		sc.resolver.setClient(sc.Client)
+	}
 }
 
-func (sc *Client) load(data []byte) error {
+// Clone returns a copy of the Client which can be configured independently, such as with its own Authorization,
+// variables, or hooks, so that parallel tests don't race on a shared Client. The copy shares the loaded swagger
+// doc, the connection pool, and the coverage of the original, and starts with a copy of its variables and hooks,
+// except AfterAll, which is left to Close on the original. If the BeforeAll hooks of the original have run, the copy
+// doesn't run them.
+func (sc *Client) Clone() *Client {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	// The hooks and their outcome are guarded by hookMu, so it is held while they are copied too.
+	sc.hookMu.Lock()
+	c := *sc
+	sc.hookMu.Unlock()
+
+	c.mu = new(sync.RWMutex)
+	c.hookMu = new(sync.Mutex)
+	c.beforeAllMu = new(sync.Mutex)
+	c.profileFromEnv = false
+	c.profileGiven = false
+
+	c.Servers = append([]string(nil), sc.Servers...)
+	c.RetryStatusCodes = append([]int(nil), sc.RetryStatusCodes...)
+	c.middleware = append([]Middleware(nil), sc.middleware...)
+
+	if sc.Endpoints != nil {
+		c.Endpoints = make(map[string]Endpoints, len(sc.Endpoints))
+		for tag, routes := range sc.Endpoints {
+			c.Endpoints[tag] = make(Endpoints, len(routes))
+			for id, route := range routes {
+				c.Endpoints[tag][id] = route
+			}
+		}
+	}
+
+	if sc.SecuritySchemes != nil {
+		c.SecuritySchemes = make(map[string]SecurityScheme, len(sc.SecuritySchemes))
+		for name, scheme := range sc.SecuritySchemes {
+			c.SecuritySchemes[name] = scheme
+		}
+	}
+
+	if sc.Credentials != nil {
+		c.Credentials = make(map[string]Credential, len(sc.Credentials))
+		for name, cred := range sc.Credentials {
+			c.Credentials[name] = cred
+		}
+	}
+
+	if sc.variables != nil {
+		c.variables = make(map[string]interface{}, len(sc.variables))
+		for name, val := range sc.variables {
+			c.variables[name] = val
+		}
+	}
+
+	if sc.profileHeaders != nil {
+		c.profileHeaders = make(map[string]string, len(sc.profileHeaders))
+		for k, v := range sc.profileHeaders {
+			c.profileHeaders[k] = v
+		}
+	}
+
+	c.beforeAll = append([]Hook(nil), c.beforeAll...)
+	c.afterAll = nil
+	c.beforeEach = append([]Hook(nil), c.beforeEach...)
+	c.afterEach = append([]AfterHook(nil), c.afterEach...)
+
+	// The http.Client's CheckRedirect refers to the Client it was set on, so is wrapped again for the copy. The
+	// resolver is shared with the original, so is set aside meanwhile, as it would otherwise be given the copy's.
+	c.resolver = nil
+	if sc.Client != nil {
+		client := *sc.Client
+		client.CheckRedirect = sc.checkRedirect
+		c.setHTTPClient(&client)
+	}
+	c.resolver = sc.resolver
+
+	return &c
+}
+
+// load loads the routes of the given swagger document, found at the given location, into the Client. It fails if the
+// document isn't valid JSON, or has a $ref which can't be resolved.
+func (sc *Client) load(data []byte, location string) error {
 	reader, err := gojson.NewJSONReader(data)
 	if err != nil {
-		return err
+		return fmt.Errorf("Unable to parse swagger document %s: %s", location, err.Error())
 	}
 
+	root := document{location: location, reader: reader}
+	rr := newRefResolver(root, sc.Client)
+	sc.resolver = rr
+
 	sc.Endpoints = make(map[string]Endpoints)
 
+	// OpenAPI 3.x documents declare an "openapi" version, where Swagger 2.0 documents declare "swagger".
+	openAPI3 := reader.KeyExists("openapi")
+
+	sc.SecuritySchemes, err = rr.parseSecuritySchemes(root)
+	if err != nil {
+		return err
+	}
+
+	sc.title = reader.GetString("info.title")
+	sc.Servers = parseServers(reader.Get("servers"))
+
+	// A base path the Client already has, such as from a profile given to BuildClientWithOptions, is kept.
+	if sc.BasePath == "" {
+		sc.BasePath = reader.GetString("basePath")
+		if len(sc.Servers) > 0 {
+			if u, err := url.Parse(sc.Servers[0]); err == nil {
+				sc.BasePath = u.Path
+			}
+		}
+	}
+	security := parseSecurity(reader.Get("security"))
+
 	paths := reader.Get("paths")
-	for _, path := range paths.Keys {
-		methods := paths.Get(path)
+	for i, item := range members(paths) {
+		path := paths.Keys[i]
+		doc, methods, err := rr.resolve(root, &item)
+		if err != nil {
+			return err
+		}
+
+		// Parameters declared on the path apply to every operation beneath it.
+		common := methods.GetCollection("parameters")
+
+		for j, op := range members(methods) {
+			method := methods.Keys[j]
+			if !isOperation(method) {
+				continue
+			}
 
-		for _, method := range methods.Keys {
-			data := methods.Get(method)
+			data := &op
 			r := Route{}
 
 			id := data.GetString("operationId")
@@ -157,19 +620,52 @@ func (sc *Client) load(data []byte) error {
 			r.Method = method
 			r.Path = path
 			r.Produces = data.GetStringSlice("produces")
+			r.Consumes = data.GetStringSlice("consumes")
 
-			paramList := data.GetCollection("parameters")
+			paramList := append(append([]gojson.JSONReader(nil), common...), data.GetCollection("parameters")...)
 			r.Parameters = make(map[string]ParamSpec, len(paramList))
 			for _, param := range paramList {
-				var p ParamSpec
-				p.FoundIn = param.GetString("in")
-				p.Name = param.GetString("name")
-				p.Required = param.GetBool("required")
-				p.Type = param.GetString("type")
+				paramDoc, node, err := rr.resolve(doc, &param)
+				if err != nil {
+					return err
+				}
 
+				p, err := rr.parseParam(paramDoc, node)
+				if err != nil {
+					return err
+				}
 				r.Parameters[p.Name] = p
 			}
 
+			r.Responses, err = rr.parseResponses(doc, data.Get("responses"))
+			if err != nil {
+				return err
+			}
+
+			// Operations inherit the document's security requirements unless they declare their own.
+			r.Security = security
+			if data.KeyExists("security") {
+				r.Security = parseSecurity(data.Get("security"))
+			}
+
+			if openAPI3 {
+				if data.KeyExists("requestBody") {
+					bodyDoc, body, err := rr.resolve(doc, data.Get("requestBody"))
+					if err != nil {
+						return err
+					}
+
+					p, err := rr.parseRequestBody(bodyDoc, body)
+					if err != nil {
+						return err
+					}
+					r.Parameters[p.Name] = p
+					r.Consumes = mediaTypes(body)
+				}
+
+				r.Produces = responseMediaTypes(r.Responses)
+			}
+
 			tags := data.GetStringSlice("tags")
 
 			for _, t := range tags {
@@ -194,33 +690,174 @@ func (sc *Client) load(data []byte) error {
 
 // ExecJSON takes a path specifier and executes the corresponding functionality if found in the loaded swagger doc, returning a JSONResponse.
 func (sc *Client) ExecJSON(specifier string, params map[string]interface{}) JSONResponse {
+	return toJSONResponse(sc.Exec(specifier, params))
+}
+
+// ExecWithDeadline behaves as ExecJSON, except that the request is abandoned if it has not completed by the given deadline.
+// If the deadline has already passed, no request is made and the Error of the response wraps ErrTimeout.
+func (sc *Client) ExecWithDeadline(specifier string, params map[string]interface{}, deadline time.Time) JSONResponse {
+	if !time.Now().Before(deadline) {
+		return toJSONResponse(ClientResponse{Error: fmt.Errorf("%w: ExecWithDeadline: deadline %s has already passed", ErrTimeout, deadline.Format(time.RFC3339Nano))})
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	return sc.ExecJSONContext(ctx, specifier, params)
+}
 
+// ExecNDJSON takes a path specifier and executes the corresponding functionality if found in the loaded swagger doc,
+// parsing the body as newline-delimited JSON. Each non-blank line of the body becomes one JSONResponse in the result.
+func (sc *Client) ExecNDJSON(specifier string, params map[string]interface{}) (NDJSONResponse, error) {
 	resp := sc.Exec(specifier, params)
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var out NDJSONResponse
+	for i, line := range strings.Split(resp.Body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		reader, err := gojson.NewJSONReader([]byte(line))
+		if err != nil {
+			return out, fmt.Errorf("ExecNDJSON: unable to parse line %d: %s", i+1, err.Error())
+		}
+
+		record := resp
+		record.Body = line
+		out = append(out, JSONResponse{ClientResponse: record, Reader: reader})
+	}
+
+	return out, nil
+}
+
+// toJSONResponse wraps a ClientResponse in a JSONResponse, loading the body into the Reader.
+func toJSONResponse(resp ClientResponse) JSONResponse {
 	reader, _ := gojson.NewJSONReader([]byte(resp.Body))
 
 	return JSONResponse{
-		ClientResponse: ClientResponse{
-			Body:        resp.Body,
-			Error:       resp.Error,
-			Headers:     resp.Headers,
-			Status:      resp.Status,
-			RequestTime: resp.RequestTime,
-			RequestURL:  resp.RequestURL,
-			StatusCode:  resp.StatusCode,
-		},
-		Reader: reader,
+		ClientResponse: resp,
+		Reader:         reader,
+	}
+}
+
+// ExecWithCookies behaves as Exec, additionally sending the given cookies with the request.
+// Cookies given here are sent as-is, alongside any cookie parameters found in params.
+func (sc *Client) ExecWithCookies(specifier string, params map[string]interface{}, cookies []*http.Cookie) ClientResponse {
+	req, err := sc.newRequest(context.Background(), specifier, params)
+	if err != nil {
+		return ClientResponse{Error: err}
+	}
+
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	return sc.MakeRequest(req)
+}
+
+// ExecJSONWithCookies behaves as ExecJSON, additionally sending the given cookies with the request.
+// Cookies given here are sent as-is, alongside any cookie parameters found in params.
+func (sc *Client) ExecJSONWithCookies(specifier string, params map[string]interface{}, cookies []*http.Cookie) JSONResponse {
+	return toJSONResponse(sc.ExecWithCookies(specifier, params, cookies))
+}
+
+// ExecMatrix executes the operation at the given path specifier once for each value in values, with that value
+// assigned to the parameter named by vary. All other parameters are taken from base, which is left unmodified.
+// Requests are made sequentially, and responses are returned in the same order as values.
+func (sc *Client) ExecMatrix(specifier string, base map[string]interface{}, vary string, values []interface{}) []JSONResponse {
+	out := make([]JSONResponse, len(values))
+
+	for i, v := range values {
+		params := make(map[string]interface{}, len(base)+1)
+		for k, bv := range base {
+			params[k] = bv
+		}
+		params[vary] = v
+
+		out[i] = sc.ExecJSON(specifier, params)
 	}
+
+	return out
 }
 
 // Exec takes a path specifier and executes the corresponding functionality if found in the loaded swagger doc, returning a generic ClientResponse.
 func (sc *Client) Exec(specifier string, params map[string]interface{}) ClientResponse {
+	req, err := sc.newRequest(context.Background(), specifier, params)
+	if err != nil {
+		return ClientResponse{Error: err}
+	}
+
+	return sc.MakeRequest(req)
+}
+
+// ExecE behaves as Exec, additionally returning the Error of the response, for callers who prefer to handle transport
+// errors directly rather than through assertions. Responses with an error status code are not an error.
+func (sc *Client) ExecE(specifier string, params map[string]interface{}) (ClientResponse, error) {
+	resp := sc.Exec(specifier, params)
+	return resp, resp.Error
+}
+
+// ExecJSONE behaves as ExecJSON, additionally returning the Error of the response, or an error if the body of the
+// response is not valid JSON. An empty body is not an error.
+func (sc *Client) ExecJSONE(specifier string, params map[string]interface{}) (JSONResponse, error) {
+	resp, err := sc.ExecE(specifier, params)
+	out := toJSONResponse(resp)
+	if err != nil {
+		return out, err
+	}
+
+	if strings.TrimSpace(resp.Body) != "" && !json.Valid([]byte(resp.Body)) {
+		return out, fmt.Errorf("ExecJSONE: response from URL %s is not valid JSON", resp.RequestURL)
+	}
+
+	return out, nil
+}
+
+// ExecInto behaves as ExecJSON, additionally unmarshalling the body of the response into dest, which must be a
+// pointer. If the body can't be unmarshalled into dest, the Error of the response says why.
+func (sc *Client) ExecInto(specifier string, params map[string]interface{}, dest interface{}) JSONResponse {
+	resp := sc.ExecJSON(specifier, params)
+	if resp.Error != nil {
+		return resp
+	}
+
+	if err := gojson.Unmarshal([]byte(resp.Body), dest); err != nil {
+		resp.Error = fmt.Errorf("ExecInto: unable to unmarshal body from URL %s: %s", resp.RequestURL, err.Error())
+	}
+
+	return resp
+}
+
+// ExecContext behaves as Exec, except that the request is bound to the given context, allowing for cancellation and deadlines.
+// When the context is cancelled or its deadline is exceeded, the Error of the response wraps ErrCanceled or ErrTimeout respectively.
+func (sc *Client) ExecContext(ctx context.Context, specifier string, params map[string]interface{}) ClientResponse {
+	req, err := sc.newRequest(ctx, specifier, params)
+	if err != nil {
+		return ClientResponse{Error: err}
+	}
+
+	return sc.MakeRequest(req)
+}
+
+// ExecJSONContext behaves as ExecJSON, except that the request is bound to the given context, allowing for cancellation and deadlines.
+// When the context is cancelled or its deadline is exceeded, the Error of the response wraps ErrCanceled or ErrTimeout respectively.
+func (sc *Client) ExecJSONContext(ctx context.Context, specifier string, params map[string]interface{}) JSONResponse {
+	return toJSONResponse(sc.ExecContext(ctx, specifier, params))
+}
+
+// lookup returns the tag, operation ID, and Route for the given path specifier.
+func (sc *Client) lookup(specifier string) (string, string, Route, error) {
 	pieces := strings.Split(specifier, ".")
 	var tag string
 	var id string
 
 	switch len(pieces) {
 	default:
-		return ClientResponse{Error: fmt.Errorf("Exec: Invalid path specifier %s", id)}
+		return "", "", Route{}, fmt.Errorf("Exec: Invalid path specifier %s", id)
 	case 1:
 		tag = "default"
 		id = pieces[0]
@@ -230,35 +867,67 @@ func (sc *Client) Exec(specifier string, params map[string]interface{}) ClientRe
 	}
 
 	if _, isset := sc.Endpoints[tag]; !isset {
-		return ClientResponse{Error: fmt.Errorf("Exec: Route %s not found", specifier)}
+		return "", "", Route{}, fmt.Errorf("Exec: Route %s not found", specifier)
 	}
 
 	if _, isset := sc.Endpoints[tag][id]; !isset {
-		return ClientResponse{Error: fmt.Errorf("Exec: Route %s not found", specifier)}
+		return "", "", Route{}, fmt.Errorf("Exec: Route %s not found", specifier)
+	}
+
+	return tag, id, sc.Endpoints[tag][id], nil
+}
+
+// newRequest builds the http.Request for the operation at the given path specifier, placing each parameter
+// according to its parameter specification.
+func (sc *Client) newRequest(ctx context.Context, specifier string, params map[string]interface{}) (*http.Request, error) {
+	if err := sc.runBeforeHooks(); err != nil {
+		return nil, err
+	}
+
+	tag, id, route, err := sc.lookup(specifier)
+	if err != nil {
+		return nil, err
 	}
 
-	route := sc.Endpoints[tag][id]
+	params, err = sc.resolveVariables(params)
+	if err != nil {
+		return nil, fmt.Errorf("Exec: %s", err.Error())
+	}
 
 	// Reject if we're missing required parameters.
 	for _, ps := range route.Parameters {
-		if _, isset := params[ps.Name]; ps.Required && !isset {
-			return ClientResponse{Error: fmt.Errorf("Exec: Required Parameter '%s' not provided", ps.Name)}
+		if !ps.Required {
+			continue
+		}
+
+		val, isset := params[ps.Name]
+		if !isset {
+			return nil, fmt.Errorf("Exec: Required Parameter '%s' not provided", ps.Name)
+		}
+
+		if sc.RejectEmptyRequired && (val == nil || val == "") {
+			return nil, fmt.Errorf("Exec: Required Parameter '%s' is empty", ps.Name)
 		}
 	}
 
-	var err error
 	var postBody []byte
+	var bodyReader io.Reader
 	var query []string
+	form := make(url.Values)
+	var files []formFile
+	var cookies []*http.Cookie
+	var bodyContentType string
 	headers := make(map[string]string)
 
-	// Put the parameters into the correct place depending on the "in" value.
+	// Put the parameters into the correct place depending on the "in" value. The route is a copy of the one in
+	// Endpoints, so filling in its path doesn't affect concurrent requests for the same route.
 	for name, val := range params {
 		// Remove any positional designators to allow for the same query parameter to be used multiple times.
 		// Refer to comment on var declaration for multiParamPattern
 		name = multiParamPattern.ReplaceAllString(name, "")
 
 		if _, isset := route.Parameters[name]; !isset {
-			return ClientResponse{Error: fmt.Errorf("[Extraneous Parameter] '%s.%s' has no parameter specification '%s'", tag, id, name)}
+			return nil, fmt.Errorf("[Extraneous Parameter] '%s.%s' has no parameter specification '%s'", tag, id, name)
 		}
 
 		ps := route.Parameters[name]
@@ -268,34 +937,107 @@ func (sc *Client) Exec(specifier string, params map[string]interface{}) ClientRe
 			route.Path = strings.Replace(route.Path, fmt.Sprintf("{%s}", name), url.PathEscape(cast.ToString(val)), -1)
 
 		case "body":
-			if reflect.TypeOf(val).String() == "[]uint8" {
+			bodyContentType = ps.ContentType
+
+			// OpenAPI 3.x describes form posts as a request body, which is given as a map of field values.
+			if bodyContentType == formContentType || bodyContentType == multipartContentType {
+				if fields, fieldFiles, ok := formFields(val); ok {
+					for k, v := range fields {
+						form[k] = append(form[k], v...)
+					}
+					files = append(files, fieldFiles...)
+					continue
+				}
+			}
+
+			if r, ok := val.(io.Reader); ok {
+				bodyReader = r
+			} else if reflect.TypeOf(val).String() == "[]uint8" {
 				postBody = val.([]byte)
 			} else {
 				postBody, err = json.Marshal(val)
 				if err != nil {
-					return ClientResponse{Error: fmt.Errorf("Marshal of postBody failed with message: %s", err.Error())}
+					return nil, fmt.Errorf("Marshal of postBody failed with message: %s", err.Error())
 				}
 			}
 
 		case "query":
-			query = append(query, fmt.Sprintf("%s=%s", name, url.QueryEscape(cast.ToString(val))))
+			// Slices and arrays are expanded into one query parameter per element.
+			for _, v := range expand(val) {
+				query = append(query, fmt.Sprintf("%s=%s", name, url.QueryEscape(cast.ToString(v))))
+			}
+
+		case "formData":
+			for _, v := range expand(val) {
+				if ps.Type == "file" {
+					files = append(files, formFile{field: name, value: v})
+					continue
+				}
+				form.Add(name, cast.ToString(v))
+			}
 
 		case "header":
 			headers[name] = cast.ToString(val)
 
-			//case "cookie":
-			// @TODO
+		case "cookie":
+			// As with query parameters, slices and arrays are sent as one cookie per element.
+			for _, v := range expand(val) {
+				cookies = append(cookies, &http.Cookie{Name: name, Value: url.QueryEscape(cast.ToString(v))})
+			}
+		}
+
+	}
+
+	// Form parameters are sent in place of a JSON body. File uploads require a multipart body, otherwise the
+	// fields are url-encoded unless the route only accepts multipart.
+	if len(files) > 0 || (len(form) > 0 && prefersMultipart(route.Consumes)) {
+		postBody, bodyContentType, err = multipartBody(form, files)
+		if err != nil {
+			return nil, fmt.Errorf("Exec: unable to build multipart body: %s", err.Error())
+		}
+	} else if len(form) > 0 {
+		postBody = []byte(form.Encode())
+		bodyContentType = formContentType
+	}
+
+	// Add credentials for the route's security requirements, without overriding any given explicitly.
+	auth, err := sc.authFor(route)
+	if err != nil {
+		return nil, err
+	}
+
+	query = append(query, auth.query...)
+	cookies = append(cookies, auth.cookies...)
+	for k, v := range auth.headers {
+		if !hasHeader(headers, k) {
+			headers[k] = v
 		}
+	}
 
+	sc.mu.RLock()
+	for k, v := range sc.profileHeaders {
+		if !hasHeader(headers, k) {
+			headers[k] = v
+		}
 	}
+	sc.mu.RUnlock()
 
 	// Construct the URL
 	url := sc.buildURL(route.Path, query)
 
 	// Build the request
-	req, err := http.NewRequest(strings.ToUpper(route.Method), url, bytes.NewBuffer(postBody))
+	// The path specifier is kept with the request, as the operation it is for, such as for RecordPact.
+	ctx = context.WithValue(ctx, specifierKey{}, specifier)
+
+	var body io.Reader = bytes.NewBuffer(postBody)
+	if bodyReader != nil && len(files) == 0 && len(form) == 0 {
+		body = bodyReader
+		ctx = withStreamedBody(ctx)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(route.Method), url, body)
 	if err != nil {
-		return ClientResponse{Error: err}
+		return nil, err
 	}
 
 	// Set Content-Type header.
@@ -303,6 +1045,9 @@ func (sc *Client) Exec(specifier string, params map[string]interface{}) ClientRe
 	if len(route.Produces) > 0 {
 		contentType = route.Produces[0]
 	}
+	if bodyContentType != "" {
+		contentType = bodyContentType
+	}
 	req.Header.Set("Content-Type", contentType)
 
 	// Add headers
@@ -314,10 +1059,75 @@ func (sc *Client) Exec(specifier string, params map[string]interface{}) ClientRe
 	// The service can handle / ignore this as it sees fit.
 	req.Header.Set(sc.IdentityHeader, "true")
 
-	// @TODO
 	// Add cookies
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
 
-	return sc.MakeRequest(req)
+	sc.recordCoverage(tag, id, params)
+
+	return req, nil
+}
+
+// UseServer directs all requests to the server at the given index of Servers, overriding the Scheme, Hostname,
+// Port, and BasePath of the Client.
+func (sc *Client) UseServer(index int) error {
+	if index < 0 || index >= len(sc.Servers) {
+		return fmt.Errorf("UseServer: no server at index %d", index)
+	}
+
+	u, err := url.Parse(sc.Servers[index])
+	if err != nil {
+		return fmt.Errorf("UseServer: invalid server URL %s: %s", sc.Servers[index], err.Error())
+	}
+
+	if u.Scheme != "" {
+		sc.Scheme = u.Scheme
+	}
+
+	if u.Hostname() != "" {
+		sc.Hostname = u.Hostname()
+
+		switch {
+		case u.Port() != "":
+			sc.Port, _ = strconv.Atoi(u.Port())
+		case sc.Scheme == "https":
+			sc.Port = 443
+		default:
+			sc.Port = 80
+		}
+	}
+
+	sc.BasePath = u.Path
+
+	return nil
+}
+
+// expand returns the elements of the given value if it is a slice or array, or the value itself otherwise.
+// Byte slices are treated as a single value.
+func expand(val interface{}) []interface{} {
+	rv := reflect.ValueOf(val)
+	if (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return []interface{}{val}
+	}
+
+	out := make([]interface{}, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+
+	return out
+}
+
+// hasHeader returns true if the given header is set, regardless of case.
+func hasHeader(headers map[string]string, key string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+
+	return false
 }
 
 // buildURL returns the url based on the host, port, and scheme set in the Client
@@ -331,56 +1141,336 @@ func (sc *Client) buildURL(path string, query []string) string {
 	}
 
 	path = strings.TrimLeft(path, "/")
+	if base := strings.Trim(sc.BasePath, "/"); base != "" {
+		path = base + "/" + path
+	}
 
 	return fmt.Sprintf("%s://%s:%d/%s%s%s", sc.Scheme, sc.Hostname, sc.Port, path, separator, strings.Join(query, "&"))
 }
 
+// MakeRequestContext behaves as MakeRequest, except that the request is bound to the given context.
+func (sc *Client) MakeRequestContext(ctx context.Context, req *http.Request) ClientResponse {
+	return sc.MakeRequest(req.WithContext(ctx))
+}
+
 // MakeRequest makes a request to a third party HTTP resource based on the given http.Request object.
+// Connection errors and responses with one of the RetryStatusCodes are retried up to MaxRetries times,
+// and the response from the last attempt is returned.
 func (sc *Client) MakeRequest(req *http.Request) ClientResponse {
+	req, span := sc.startSpan(req)
+
+	if sc.OnRequest != nil {
+		sc.OnRequest(req)
+	}
+
+	resp := sc.retryRequest(req)
+	if resp.Cached {
+		resp.Attempts = 0
+	}
+
+	resp = sc.endSpan(req.Context(), span, resp)
+
+	if sc.OnResponse != nil {
+		sc.OnResponse(req, resp)
+	}
+
+	resp = sc.runAfterEach(resp)
 
+	if rec := sc.currentRecorder(); rec != nil {
+		resp.recordID = rec.recordRequest(req, resp)
+	}
+
+	if pact := sc.currentPact(); pact != nil && resp.Error == nil {
+		if spec, isset := req.Context().Value(specifierKey{}).(string); isset {
+			pact.record(spec, req, resp)
+		}
+	}
+
+	if metrics := sc.currentMetrics(); metrics != nil && !resp.Cached {
+		metrics.record(req, resp)
+	}
+
+	return resp
+}
+
+// retryRequest makes the given request, retrying as configured by MaxRetries.
+func (sc *Client) retryRequest(req *http.Request) ClientResponse {
+	// A body streamed from an io.Reader can't be replayed without buffering it, which streaming is meant to avoid.
+	if sc.MaxRetries <= 0 || streamed(req) {
+		resp := sc.makeRequest(req)
+		resp.Attempts = 1
+		return resp
+	}
+
+	// Buffer the body so that it can be replayed on each attempt.
+	var body []byte
+	replay := req.Body != nil && req.Body != http.NoBody
+	if replay {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return ClientResponse{Error: fmt.Errorf("Unable to buffer body for request to URL %s: %s", req.URL, err.Error())}
+		}
+	}
+
+	backoff := sc.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		if replay {
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		resp := sc.makeRequest(req)
+		resp.Attempts = attempt
+		if attempt > sc.MaxRetries || !sc.retryable(resp) {
+			return resp
+		}
+
+		select {
+		case <-req.Context().Done():
+			return resp
+		case <-time.After(backoff):
+		}
+
+		backoff = sc.nextBackoff(backoff)
+	}
+}
+
+// nextBackoff returns the time to wait before the attempt after one which waited the given backoff.
+func (sc *Client) nextBackoff(backoff time.Duration) time.Duration {
+	if sc.RetryBackoffMultiplier > 1 {
+		backoff = time.Duration(float64(backoff) * sc.RetryBackoffMultiplier)
+	}
+
+	if sc.RetryMaxBackoff > 0 && backoff > sc.RetryMaxBackoff {
+		backoff = sc.RetryMaxBackoff
+	}
+
+	return backoff
+}
+
+// retryable returns true if the connection for the given response failed, as ErrConnection reports, or it has one
+// of the RetryStatusCodes. If the Client has a Retryable func, it decides instead.
+func (sc *Client) retryable(resp ClientResponse) bool {
+	if sc.Retryable != nil {
+		return sc.Retryable(resp)
+	}
+
+	if resp.Error != nil {
+		return errors.Is(resp.Error, ErrConnection)
+	}
+
+	for _, code := range sc.RetryStatusCodes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// makeRequest makes a single attempt at the given http.Request.
+func (sc *Client) makeRequest(req *http.Request) ClientResponse {
+	start := time.Now()
+	resp := sc.doRequest(req)
+	resp.request = req
+	resp.failures = &sync.Once{}
+
+	// A response served from the cache was never sent, so isn't logged or recorded as traffic.
+	if resp.Cached {
+		return resp
+	}
+
+	sc.logRequest(req, resp, start)
+
+	if har := sc.currentHAR(); har != nil {
+		har.record(req, resp, start)
+	}
+
+	return resp
+}
+
+// doRequest sends the given request and reads its response.
+func (sc *Client) doRequest(req *http.Request) ClientResponse {
+	// The time taken is counted from when the request was sent, leaving out any wait for the rate limit.
+	at := &attempt{}
 	start := time.Now()
-	res, err := sc.Client.Do(req)
+	res, err := sc.roundTrip()(req.WithContext(context.WithValue(req.Context(), attemptKey{}, at)))
+	if !at.sent.IsZero() {
+		start = at.sent
+	}
 	elapsed := time.Since(start)
 	if err != nil {
+		if reason := interruption(err); reason != nil {
+			return ClientResponse{Error: fmt.Errorf("%w: request to URL %s: %s", reason, req.URL, err.Error())}
+		}
+		if connectionFailure(err) {
+			return ClientResponse{Error: fmt.Errorf("%w: request to URL %s: %s", ErrConnection, req.URL, err.Error())}
+		}
 		return ClientResponse{Error: fmt.Errorf("Request to URL %s failed with error: %s", req.URL, err.Error())}
 	}
 
 	defer res.Body.Close()
 
-	// Decompress gzip content
-	var rawBody io.ReadCloser
-	switch res.Header.Get("Content-Encoding") {
-	case "gzip":
-		rawBody, err = gzip.NewReader(res.Body)
-		if err != nil {
-			rawBody = res.Body
+	read := ioutil.ReadAll
+	if r, isset := req.Context().Value(bodyReaderKey{}).(func(io.Reader) ([]byte, error)); isset {
+		read = r
+	}
+
+	// A streamed body is written to the sink as it is read, with its content coding reversed, leaving none in memory.
+	sink, toSink := req.Context().Value(bodySinkKey{}).(*bodySink)
+	if toSink {
+		read = func(body io.Reader) ([]byte, error) {
+			return nil, sink.stream(body, res.Header.Get("Content-Encoding"))
 		}
-	default:
-		rawBody = res.Body
 	}
 
-	body, err := ioutil.ReadAll(rawBody)
-	if err != nil {
+	// A body shorter than the declared Content-Length is kept as-is, so that the
+	// mismatch can be asserted on with ExpectContentLengthAccurate.
+	raw, err := read(res.Body)
+	if reason := interruption(err); reason != nil {
+		return ClientResponse{Error: fmt.Errorf("%w: reading body from URL %s: %s", reason, req.URL, err.Error())}
+	}
+	if err != nil && !(err == io.ErrUnexpectedEOF && res.ContentLength >= 0) {
+		return ClientResponse{Error: fmt.Errorf("Unable to read body from request to URL %s: %s", req.URL, err.Error())}
+	}
+
+	received := int64(len(raw))
+	body := raw
+	if toSink {
+		received = sink.received
+	} else if body, err = decodeBody(raw, res.Header.Get("Content-Encoding")); err != nil {
 		return ClientResponse{Error: fmt.Errorf("Unable to unpack body from request to URL %s: %s", req.URL, err.Error())}
 	}
 
+	text, charset := body, ""
+	if !sc.RawCharset {
+		text, charset = decodeCharset(body, res.Header.Get("Content-Type"))
+	}
+
 	headers := make(map[string]string)
+	headerValues := make(map[string][]string)
 	for k, set := range res.Header {
 		if len(set) > 0 {
 			headers[k] = set[0]
+			headerValues[k] = append([]string(nil), set...)
 		}
 	}
 
 	out := ClientResponse{
-		Body:        string(body),
-		Cookies:     res.Cookies(),
-		Error:       nil,
-		Headers:     headers,
-		RequestTime: fmt.Sprint(elapsed),
-		RequestURL:  req.URL.String(),
-		Status:      http.StatusText(res.StatusCode),
-		StatusCode:  res.StatusCode,
+		Body:            string(text),
+		BodyBytes:       body,
+		RawBody:         raw,
+		BytesReceived:   received,
+		ContentLength:   res.ContentLength,
+		Cookies:         res.Cookies(),
+		Error:           nil,
+		Headers:         headers,
+		HeaderValues:    headerValues,
+		Proto:           res.Proto,
+		RequestTime:     fmt.Sprint(elapsed),
+		RequestDuration: elapsed,
+		RequestURL:      req.URL.String(),
+		Status:          http.StatusText(res.StatusCode),
+		StatusCode:      res.StatusCode,
+		Cached:          at.cached,
+		client:          sc,
+		charset:         charset,
+	}
+
+	if at.trace != nil {
+		out.Timings = at.trace.result()
+	}
+
+	// Trailers are only known once the body has been read.
+	for k, set := range res.Trailer {
+		if len(set) > 0 {
+			if out.Trailers == nil {
+				out.Trailers = make(map[string][]string)
+			}
+			out.Trailers[k] = append([]string(nil), set...)
+		}
 	}
 
 	return out
 }
+
+// decodeBody reverses the content codings listed in the given Content-Encoding header, in the reverse of the order
+// they were applied. If any coding is unrecognized, or its stream can't be opened, the raw body is returned instead.
+func decodeBody(raw []byte, encoding string) ([]byte, error) {
+	if encoding == "" {
+		return raw, nil
+	}
+
+	codings := strings.Split(encoding, ",")
+	body := raw
+
+	for i := len(codings) - 1; i >= 0; i-- {
+		var r io.Reader
+
+		switch strings.ToLower(strings.TrimSpace(codings[i])) {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			gz, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return raw, nil
+			}
+			r = gz
+		case "deflate":
+			// Deflate is supposed to be zlib wrapped, but some servers send raw deflate data instead.
+			zr, err := zlib.NewReader(bytes.NewReader(body))
+			if err != nil {
+				r = flate.NewReader(bytes.NewReader(body))
+			} else {
+				r = zr
+			}
+		case "br":
+			r = brotli.NewReader(bytes.NewReader(body))
+		default:
+			return raw, nil
+		}
+
+		var err error
+		body, err = ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return body, nil
+}
+
+// connectionFailure returns true if the given error, returned in place of a response, was caused by the connection
+// failing, such as by being refused, reset, or closed, rather than by the request, TLS, or the response itself.
+func connectionFailure(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return opErr.Op == "dial" || opErr.Op == "read" || opErr.Op == "write"
+	}
+
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// interruption returns ErrTimeout or ErrCanceled if the given error was caused by a deadline, timeout, or cancellation.
+// Any other error, including nil, returns nil.
+func interruption(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ErrCanceled
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrTimeout
+	}
+
+	return nil
+}
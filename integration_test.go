@@ -0,0 +1,396 @@
+package gointegration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/btm6084/gojson"
+	"github.com/stretchr/testify/assert"
+)
+
+// usersDoc is a swagger document whose parameters and responses are given by $ref, within the document, to
+// common.json beside it, and to the document served at the URL it is formatted with.
+const usersDoc = `{
+	"swagger": "2.0",
+	"basePath": "/v1",
+	"paths": {
+		"/users/{id}": {
+			"parameters": [{"$ref": "#/parameters/id"}],
+			"get": {
+				"operationId": "GetUser",
+				"tags": ["users"],
+				"parameters": [
+					{"$ref": "common.json#/parameters/limit"},
+					{"$ref": "%s/remote.json#/parameters/verbose"}
+				],
+				"responses": {"200": {"$ref": "#/responses/user"}}
+			}
+		}
+	},
+	"parameters": {
+		"id": {"$ref": "#/parameters/userID"},
+		"userID": {"name": "id", "in": "path", "required": true, "type": "integer"}
+	},
+	"responses": {
+		"user": {"description": "A user", "schema": {"type": "object"}}
+	}
+}`
+
+// commonDoc is the common.json referred to by usersDoc.
+const commonDoc = `{"parameters": {"limit": {"name": "limit", "in": "query", "type": "integer"}}}`
+
+// remoteDoc is the remote.json referred to by usersDoc, served by the test server.
+const remoteDoc = `{"parameters": {"verbose": {"name": "verbose", "in": "query", "type": "boolean"}}}`
+
+// echoServer returns a server which responds to /remote.json with remoteDoc, and to any other request with a JSON
+// object describing it. Every request it receives is counted in hits.
+func echoServer(t *testing.T, hits *int64) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(hits, 1)
+
+		if r.URL.Path == "/remote.json" {
+			w.Write([]byte(remoteDoc))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"method":        r.Method,
+			"path":          r.URL.Path,
+			"query":         r.URL.RawQuery,
+			"authorization": r.Header.Get("Authorization"),
+			"items": []map[string]interface{}{
+				{"id": 1, "type": "book", "price": 12.5, "stock": 3},
+				{"id": 2, "type": "pen", "price": 1.25, "stock": 0},
+				{"id": 3, "type": "book", "price": 30, "discount": true},
+			},
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+// writeDocs writes the given documents, by file name, to a temporary directory, and returns its path.
+func writeDocs(t *testing.T, docs map[string]string) string {
+	dir := t.TempDir()
+	for name, doc := range docs {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(doc), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// testClient builds a Client from the document at the given path, making its requests to the given server.
+func testClient(t *testing.T, ts *httptest.Server, path string, opts ...Option) *Client {
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts = append([]Option{WithScheme("http"), WithHost(u.Hostname()), WithPort(port)}, opts...)
+	sc, err := BuildClientWithOptions(path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sc
+}
+
+func TestRefResolution(t *testing.T) {
+	var hits int64
+	ts := echoServer(t, &hits)
+	dir := writeDocs(t, map[string]string{
+		"swagger.json": fmt.Sprintf(usersDoc, ts.URL),
+		"common.json":  commonDoc,
+	})
+
+	sc := testClient(t, ts, filepath.Join(dir, "swagger.json"))
+
+	r := sc.Endpoints["users"]["GetUser"]
+	names := make([]string, 0, len(r.Parameters))
+	for name := range r.Parameters {
+		names = append(names, name)
+	}
+	assert.ElementsMatch(t, []string{"id", "limit", "verbose"}, names)
+	assert.Equal(t, "path", r.Parameters["id"].FoundIn)
+	assert.Equal(t, "query", r.Parameters["limit"].FoundIn)
+	assert.Equal(t, "query", r.Parameters["verbose"].FoundIn)
+	assert.Equal(t, "A user", r.Responses["200"].Description)
+
+	sc.ExecJSON("users.GetUser", map[string]interface{}{"id": 42, "limit": 5}).
+		ExpectStatus(t, http.StatusOK).
+		ExpectValue(t, "path", "/v1/users/42").
+		ExpectValue(t, "query", "limit=5")
+}
+
+func TestRefResolutionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		ref  string
+		docs map[string]string
+		want string
+	}{
+		{"missing file", "missing.json#/parameters/limit", nil, "no such file"},
+		{"missing node", "#/parameters/nothing", nil, "no such node"},
+		{"missing node in file", "common.json#/parameters/nothing", map[string]string{"common.json": commonDoc}, "no such node"},
+		{"circular", "#/parameters/a", nil, "too many levels of $ref"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := `{
+				"swagger": "2.0",
+				"paths": {"/users": {"get": {"operationId": "ListUsers", "parameters": [{"$ref": "` + tt.ref + `"}]}}},
+				"parameters": {"a": {"$ref": "#/parameters/b"}, "b": {"$ref": "#/parameters/a"}}
+			}`
+
+			docs := map[string]string{"swagger.json": doc}
+			for name, d := range tt.docs {
+				docs[name] = d
+			}
+			dir := writeDocs(t, docs)
+
+			_, err := BuildClient(filepath.Join(dir, "swagger.json"))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "Unable to resolve $ref '"+tt.ref+"'")
+				assert.Contains(t, err.Error(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeBasePath(t *testing.T) {
+	var hits int64
+	ts := echoServer(t, &hits)
+	dir := writeDocs(t, map[string]string{
+		"users.json":  `{"swagger": "2.0", "basePath": "/users/", "paths": {"/{id}": {"get": {"operationId": "Get", "tags": ["users"]}}}}`,
+		"orders.json": `{"swagger": "2.0", "basePath": "/orders", "paths": {"/": {"get": {"operationId": "List", "tags": ["orders"]}}}}`,
+		"carts.json":  `{"swagger": "2.0", "basePath": "orders", "paths": {"/carts": {"get": {"operationId": "List", "tags": ["carts"]}}}}`,
+		"dupes.json":  `{"swagger": "2.0", "basePath": "/orders", "paths": {"/all": {"get": {"operationId": "List", "tags": ["orders"]}}}}`,
+	})
+
+	t.Run("different base paths", func(t *testing.T) {
+		sc := testClient(t, ts, filepath.Join(dir, "users.json"))
+		other := testClient(t, ts, filepath.Join(dir, "orders.json"))
+
+		if !assert.NoError(t, sc.Merge(other)) {
+			return
+		}
+
+		assert.Equal(t, "", sc.BasePath)
+		assert.Equal(t, "/users/{id}", sc.Endpoints["users"]["Get"].Path)
+		assert.Equal(t, "/orders/", sc.Endpoints["orders"]["List"].Path)
+
+		// The merged Client is left as it was.
+		assert.Equal(t, "/orders", other.BasePath)
+		assert.Equal(t, "/", other.Endpoints["orders"]["List"].Path)
+
+		sc.ExecJSON("users.Get", map[string]interface{}{"id": 7}).ExpectValue(t, "path", "/users/7")
+		sc.ExecJSON("orders.List", nil).ExpectValue(t, "path", "/orders/")
+	})
+
+	t.Run("same base path", func(t *testing.T) {
+		sc := testClient(t, ts, filepath.Join(dir, "orders.json"))
+		other := testClient(t, ts, filepath.Join(dir, "carts.json"))
+
+		if !assert.NoError(t, sc.Merge(other)) {
+			return
+		}
+
+		assert.Equal(t, "/orders", sc.BasePath)
+		assert.Equal(t, "/carts", sc.Endpoints["carts"]["List"].Path)
+
+		sc.ExecJSON("carts.List", nil).ExpectValue(t, "path", "/orders/carts")
+	})
+
+	t.Run("collision", func(t *testing.T) {
+		sc := testClient(t, ts, filepath.Join(dir, "orders.json"))
+		other := testClient(t, ts, filepath.Join(dir, "dupes.json"))
+
+		err := sc.Merge(other)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "orders.List")
+		}
+		assert.Equal(t, "/", sc.Endpoints["orders"]["List"].Path)
+	})
+}
+
+func TestJSONPathFilters(t *testing.T) {
+	doc, err := gojson.NewJSONReader([]byte(`{"items": [
+		{"id": 1, "type": "book", "price": 12.5, "stock": 3},
+		{"id": 2, "type": "pen", "price": 1.25, "stock": 0},
+		{"id": 3, "type": "book", "price": 30, "discount": true, "note": null}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"items.*.id", []string{"items.0.id", "items.1.id", "items.2.id"}},
+		{"items[?(@.type=='book')].price", []string{"items.0.price", "items.2.price"}},
+		{`items[?(@.type != "book")].id`, []string{"items.1.id"}},
+		{"items[?(@.price > 10)].id", []string{"items.0.id", "items.2.id"}},
+		{"items[?(@.price <= 12.5)].id", []string{"items.0.id", "items.1.id"}},
+		{"items[?(@.stock >= 1)].id", []string{"items.0.id"}},
+		{"items[?(@.stock < 1)].id", []string{"items.1.id"}},
+		{"items[?(@.stock)].id", []string{"items.0.id", "items.1.id"}},
+		{"items[?(@.discount == true)].id", []string{"items.2.id"}},
+		{"items[?(@.note == null)].id", []string{"items.2.id"}},
+		{"items[?(@.stock != 0)].id", []string{"items.0.id", "items.2.id"}},
+		{"items[?(@.type=='magazine')].id", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			keys, err := expandKey(doc, tt.key)
+			if assert.NoError(t, err) {
+				assert.Equal(t, len(tt.want), len(keys))
+				if len(tt.want) > 0 {
+					assert.Equal(t, tt.want, keys)
+				}
+			}
+		})
+	}
+
+	t.Run("invalid filter", func(t *testing.T) {
+		_, err := expandKey(doc, "items[?(@.price > cheap)].id")
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "invalid literal")
+		}
+	})
+
+	t.Run("assertions", func(t *testing.T) {
+		var hits int64
+		ts := echoServer(t, &hits)
+		dir := writeDocs(t, map[string]string{"swagger.json": fmt.Sprintf(usersDoc, ts.URL), "common.json": commonDoc})
+		sc := testClient(t, ts, filepath.Join(dir, "swagger.json"))
+
+		sc.ExecJSON("users.GetUser", map[string]interface{}{"id": 1}).
+			ExpectValue(t, "items[?(@.type=='book')].type", "book").
+			ExpectValueGreaterThan(t, "items[?(@.type=='book')].price", 10).
+			ExpectValue(t, "items[?(@.stock > 0)].id", 1).
+			ExpectType(t, "items.*.price", "number")
+	})
+}
+
+func TestConcurrency(t *testing.T) {
+	var hits int64
+	ts := echoServer(t, &hits)
+	dir := writeDocs(t, map[string]string{"swagger.json": fmt.Sprintf(usersDoc, ts.URL), "common.json": commonDoc})
+	sc := testClient(t, ts, filepath.Join(dir, "swagger.json"))
+
+	var beforeAll, beforeEach int64
+	sc.BeforeAll(func(c *Client) error {
+		atomic.AddInt64(&beforeAll, 1)
+		c.SetVariable("token", "Bearer shared")
+		return nil
+	})
+	sc.BeforeEach(func(c *Client) error {
+		atomic.AddInt64(&beforeEach, 1)
+		return nil
+	})
+
+	t.Run("ExecConcurrent", func(t *testing.T) {
+		start := atomic.LoadInt64(&hits)
+
+		stats := sc.ExecConcurrent("users.GetUser", map[string]interface{}{"id": 1}, 50, 8)
+
+		assert.Equal(t, 50, stats.Requests)
+		assert.Equal(t, 0, stats.Errors)
+		assert.Equal(t, 50, stats.StatusCodes[http.StatusOK])
+		assert.Equal(t, int64(50), atomic.LoadInt64(&hits)-start)
+		assert.Equal(t, int64(1), atomic.LoadInt64(&beforeAll))
+		assert.Equal(t, int64(50), atomic.LoadInt64(&beforeEach))
+	})
+
+	t.Run("Clone", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c := sc.Clone()
+				c.SetVariable("i", i)
+				c.ExecJSON("users.GetUser", map[string]interface{}{"id": Variable("i")}).ExpectStatus(t, http.StatusOK)
+			}()
+		}
+		wg.Wait()
+
+		_, isset := sc.GetVariable("i")
+		assert.False(t, isset, "variables set on clones must not be set on the original")
+	})
+
+	t.Run("parallel", func(t *testing.T) {
+		for i := 0; i < 8; i++ {
+			t.Run(fmt.Sprintf("user %d", i), func(t *testing.T) {
+				t.Parallel()
+
+				c := sc.Clone()
+				c.Authorization = fmt.Sprintf("Bearer %d", i)
+				c.SetVariable("id", i)
+
+				c.ExecJSON("users.GetUser", map[string]interface{}{"id": Variable("id"), "limit": i}).
+					ExpectStatus(t, http.StatusOK).
+					ExpectValue(t, "authorization", c.Authorization).
+					ExpectValue(t, "path", fmt.Sprintf("/v1/users/%d", i)).
+					ExpectValue(t, "query", fmt.Sprintf("limit=%d", i))
+			})
+		}
+	})
+
+	assert.Equal(t, "", sc.Authorization)
+	if token, isset := sc.GetVariable("token"); assert.True(t, isset) {
+		assert.Equal(t, "Bearer shared", token)
+	}
+}
+
+// TestCloneCopiesEveryField guards Clone against fields added to the Client which it would share by mistake: every
+// map and slice of the clone must be a copy, and every lock its own.
+func TestCloneCopiesEveryField(t *testing.T) {
+	var hits int64
+	ts := echoServer(t, &hits)
+	dir := writeDocs(t, map[string]string{"swagger.json": fmt.Sprintf(usersDoc, ts.URL), "common.json": commonDoc})
+	sc := testClient(t, ts, filepath.Join(dir, "swagger.json"))
+	sc.SetVariable("id", 1)
+	sc.BeforeEach(func(c *Client) error { return nil })
+
+	c := sc.Clone()
+
+	orig, clone := reflect.ValueOf(sc).Elem(), reflect.ValueOf(c).Elem()
+	for i := 0; i < orig.NumField(); i++ {
+		name := orig.Type().Field(i).Name
+		a, b := orig.Field(i), clone.Field(i)
+
+		switch a.Kind() {
+		case reflect.Map, reflect.Slice:
+			if a.Len() > 0 && a.Pointer() == b.Pointer() {
+				t.Errorf("Clone shares the %s of the original", name)
+			}
+		case reflect.Ptr:
+			if strings.HasSuffix(a.Type().String(), "Mutex") && a.Pointer() == b.Pointer() {
+				t.Errorf("Clone shares the %s lock of the original", name)
+			}
+		}
+	}
+}
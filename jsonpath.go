@@ -0,0 +1,230 @@
+package gointegration
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/btm6084/gojson"
+	"github.com/stretchr/testify/assert"
+)
+
+// Keys given to JSONResponse assertions may contain wildcards and filters, which address every matching value:
+//
+//	items.*.id                        the id of every element of items
+//	items[?(@.type=='book')].price    the price of every element of items whose type is book
+//	items[?(@.stock)].id              the id of every element of items which has a stock key
+//
+// A wildcard matches every element of an array or every member of an object. Filters compare a key of each
+// element, addressed from @, to a quoted string, number, true, false, or null using ==, !=, >, >=, <, or <=.
+// An assertion on such a key passes only if it passes for every matching value, and fails if nothing matches.
+
+// filterPattern matches a filter expression, such as [?(@.type=='book')].
+var filterPattern = regexp.MustCompile(`^\[\?\(@\.?([^=!<>]*?)\s*(?:(==|!=|>=|<=|>|<)\s*(.+?))?\s*\)\]$`)
+
+// isPatternKey returns true if the given key contains a wildcard or filter.
+func isPatternKey(key string) bool {
+	return strings.Contains(key, "*") || strings.Contains(key, "[?(")
+}
+
+// expandKey returns the concrete keys which the given pattern key matches in the given document, in document order.
+func expandKey(doc *gojson.JSONReader, key string) ([]string, error) {
+	type match struct {
+		key  string
+		node *gojson.JSONReader
+	}
+
+	current := []match{{node: doc}}
+	for _, seg := range splitKey(key) {
+		name, filter := seg, ""
+		if i := strings.Index(seg, "[?("); i >= 0 {
+			name, filter = seg[:i], seg[i:]
+		}
+
+		var next []match
+		for _, m := range current {
+			children := []match{m}
+			if name != "" {
+				children = children[:0]
+				for i, child := range members(m.node) {
+					if name == "*" || m.node.Keys[i] == name {
+						child := child
+						children = append(children, match{key: joinKey(m.key, m.node.Keys[i]), node: &child})
+					}
+				}
+			}
+
+			if filter == "" {
+				next = append(next, children...)
+				continue
+			}
+
+			for _, c := range children {
+				for i, element := range members(c.node) {
+					ok, err := filterMatches(&element, filter)
+					if err != nil {
+						return nil, err
+					}
+					if ok {
+						element := element
+						next = append(next, match{key: joinKey(c.key, c.node.Keys[i]), node: &element})
+					}
+				}
+			}
+		}
+
+		current = next
+	}
+
+	keys := make([]string, len(current))
+	for i, m := range current {
+		keys[i] = m.key
+	}
+
+	return keys, nil
+}
+
+// splitKey splits a key on ".", ignoring any inside a filter.
+func splitKey(key string) []string {
+	var out []string
+	depth := 0
+	start := 0
+
+	for i, r := range key {
+		switch {
+		case r == '[':
+			depth++
+		case r == ']':
+			depth--
+		case r == '.' && depth == 0:
+			out = append(out, key[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(out, key[start:])
+}
+
+// filterMatches returns true if the given element satisfies the given filter expression.
+func filterMatches(element *gojson.JSONReader, filter string) (bool, error) {
+	m := filterPattern.FindStringSubmatch(filter)
+	if m == nil {
+		return false, fmt.Errorf("invalid filter expression `%s`", filter)
+	}
+
+	path, op, literal := m[1], m[2], m[3]
+
+	exists := path == "" || element.KeyExists(path)
+	if op == "" {
+		return exists, nil
+	}
+	if !exists {
+		return op == "!=", nil
+	}
+
+	value := element
+	if path != "" {
+		value = element.Get(path)
+	}
+
+	literal = strings.TrimSpace(literal)
+	switch {
+	case strings.HasPrefix(literal, "'") || strings.HasPrefix(literal, `"`):
+		want := strings.Trim(literal, `'"`)
+		got := value.GetString("")
+		switch op {
+		case "==":
+			return value.Type == gojson.JSONString && got == want, nil
+		case "!=":
+			return value.Type != gojson.JSONString || got != want, nil
+		}
+		return compare(op, strings.Compare(got, want)), nil
+	case literal == "true" || literal == "false":
+		equal := value.Type == gojson.JSONBool && value.GetBool("") == (literal == "true")
+		return (op == "==") == equal, nil
+	case literal == "null":
+		equal := value.Type == gojson.JSONNull
+		return (op == "==") == equal, nil
+	}
+
+	want, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid literal `%s` in filter expression `%s`", literal, filter)
+	}
+
+	if value.Type != gojson.JSONInt && value.Type != gojson.JSONFloat {
+		return op == "!=", nil
+	}
+
+	got := value.GetFloat("")
+	switch {
+	case got < want:
+		return compare(op, -1), nil
+	case got > want:
+		return compare(op, 1), nil
+	}
+
+	return compare(op, 0), nil
+}
+
+// compare returns true if a comparison result, as returned by strings.Compare, satisfies the given operator.
+func compare(op string, result int) bool {
+	switch op {
+	case "==":
+		return result == 0
+	case "!=":
+		return result != 0
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	}
+
+	return false
+}
+
+// each runs the given assertion against every concrete key matched by the given key, if it is a pattern key. It
+// returns false, having done nothing, if the key is not a pattern key, in which case the caller asserts on it directly.
+func (c JSONResponse) each(t *testing.T, key string, assertion func(key string)) bool {
+	if !isPatternKey(key) {
+		return false
+	}
+
+	keys, err := expandKey(c.Reader, key)
+	if err != nil {
+		assert.Fail(c.reporter(t), err.Error())
+		return true
+	}
+
+	if len(keys) == 0 {
+		assert.Fail(c.reporter(t), fmt.Sprintf("no values match key `%s`", key))
+		return true
+	}
+
+	for _, k := range keys {
+		// A key which itself contains a wildcard can't be addressed, and would otherwise be expanded again.
+		if isPatternKey(k) {
+			assert.Fail(c.reporter(t), fmt.Sprintf("key `%s` matched by `%s` can't be addressed", k, key))
+			continue
+		}
+		assertion(k)
+	}
+
+	return true
+}
+
+// exists returns true if the given key exists, or if it is a pattern key, if it matches anything.
+func (c JSONResponse) exists(key string) bool {
+	if !isPatternKey(key) {
+		return c.Reader.KeyExists(key)
+	}
+
+	keys, _ := expandKey(c.Reader, key)
+	return len(keys) > 0
+}
@@ -0,0 +1,231 @@
+package gointegration
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// LoadStats aggregates the responses to the requests made by ExecConcurrent. Errors is the number of requests which
+// failed to be made, such as by connection errors or timeouts, and Err is the first of them. ErrorRate is the
+// fraction of requests which failed to be made or received a 5xx status. StatusCodes counts the responses received
+// by status code. Elapsed is the time taken to make every request, and Throughput the requests made per second.
+//
+// The latencies are the RequestDuration of each response received, leaving out requests which failed to be made.
+// P50, P95, and P99 are their percentiles, by the nearest rank.
+type LoadStats struct {
+	Requests    int
+	Errors      int
+	ErrorRate   float64
+	StatusCodes map[int]int
+	Elapsed     time.Duration
+	Throughput  float64
+	Err         error
+
+	Min  time.Duration
+	Mean time.Duration
+	Max  time.Duration
+	P50  time.Duration
+	P95  time.Duration
+	P99  time.Duration
+
+	// latencies holds the latencies in ascending order.
+	latencies []time.Duration
+}
+
+// ExecConcurrent makes n requests to the operation at the given path specifier, each with the given params, from a
+// pool of the given number of workers, and returns their aggregate stats, such as for a smoke load test of an
+// endpoint. The params are shared by every request, so must not hold values which can only be sent once, such as an
+// io.Reader. A rate limit set on the Client applies, as does its retrying, and each request runs the hooks of the
+// Client, as Exec does. No requests are made if n is less than 1.
+func (sc *Client) ExecConcurrent(specifier string, params map[string]interface{}, n, concurrency int) LoadStats {
+	if n < 0 {
+		n = 0
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > n {
+		concurrency = n
+	}
+
+	results := make([]ClientResponse, n)
+	var next int64 = -1
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := atomic.AddInt64(&next, 1)
+				if i >= int64(n) {
+					return
+				}
+
+				results[i] = loadResult(sc.Exec(specifier, params))
+			}
+		}()
+	}
+	wg.Wait()
+
+	return loadStats(results, time.Since(start))
+}
+
+// loadResult returns only what the stats of a request need of the given response, as the bodies of many responses
+// may be large.
+func loadResult(resp ClientResponse) ClientResponse {
+	return ClientResponse{Error: resp.Error, StatusCode: resp.StatusCode, RequestDuration: resp.RequestDuration}
+}
+
+// loadStats aggregates the given responses, which took the given time to receive.
+func loadStats(results []ClientResponse, elapsed time.Duration) LoadStats {
+	stats := LoadStats{Requests: len(results), StatusCodes: map[int]int{}, Elapsed: elapsed}
+	if elapsed > 0 {
+		stats.Throughput = float64(len(results)) / elapsed.Seconds()
+	}
+
+	failed := 0
+	var total time.Duration
+	for _, resp := range results {
+		if resp.Error != nil {
+			if stats.Err == nil {
+				stats.Err = resp.Error
+			}
+			stats.Errors++
+			failed++
+			continue
+		}
+
+		stats.StatusCodes[resp.StatusCode]++
+		if resp.StatusCode >= http.StatusInternalServerError {
+			failed++
+		}
+
+		stats.latencies = append(stats.latencies, resp.RequestDuration)
+		total += resp.RequestDuration
+	}
+
+	if len(results) > 0 {
+		stats.ErrorRate = float64(failed) / float64(len(results))
+	}
+
+	sort.Slice(stats.latencies, func(i, j int) bool { return stats.latencies[i] < stats.latencies[j] })
+	if len(stats.latencies) > 0 {
+		stats.Min = stats.latencies[0]
+		stats.Max = stats.latencies[len(stats.latencies)-1]
+		stats.Mean = total / time.Duration(len(stats.latencies))
+	}
+	stats.P50 = stats.Percentile(50)
+	stats.P95 = stats.Percentile(95)
+	stats.P99 = stats.Percentile(99)
+
+	return stats
+}
+
+// Percentile returns the given percentile of the latencies, from 0 to 100, by the nearest rank. It is 0 if no
+// responses were received.
+func (s LoadStats) Percentile(p float64) time.Duration {
+	if len(s.latencies) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(s.latencies))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(s.latencies) {
+		rank = len(s.latencies)
+	}
+
+	return s.latencies[rank-1]
+}
+
+// String summarizes the stats on a single line, such as for logging.
+func (s LoadStats) String() string {
+	return fmt.Sprintf("%d requests in %s (%.1f/s), %.1f%% errors, p50 %s, p95 %s, p99 %s, max %s",
+		s.Requests, s.Elapsed.Round(time.Millisecond), s.Throughput, s.ErrorRate*100, s.P50, s.P95, s.P99, s.Max)
+}
+
+// ExpectP50Under asserts that the median latency is under the given limit.
+func (s LoadStats) ExpectP50Under(t *testing.T, limit time.Duration) LoadStats {
+	return s.expectPercentileUnder(t, "p50", s.P50, limit)
+}
+
+// ExpectP95Under asserts that the 95th percentile latency is under the given limit.
+func (s LoadStats) ExpectP95Under(t *testing.T, limit time.Duration) LoadStats {
+	return s.expectPercentileUnder(t, "p95", s.P95, limit)
+}
+
+// ExpectP99Under asserts that the 99th percentile latency is under the given limit.
+func (s LoadStats) ExpectP99Under(t *testing.T, limit time.Duration) LoadStats {
+	return s.expectPercentileUnder(t, "p99", s.P99, limit)
+}
+
+// expectPercentileUnder asserts that the given percentile latency is under the given limit.
+func (s LoadStats) expectPercentileUnder(t *testing.T, name string, actual, limit time.Duration) LoadStats {
+	if len(s.latencies) == 0 {
+		assert.Fail(t, fmt.Sprintf("expected %s latency under %s, got no responses: %s", name, limit, s))
+		return s
+	}
+
+	assert.True(t, actual < limit, fmt.Sprintf("expected %s latency under %s, got %s: %s", name, limit, actual, s))
+
+	return s
+}
+
+// ExpectErrorRateUnder asserts that the fraction of requests which failed to be made or received a 5xx status is
+// under the given rate, such as 0.01 for 1%.
+func (s LoadStats) ExpectErrorRateUnder(t *testing.T, rate float64) LoadStats {
+	assert.True(t, s.ErrorRate < rate, fmt.Sprintf("expected error rate under %.2f%%, got %.2f%%: %s", rate*100, s.ErrorRate*100, s.failures()))
+
+	return s
+}
+
+// ExpectNoErrors asserts that every request was made and received a status under 500.
+func (s LoadStats) ExpectNoErrors(t *testing.T) LoadStats {
+	assert.True(t, s.ErrorRate == 0, fmt.Sprintf("expected no errors, got %.2f%%: %s", s.ErrorRate*100, s.failures()))
+
+	return s
+}
+
+// ExpectAllStatus asserts that every request received the given status.
+func (s LoadStats) ExpectAllStatus(t *testing.T, status int) LoadStats {
+	assert.True(t, s.StatusCodes[status] == s.Requests, fmt.Sprintf("expected status %d for all %d requests, got %s", status, s.Requests, s.failures()))
+
+	return s
+}
+
+// failures describes the statuses received, and the first error, if any.
+func (s LoadStats) failures() string {
+	codes := make([]int, 0, len(s.StatusCodes))
+	for code := range s.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	counts := make([]string, len(codes))
+	for i, code := range codes {
+		counts[i] = fmt.Sprintf("%d: %d", code, s.StatusCodes[code])
+	}
+
+	out := "no statuses"
+	if len(counts) > 0 {
+		out = "statuses " + strings.Join(counts, ", ")
+	}
+
+	if s.Err != nil {
+		out += fmt.Sprintf(", %d errors, first: %s", s.Errors, s.Err.Error())
+	}
+
+	return out
+}
@@ -0,0 +1,192 @@
+package gointegration
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LogLevel sets how much of each request a Client logs.
+type LogLevel int
+
+const (
+	// LogSilent logs nothing. It is the default.
+	LogSilent LogLevel = iota
+
+	// LogInfo logs the method, URL, status, and time taken by each request.
+	LogInfo
+
+	// LogDebug additionally logs the headers and bodies of each request and response.
+	LogDebug
+)
+
+// maxLoggedBody is the number of bytes of each request and response body logged at LogDebug.
+const maxLoggedBody = 4096
+
+// redactedHeaders are headers which carry secrets, and whose values are never logged.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// String returns the name of the log level, as accepted by ParseLogLevel.
+func (l LogLevel) String() string {
+	switch l {
+	case LogSilent:
+		return "silent"
+	case LogInfo:
+		return "info"
+	case LogDebug:
+		return "debug"
+	}
+
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
+
+// ParseLogLevel returns the log level with the given name: silent, info, or debug.
+func ParseLogLevel(name string) (LogLevel, error) {
+	for _, l := range []LogLevel{LogSilent, LogInfo, LogDebug} {
+		if strings.EqualFold(name, l.String()) {
+			return l, nil
+		}
+	}
+
+	return LogSilent, fmt.Errorf("ParseLogLevel: Unknown log level %s", name)
+}
+
+// WithLogLevel sets how much of each request is logged. Refer to LogLevel.
+func WithLogLevel(level LogLevel) Option {
+	return func(sc *Client) error {
+		sc.LogLevel = level
+		return nil
+	}
+}
+
+// WithLogger sets the function log lines are written with, such as t.Logf, and how much of each request is logged.
+func WithLogger(level LogLevel, logf func(format string, args ...interface{})) Option {
+	return func(sc *Client) error {
+		sc.LogLevel = level
+		sc.Logf = logf
+		return nil
+	}
+}
+
+// logRequest logs the given request, started at the given time, and its response, according to the LogLevel.
+func (sc *Client) logRequest(req *http.Request, resp ClientResponse, start time.Time) {
+	if sc.LogLevel <= LogSilent {
+		return
+	}
+
+	logf := sc.Logf
+	if logf == nil {
+		logf = log.Printf
+	}
+
+	// The URL is logged with the API keys in its query redacted, including where errors repeat it.
+	u := redactURL(req.URL, sc.secretQuery())
+	if resp.Error != nil {
+		msg := strings.Replace(resp.Error.Error(), req.URL.String(), u, -1)
+		logf("%s %s failed after %s: %s", req.Method, u, time.Since(start), msg)
+	} else {
+		logf("%s %s %d %s in %s", req.Method, u, resp.StatusCode, http.StatusText(resp.StatusCode), resp.RequestDuration)
+	}
+
+	if sc.LogLevel < LogDebug {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("Request:\n")
+	writeLogHeaders(&b, sc.secretHeaders(), req.Header)
+	writeLogBody(&b, requestBody(req))
+
+	if resp.Error == nil {
+		b.WriteString("Response:\n")
+		writeLogHeaders(&b, sc.secretHeaders(), resp.HeaderValues)
+		writeLogBody(&b, resp.Body)
+	}
+
+	logf("%s", strings.TrimRight(b.String(), "\n"))
+}
+
+// secretHeaders returns the names of the headers whose values are redacted when logged: the redactedHeaders, and
+// those holding API keys for the SecuritySchemes.
+func (sc *Client) secretHeaders() map[string]bool {
+	out := make(map[string]bool, len(redactedHeaders))
+	for _, h := range redactedHeaders {
+		out[http.CanonicalHeaderKey(h)] = true
+	}
+
+	for _, scheme := range sc.SecuritySchemes {
+		if scheme.Type == "apiKey" && scheme.In == "header" {
+			out[http.CanonicalHeaderKey(scheme.Name)] = true
+		}
+	}
+
+	return out
+}
+
+// secretQuery returns the names of the query params whose values are redacted when logged: those holding API keys for
+// the SecuritySchemes.
+func (sc *Client) secretQuery() map[string]bool {
+	out := make(map[string]bool)
+	for _, scheme := range sc.SecuritySchemes {
+		if scheme.Type == "apiKey" && scheme.In == "query" {
+			out[scheme.Name] = true
+		}
+	}
+
+	return out
+}
+
+// redactURL returns the given URL with the values of the given secret query params redacted. The rest of the query
+// is kept as sent, in its order and escaping.
+func redactURL(u *url.URL, secret map[string]bool) string {
+	if len(secret) == 0 || u.RawQuery == "" {
+		return u.String()
+	}
+
+	pairs := strings.Split(u.RawQuery, "&")
+	for i, pair := range pairs {
+		key := strings.SplitN(pair, "=", 2)[0]
+		if name, err := url.QueryUnescape(key); err == nil && secret[name] {
+			pairs[i] = key + "=<redacted>"
+		}
+	}
+
+	redacted := *u
+	redacted.RawQuery = strings.Join(pairs, "&")
+	return redacted.String()
+}
+
+// writeLogHeaders writes the given headers, sorted by name, with the values of secret headers redacted.
+func writeLogHeaders(b *strings.Builder, secret map[string]bool, header map[string][]string) {
+	names := make([]string, 0, len(header))
+	for k := range header {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		for _, v := range header[k] {
+			if secret[http.CanonicalHeaderKey(k)] {
+				v = "<redacted>"
+			}
+			fmt.Fprintf(b, "  %s: %s\n", k, v)
+		}
+	}
+}
+
+// writeLogBody writes the given body, truncated to maxLoggedBody bytes.
+func writeLogBody(b *strings.Builder, body string) {
+	if body == "" {
+		return
+	}
+
+	if len(body) > maxLoggedBody {
+		body = fmt.Sprintf("%s... (%d bytes truncated)", body[:maxLoggedBody], len(body)-maxLoggedBody)
+	}
+
+	fmt.Fprintf(b, "\n%s\n", body)
+}
@@ -0,0 +1,33 @@
+package gointegration
+
+// Matcher is a reusable check on a single value, used with ExpectThat. Match returns an error describing why the
+// value doesn't match, or nil if it does. Describe returns what a matching value looks like, in a form that reads
+// after "to be", such as "an ISO 8601 date" or "a positive amount of money".
+//
+// Values are given to Match as returned by gojson's GetInterface: strings, bools, nil, int or float64 numbers,
+// and []interface{} or map[string]interface{} for arrays and objects.
+type Matcher interface {
+	Match(value interface{}) error
+	Describe() string
+}
+
+// NewMatcher returns a Matcher with the given description, which matches values for which match returns nil.
+func NewMatcher(description string, match func(value interface{}) error) Matcher {
+	return funcMatcher{description: description, match: match}
+}
+
+// funcMatcher is a Matcher built from a function, as returned by NewMatcher.
+type funcMatcher struct {
+	description string
+	match       func(value interface{}) error
+}
+
+// Match implements Matcher.
+func (m funcMatcher) Match(value interface{}) error {
+	return m.match(value)
+}
+
+// Describe implements Matcher.
+func (m funcMatcher) Describe() string {
+	return m.description
+}
@@ -0,0 +1,121 @@
+package gointegration
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// BuildClientFromPaths creates a new Client from several swagger documents on the filesystem, such as those of each
+// service behind a gateway, so that one Client can exercise all of them. Refer to Merge for how documents are combined.
+func BuildClientFromPaths(paths []string) (*Client, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("BuildClientFromPaths: no paths given")
+	}
+
+	sc, err := BuildClient(paths[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths[1:] {
+		other, err := BuildClient(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := sc.Merge(other); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err.Error())
+		}
+	}
+
+	return sc, nil
+}
+
+// Merge adds the routes and security schemes loaded by other to this Client. An operation that is defined by both
+// Clients under the same tag and operationId is a collision, and causes an error without modifying this Client.
+// If the Clients have different base paths, each route's base path is moved into its own path.
+func (sc *Client) Merge(other *Client) error {
+	var collisions []string
+	for tag, endpoints := range other.Endpoints {
+		for id := range endpoints {
+			if _, isset := sc.Endpoints[tag][id]; isset {
+				collisions = append(collisions, fmt.Sprintf("%s.%s", tag, id))
+			}
+		}
+	}
+
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+		return fmt.Errorf("Merge: operations defined more than once: %s", strings.Join(collisions, ", "))
+	}
+
+	if strings.Trim(sc.BasePath, "/") != strings.Trim(other.BasePath, "/") {
+		sc.Endpoints = withBasePath(sc.Endpoints, sc.BasePath)
+		sc.BasePath = ""
+
+		other = &Client{Endpoints: withBasePath(other.Endpoints, other.BasePath), SecuritySchemes: other.SecuritySchemes, Servers: other.Servers}
+	}
+
+	if sc.Endpoints == nil {
+		sc.Endpoints = make(map[string]Endpoints)
+	}
+
+	for tag, endpoints := range other.Endpoints {
+		if sc.Endpoints[tag] == nil {
+			sc.Endpoints[tag] = make(Endpoints)
+		}
+
+		for id, r := range endpoints {
+			sc.Endpoints[tag][id] = r
+		}
+	}
+
+	if sc.SecuritySchemes == nil {
+		sc.SecuritySchemes = make(map[string]SecurityScheme)
+	}
+
+	for name, scheme := range other.SecuritySchemes {
+		if _, isset := sc.SecuritySchemes[name]; !isset {
+			sc.SecuritySchemes[name] = scheme
+		}
+	}
+
+	for _, server := range other.Servers {
+		if !stringInSlice(server, sc.Servers) {
+			sc.Servers = append(sc.Servers, server)
+		}
+	}
+
+	return nil
+}
+
+// withBasePath returns a copy of the given endpoints with the given base path prepended to each route's path.
+func withBasePath(in map[string]Endpoints, basePath string) map[string]Endpoints {
+	base := strings.Trim(basePath, "/")
+
+	out := make(map[string]Endpoints, len(in))
+	for tag, endpoints := range in {
+		out[tag] = make(Endpoints, len(endpoints))
+
+		for id, r := range endpoints {
+			if base != "" {
+				r.Path = "/" + base + "/" + strings.TrimLeft(r.Path, "/")
+			}
+			out[tag][id] = r
+		}
+	}
+
+	return out
+}
+
+// stringInSlice returns true if the given string exists in the given slice.
+func stringInSlice(needle string, haystack []string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,270 @@
+package gointegration
+
+import (
+	"bytes"
+	"context"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricBuckets are the upper bounds, in seconds, of the buckets of the request latency histograms kept by Metrics.
+// Each Metrics keeps a copy of them as they are when it is created by NewMetrics, so changing them afterwards only
+// affects the Metrics created later.
+var MetricBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics counts the requests made by the Clients it is attached to with RecordMetrics, by operation, method, and
+// status, and keeps a histogram of their latencies by operation, for graphing the behavior of the service over a
+// long-running or soak suite. Requests for operations in the swagger doc are labelled with their path specifier,
+// and those made by ExecRaw with "raw". Requests which fail without a response have the status "error". Retried
+// requests are counted once, with the latency of their final attempt.
+//
+// Metrics can be scraped by Prometheus from Handler, pushed to a Prometheus Pushgateway by Push, or published with
+// expvar by Publish.
+type Metrics struct {
+	mu        sync.Mutex
+	buckets   []float64
+	requests  map[metricKey]int
+	latencies map[string]*histogram
+
+	// client pushes the metrics. It is the http.Client of the Client the Metrics was last attached to.
+	client *http.Client
+}
+
+// metricKey labels a count of requests.
+type metricKey struct {
+	operation string
+	method    string
+	status    string
+}
+
+// histogram counts observations into the buckets of its Metrics. Counts are not cumulative.
+type histogram struct {
+	counts []int
+	count  int
+	sum    float64
+}
+
+// pushTimeout is how long Push waits for the Pushgateway to accept the metrics.
+const pushTimeout = 30 * time.Second
+
+// NewMetrics returns an empty Metrics, with histograms bucketed by the MetricBuckets.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		buckets:   append([]float64(nil), MetricBuckets...),
+		requests:  make(map[metricKey]int),
+		latencies: make(map[string]*histogram),
+	}
+}
+
+// RecordMetrics attaches the given Metrics to the Client, or detaches it, if nil. Clones of the Client share its
+// Metrics.
+func (sc *Client) RecordMetrics(m *Metrics) *Client {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.metrics = m
+	if m != nil {
+		m.mu.Lock()
+		m.client = sc.Client
+		m.mu.Unlock()
+	}
+
+	return sc
+}
+
+// currentMetrics returns the Metrics attached to the Client, if any.
+func (sc *Client) currentMetrics() *Metrics {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	return sc.metrics
+}
+
+// record counts the given request and its response.
+func (m *Metrics) record(req *http.Request, resp ClientResponse) {
+	key := metricKey{operation: "raw", method: req.Method, status: strconv.Itoa(resp.StatusCode)}
+	if spec, isset := req.Context().Value(specifierKey{}).(string); isset {
+		key.operation = spec
+	}
+	if resp.Error != nil {
+		key.status = "error"
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[key]++
+
+	if resp.Error != nil {
+		return
+	}
+
+	h, isset := m.latencies[key.operation]
+	if !isset {
+		h = &histogram{counts: make([]int, len(m.buckets))}
+		m.latencies[key.operation] = h
+	}
+
+	seconds := resp.RequestDuration.Seconds()
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format, as the counter
+// gointegration_requests_total and the histogram gointegration_request_duration_seconds.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b bytes.Buffer
+
+	b.WriteString("# HELP gointegration_requests_total Requests made, by operation, method, and status.\n")
+	b.WriteString("# TYPE gointegration_requests_total counter\n")
+
+	keys := make([]metricKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+
+	for _, k := range keys {
+		fmt.Fprintf(&b, "gointegration_requests_total{operation=%s,method=%s,status=%s} %d\n",
+			labelValue(k.operation), labelValue(k.method), labelValue(k.status), m.requests[k])
+	}
+
+	b.WriteString("# HELP gointegration_request_duration_seconds Latency of responses, by operation.\n")
+	b.WriteString("# TYPE gointegration_request_duration_seconds histogram\n")
+
+	for _, op := range m.operations() {
+		h := m.latencies[op]
+
+		cumulative := 0
+		for i, bound := range m.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "gointegration_request_duration_seconds_bucket{operation=%s,le=\"%s\"} %d\n",
+				labelValue(op), strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "gointegration_request_duration_seconds_bucket{operation=%s,le=\"+Inf\"} %d\n", labelValue(op), h.count)
+		fmt.Fprintf(&b, "gointegration_request_duration_seconds_sum{operation=%s} %s\n", labelValue(op), strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "gointegration_request_duration_seconds_count{operation=%s} %d\n", labelValue(op), h.count)
+	}
+
+	_, err := w.Write(b.Bytes())
+
+	return err
+}
+
+// operations returns the operations with latencies, sorted. The caller must hold the lock.
+func (m *Metrics) operations() []string {
+	ops := make([]string, 0, len(m.latencies))
+	for op := range m.latencies {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	return ops
+}
+
+// labelValue returns the given label value quoted and escaped for the Prometheus text exposition format.
+func labelValue(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// Handler returns an http.Handler serving the metrics for Prometheus to scrape.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		m.WritePrometheus(w)
+	})
+}
+
+// Push pushes the metrics to the Prometheus Pushgateway at the given URL, such as http://pushgateway:9091, under
+// the given job name, replacing any metrics previously pushed for the job. The metrics are pushed with the http.Client
+// of the Client they were last attached to, so with its transport and TLS configuration, and fail if the Pushgateway
+// hasn't responded within 30 seconds.
+func (m *Metrics) Push(gatewayURL, job string) error {
+	var b bytes.Buffer
+	if err := m.WritePrometheus(&b); err != nil {
+		return fmt.Errorf("Push: %s", err.Error())
+	}
+
+	u := strings.TrimRight(gatewayURL, "/") + "/metrics/job/" + url.PathEscape(job)
+
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, &b)
+	if err != nil {
+		return fmt.Errorf("Push: %s", err.Error())
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	m.mu.Lock()
+	client := m.client
+	m.mu.Unlock()
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Push: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("Push: Pushgateway responded with status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// Publish publishes the metrics with expvar under the given name, so that they are served at /debug/vars. As with
+// expvar.Publish, it panics if the name is already in use.
+func (m *Metrics) Publish(name string) {
+	expvar.Publish(name, expvar.Func(m.snapshot))
+}
+
+// snapshot returns the metrics as a value for expvar: the requests keyed by "operation method status", and the
+// latencies keyed by operation, with cumulative bucket counts as in the Prometheus histogram.
+func (m *Metrics) snapshot() interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	requests := make(map[string]int, len(m.requests))
+	for k, n := range m.requests {
+		requests[k.operation+" "+k.method+" "+k.status] = n
+	}
+
+	latencies := make(map[string]interface{}, len(m.latencies))
+	for _, op := range m.operations() {
+		h := m.latencies[op]
+
+		buckets := make(map[string]int, len(m.buckets))
+		cumulative := 0
+		for i, bound := range m.buckets {
+			cumulative += h.counts[i]
+			buckets[strconv.FormatFloat(bound, 'g', -1, 64)] = cumulative
+		}
+
+		latencies[op] = map[string]interface{}{"count": h.count, "sum": h.sum, "buckets": buckets}
+	}
+
+	return map[string]interface{}{"requests": requests, "latencies": latencies}
+}
@@ -0,0 +1,104 @@
+package gointegration
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RoundTripFunc makes a single HTTP request and returns its response, in the same manner as http.Client.Do.
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps a RoundTripFunc, returning a RoundTripFunc which may inspect or modify the request before
+// calling next, and the response after.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// Use adds the given middlewares to the Client. They are applied to every request made by the Client, including
+// each retry, in the order they were added: the first middleware added is the first to see the request.
+func (sc *Client) Use(mw ...Middleware) *Client {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.middleware = append(sc.middleware, mw...)
+
+	return sc
+}
+
+// attemptKey is the context key under which doRequest shares an attempt with the innermost round trip of a request.
+type attemptKey struct{}
+
+// attempt is what the innermost round trip of a request reports back to doRequest: whether the response was served
+// from the cache, when the request was sent, once it had waited for the rate limit, and the trace of its timings.
+type attempt struct {
+	cached bool
+	sent   time.Time
+	trace  *requestTrace
+}
+
+// httpClientKey is the context key under which an http.Client to make a request with, in place of the Client's own,
+// is stored, as set by RequestOptions.HTTPClient.
+type httpClientKey struct{}
+
+// roundTrip returns a RoundTripFunc which makes requests with the Client's http.Client, wrapped in its middlewares.
+func (sc *Client) roundTrip() RoundTripFunc {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	client := sc.Client
+	gzip := sc.GzipRequests
+	cache := sc.cache
+	limiter := sc.rateLimiter
+	next := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		c := client
+		if hc, isset := req.Context().Value(httpClientKey{}).(*http.Client); isset {
+			c = hc
+		}
+		at, _ := req.Context().Value(attemptKey{}).(*attempt)
+
+		// The cache is consulted once middlewares have run, so that the headers they set are part of its key.
+		if cache != nil && cacheable(req) {
+			if res, hit := cache.get(req, c.Jar); hit {
+				if at != nil {
+					at.cached = true
+					at.sent = time.Now()
+				}
+				return res, nil
+			}
+		}
+
+		if limiter != nil {
+			if err := limiter.wait(req.Context()); err != nil {
+				return nil, fmt.Errorf("waiting for the rate limit: %w", err)
+			}
+		}
+
+		// The request, as it is given to middlewares and the cache, is kept for the cache to store its response under.
+		sent := req
+
+		// The body is compressed last, so that middlewares see it as it was given.
+		if gzipRequests(req.Context(), gzip) {
+			var err error
+			if sent, err = gzipRequest(req); err != nil {
+				return nil, fmt.Errorf("unable to gzip body: %s", err.Error())
+			}
+		}
+
+		if at != nil {
+			at.sent = time.Now()
+			at.trace = newRequestTrace(at.sent)
+			sent = withTrace(sent, at.trace)
+		}
+
+		res, err := c.Do(sent)
+		if err == nil && cache != nil {
+			res = cache.store(req, c.Jar, res)
+		}
+
+		return res, err
+	})
+	for i := len(sc.middleware) - 1; i >= 0; i-- {
+		next = sc.middleware[i](next)
+	}
+
+	return next
+}
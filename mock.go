@@ -0,0 +1,440 @@
+package gointegration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/btm6084/gojson"
+)
+
+// MockServer serves stub responses for the operations in the swagger doc loaded by a Client, so that tests of a
+// consumer of the service can run against the same doc without the service itself. Each operation responds with
+// its first declared success response, with the declared example as its body, or failing that, a value generated
+// from its schema. Stub overrides the response for an operation.
+//
+// Requests are routed by method and path, with the BasePath of the Client removed. Requests which match no
+// operation get 404 Not Found, or 405 Method Not Allowed if the path matches an operation with another method.
+type MockServer struct {
+	// URL is the base URL of the server, such as http://127.0.0.1:50000, to which the BasePath is appended.
+	URL string
+
+	server   *httptest.Server
+	basePath string
+	routes   []mockRoute
+	rr       *refResolver
+
+	mu    sync.Mutex
+	stubs map[string]mockStub
+}
+
+// mockRoute is an operation served by a MockServer.
+type mockRoute struct {
+	specifier string
+	route     Route
+	pattern   *regexp.Regexp
+	params    int
+}
+
+// mockStub is a response set by Stub.
+type mockStub struct {
+	status int
+	body   interface{}
+}
+
+// pathParam matches a path parameter in a path template, such as {id} in /users/{id}.
+var pathParam = regexp.MustCompile(`\{[^/}]+\}`)
+
+// MockServer starts a MockServer for the operations in the swagger doc loaded by the Client. It must be closed with
+// Close when done.
+func (sc *Client) MockServer() *MockServer {
+	ms := &MockServer{
+		basePath: strings.TrimRight(sc.BasePath, "/"),
+		rr:       sc.resolver,
+		stubs:    make(map[string]mockStub),
+	}
+
+	for tag, routes := range sc.Endpoints {
+		for id, route := range routes {
+			ms.routes = append(ms.routes, mockRoute{
+				specifier: specifier(tag, id),
+				route:     route,
+				pattern:   pathPattern(route.Path),
+				params:    len(pathParam.FindAllString(route.Path, -1)),
+			})
+		}
+	}
+
+	// Literal paths are preferred to templated ones, so that /users/me is matched before /users/{id}.
+	sort.Slice(ms.routes, func(i, j int) bool {
+		if ms.routes[i].params != ms.routes[j].params {
+			return ms.routes[i].params < ms.routes[j].params
+		}
+		return ms.routes[i].specifier < ms.routes[j].specifier
+	})
+
+	ms.server = httptest.NewServer(ms)
+	ms.URL = ms.server.URL
+
+	return ms
+}
+
+// pathPattern returns a pattern matching the paths of the given path template, in which each path parameter
+// matches a single path segment.
+func pathPattern(path string) *regexp.Regexp {
+	literals := pathParam.Split(path, -1)
+	for i := range literals {
+		literals[i] = regexp.QuoteMeta(literals[i])
+	}
+
+	return regexp.MustCompile("^" + strings.Join(literals, "[^/]+") + "$")
+}
+
+// Close shuts down the server.
+func (ms *MockServer) Close() {
+	ms.server.Close()
+}
+
+// Stub sets the response for the operation at the given path specifier, replacing the one generated from the
+// swagger doc. The body is sent as-is if it is a []byte or string, and is otherwise marshalled as JSON.
+func (ms *MockServer) Stub(specifier string, status int, body interface{}) error {
+	found := false
+	for _, r := range ms.routes {
+		if r.specifier == specifier || "default."+r.specifier == specifier {
+			specifier = r.specifier
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("Stub: Route %s not found", specifier)
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.stubs[specifier] = mockStub{status: status, body: body}
+
+	return nil
+}
+
+// Reset removes every response set by Stub.
+func (ms *MockServer) Reset() {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.stubs = make(map[string]mockStub)
+}
+
+// ServeHTTP routes the request to its operation, and writes the operation's response. It implements http.Handler.
+func (ms *MockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	if ms.basePath != "" {
+		if !strings.HasPrefix(path, ms.basePath+"/") && path != ms.basePath {
+			mockError(w, http.StatusNotFound, "no operation matches %s %s", r.Method, r.URL.Path)
+			return
+		}
+		path = "/" + strings.TrimLeft(strings.TrimPrefix(path, ms.basePath), "/")
+	}
+
+	matched := false
+	for _, route := range ms.routes {
+		if !route.pattern.MatchString(path) {
+			continue
+		}
+
+		matched = true
+		if !strings.EqualFold(route.route.Method, r.Method) {
+			continue
+		}
+
+		ms.mu.Lock()
+		stub, isset := ms.stubs[route.specifier]
+		ms.mu.Unlock()
+
+		if isset {
+			writeStub(w, stub)
+			return
+		}
+
+		status, mediaType, body := ms.response(route.route)
+		if mediaType != "" && body != nil {
+			w.Header().Set("Content-Type", mediaType)
+		}
+		w.WriteHeader(status)
+		w.Write(body)
+		return
+	}
+
+	if matched {
+		mockError(w, http.StatusMethodNotAllowed, "no operation matches %s %s", r.Method, r.URL.Path)
+		return
+	}
+
+	mockError(w, http.StatusNotFound, "no operation matches %s %s", r.Method, r.URL.Path)
+}
+
+// writeStub writes the given stub response.
+func writeStub(w http.ResponseWriter, stub mockStub) {
+	var body []byte
+	switch b := stub.body.(type) {
+	case nil:
+	case []byte:
+		body = b
+	case string:
+		body = []byte(b)
+	default:
+		var err error
+		body, err = json.Marshal(b)
+		if err != nil {
+			mockError(w, http.StatusInternalServerError, "Marshal of stub body failed with message: %s", err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+	}
+
+	w.WriteHeader(stub.status)
+	w.Write(body)
+}
+
+// mockError writes an error response with a JSON body describing it.
+func mockError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	body, _ := json.Marshal(map[string]string{"error": fmt.Sprintf(format, args...)})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// response returns the status, media type, and body of the response generated for the given route: its first
+// declared success response, or "default", or failing both, its first declared response. A response without a
+// schema or example has no body.
+func (ms *MockServer) response(route Route) (int, string, []byte) {
+	codes := make([]string, 0, len(route.Responses))
+	for code := range route.Responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	chosen := ""
+	for _, code := range codes {
+		if strings.HasPrefix(code, "2") {
+			chosen = code
+			break
+		}
+	}
+	if chosen == "" {
+		if _, isset := route.Responses["default"]; isset {
+			chosen = "default"
+		} else if len(codes) > 0 {
+			chosen = codes[0]
+		}
+	}
+
+	status, err := strconv.Atoi(strings.Replace(strings.ToUpper(chosen), "XX", "00", 1))
+	if err != nil {
+		status = http.StatusOK
+	}
+
+	spec, isset := route.Responses[chosen]
+	if !isset || status == http.StatusNoContent {
+		return status, "", nil
+	}
+
+	mediaType := mockMediaType(spec, route)
+
+	var value interface{}
+	switch {
+	case spec.examples[mediaType] != nil:
+		value = spec.examples[mediaType].ToInterface()
+	case spec.schemas[mediaType].node != nil:
+		value = ms.mockValue(spec.schemas[mediaType], 0)
+	case spec.schemas[""].node != nil:
+		value = ms.mockValue(spec.schemas[""], 0)
+	default:
+		return status, "", nil
+	}
+
+	if s, isString := value.(string); isString && !strings.Contains(mediaType, "json") {
+		return status, mediaType, []byte(s)
+	}
+
+	body, err := json.Marshal(value)
+	if err != nil {
+		return http.StatusInternalServerError, "", nil
+	}
+
+	return status, mediaType, body
+}
+
+// mockMediaType returns the media type of the given response: application/json if it is produced, or the first
+// media type produced by the response or its route.
+func mockMediaType(spec ResponseSpec, route Route) string {
+	produces := spec.Produces
+	if len(produces) == 0 {
+		produces = route.Produces
+	}
+
+	for _, t := range produces {
+		if t == "application/json" {
+			return t
+		}
+	}
+
+	if len(produces) > 0 {
+		return produces[0]
+	}
+
+	return "application/json"
+}
+
+// mockValue returns a value valid against the given schema, preferring the values it declares, such as its
+// example, default, const, or first enum value.
+func (ms *MockServer) mockValue(schema schemaRef, depth int) interface{} {
+	if depth > maxSchemaDepth {
+		return nil
+	}
+
+	if ms.rr != nil {
+		var err error
+		schema.doc, schema.node, err = ms.rr.resolve(schema.doc, schema.node)
+		if err != nil {
+			return nil
+		}
+	}
+	s := schema.node
+
+	sub := func(node *gojson.JSONReader) schemaRef {
+		return schemaRef{doc: schema.doc, node: node}
+	}
+
+	for _, k := range []string{"example", "default", "const"} {
+		if s.KeyExists(k) {
+			return s.Get(k).ToInterface()
+		}
+	}
+
+	if examples := members(s.Get("examples")); len(examples) > 0 && s.Get("examples").Type == gojson.JSONArray {
+		return examples[0].ToInterface()
+	}
+
+	if enum := members(s.Get("enum")); len(enum) > 0 {
+		return enum[0].ToInterface()
+	}
+
+	// Every schema in allOf applies, so their objects are merged, where only the first schema of anyOf or oneOf is used.
+	if all := members(s.Get("allOf")); len(all) > 0 {
+		merged := map[string]interface{}{}
+		for i := range all {
+			value := ms.mockValue(sub(&all[i]), depth+1)
+			object, isObject := value.(map[string]interface{})
+			if !isObject {
+				return value
+			}
+			for k, v := range object {
+				merged[k] = v
+			}
+		}
+		return merged
+	}
+
+	for _, k := range []string{"anyOf", "oneOf"} {
+		if branches := members(s.Get(k)); len(branches) > 0 {
+			return ms.mockValue(sub(&branches[0]), depth+1)
+		}
+	}
+
+	types := s.GetStringSlice("type")
+	kind := ""
+	for _, t := range types {
+		if t != "null" {
+			kind = t
+			break
+		}
+	}
+	if kind == "" {
+		switch {
+		case s.KeyExists("properties"):
+			kind = "object"
+		case s.KeyExists("items"):
+			kind = "array"
+		case len(types) > 0:
+			return nil
+		}
+	}
+
+	switch kind {
+	case "object":
+		out := map[string]interface{}{}
+		properties := s.Get("properties")
+		for i, property := range members(properties) {
+			out[properties.Keys[i]] = ms.mockValue(sub(&property), depth+1)
+		}
+		return out
+	case "array":
+		count := s.GetInt("minItems")
+		if count < 1 {
+			count = 1
+		}
+		out := make([]interface{}, 0, count)
+		if s.KeyExists("items") && s.Get("items").Type == gojson.JSONObject {
+			item := s.Get("items")
+			for i := 0; i < count; i++ {
+				out = append(out, ms.mockValue(sub(item), depth+1))
+			}
+		}
+		return out
+	case "string":
+		return mockString(s)
+	case "integer":
+		if s.KeyExists("minimum") {
+			return s.GetInt("minimum")
+		}
+		return 0
+	case "number":
+		if s.KeyExists("minimum") {
+			return s.GetFloat("minimum")
+		}
+		return 0
+	case "boolean":
+		return true
+	}
+
+	return nil
+}
+
+// mockFormats holds a valid value for each string format recognized when validating.
+var mockFormats = map[string]string{
+	"date-time": "1970-01-01T00:00:00Z",
+	"date":      "1970-01-01",
+	"time":      "00:00:00Z",
+	"email":     "user@example.com",
+	"uuid":      "00000000-0000-0000-0000-000000000000",
+	"uri":       "https://example.com",
+	"ipv4":      "127.0.0.1",
+	"ipv6":      "::1",
+}
+
+// mockString returns a string valid against the given string schema's format and length. Patterns aren't honored.
+func mockString(s *gojson.JSONReader) string {
+	if value, isset := mockFormats[s.GetString("format")]; isset {
+		return value
+	}
+
+	value := "string"
+	if min := s.GetInt("minLength"); len(value) < min {
+		value += strings.Repeat("x", min-len(value))
+	}
+	if s.KeyExists("maxLength") && len(value) > s.GetInt("maxLength") {
+		value = value[:s.GetInt("maxLength")]
+	}
+
+	return value
+}
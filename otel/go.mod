@@ -0,0 +1,20 @@
+module github.com/btm6084/gointegration/otel
+
+go 1.26.0
+
+require (
+	github.com/btm6084/gointegration v0.0.0-00010101000000-000000000000
+	go.opentelemetry.io/otel v1.46.0
+	go.opentelemetry.io/otel/trace v1.46.0
+)
+
+require (
+	github.com/andybalholm/brotli v1.1.0 // indirect
+	github.com/btm6084/gojson v0.0.0-20190528182933-04c0929b1e82 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/spf13/cast v1.3.0 // indirect
+	github.com/stretchr/testify v1.12.1 // indirect
+	go.yaml.in/yaml/v3 v3.0.5 // indirect
+)
+
+replace github.com/btm6084/gointegration => ../
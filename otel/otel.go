@@ -0,0 +1,102 @@
+// Package otel creates the spans of the requests made by a gointegration Client with OpenTelemetry, so that they
+// can be exported to a tracing backend with the TracerProvider of an OpenTelemetry SDK. It is kept apart from
+// gointegration so that clients which don't export their spans don't depend on OpenTelemetry.
+package otel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/btm6084/gointegration"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the OpenTelemetry tracer spans are created with.
+const tracerName = "github.com/btm6084/gointegration"
+
+// intAttributes are the span attributes recorded as integers, as in the OpenTelemetry semantic conventions.
+var intAttributes = map[string]bool{
+	"http.response.status_code": true,
+	"http.request.resend_count": true,
+}
+
+// traceContext propagates trace contexts in the W3C traceparent and tracestate headers.
+var traceContext = propagation.TraceContext{}
+
+// tracer is a gointegration.Tracer creating spans with an OpenTelemetry TracerProvider.
+type tracer struct {
+	provider trace.TracerProvider
+}
+
+// NewTracer returns a gointegration.Tracer which creates the span of each request with the given OpenTelemetry
+// TracerProvider. A request which already has a traceparent, or whose context has a span, continues that trace, as a
+// child of its span, and is sampled as its parent was.
+func NewTracer(tp trace.TracerProvider) gointegration.Tracer {
+	return tracer{provider: tp}
+}
+
+// WithTracerProvider sets Tracing, creating the span of each request with the given OpenTelemetry TracerProvider,
+// such as one exporting to a tracing backend, or otel.GetTracerProvider() for the global one. It may be given with
+// gointegration.WithTracing, to pass the spans to OnSpan too.
+func WithTracerProvider(tp trace.TracerProvider) gointegration.Option {
+	return func(sc *gointegration.Client) error {
+		if tp == nil {
+			return fmt.Errorf("WithTracerProvider: tracer provider is nil")
+		}
+
+		sc.Tracing = true
+		sc.Tracer = NewTracer(tp)
+		return nil
+	}
+}
+
+// Start starts the OpenTelemetry span of the given request, and sets its trace context in the traceparent and
+// tracestate headers.
+func (t tracer) Start(req *http.Request, span *gointegration.Span) *http.Request {
+	ctx := traceContext.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+	parent := trace.SpanContextFromContext(ctx)
+
+	ctx, s := t.provider.Tracer(tracerName).Start(ctx, span.Name, trace.WithSpanKind(trace.SpanKindClient), trace.WithTimestamp(span.Start))
+	for k, v := range span.Attributes {
+		s.SetAttributes(attribute.String(k, v))
+	}
+
+	traceContext.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	span.TraceID = s.SpanContext().TraceID().String()
+	span.SpanID = s.SpanContext().SpanID().String()
+	span.Sampled = s.SpanContext().IsSampled()
+	if parent.IsValid() {
+		span.ParentSpanID = parent.SpanID().String()
+	}
+
+	return req.WithContext(ctx)
+}
+
+// End ends the OpenTelemetry span in the given context, recording the attributes of the response, and the error of
+// a request which failed or returned an error status.
+func (t tracer) End(ctx context.Context, span gointegration.Span) {
+	s := trace.SpanFromContext(ctx)
+
+	for k, v := range span.Attributes {
+		if n, err := strconv.Atoi(v); err == nil && intAttributes[k] {
+			s.SetAttributes(attribute.Int(k, n))
+			continue
+		}
+		s.SetAttributes(attribute.String(k, v))
+	}
+
+	if span.Error != nil {
+		s.RecordError(span.Error)
+		s.SetStatus(codes.Error, span.Error.Error())
+	} else if _, isset := span.Attributes["error.type"]; isset {
+		s.SetStatus(codes.Error, "")
+	}
+
+	s.End(trace.WithTimestamp(span.End))
+}
@@ -0,0 +1,216 @@
+package gointegration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Pact records the requests made by the Clients it is attached to with RecordPact for operations in the swagger doc,
+// and their responses, as the interactions of a Pact consumer contract (specification v2). This lets integration
+// tests double as contract tests, whose contracts are verified by the provider's own test suite.
+//
+// Each interaction is described by its path specifier and response status, such as "users.Get returning 200".
+// Requests are matched exactly, while response bodies are matched by type, so that a provider returning different
+// data of the same shape passes. Only the Content-Type header is recorded, and requests which fail without a
+// response, or are made by ExecRaw, are not recorded.
+type Pact struct {
+	Consumer string
+	Provider string
+
+	mu           sync.Mutex
+	state        string
+	interactions []pactInteraction
+}
+
+// specifierKey is the context key under which the path specifier of a request made by Exec is stored.
+type specifierKey struct{}
+
+// NewPact returns an empty Pact between the given consumer and provider.
+func NewPact(consumer, provider string) *Pact {
+	return &Pact{Consumer: consumer, Provider: provider}
+}
+
+// RecordPact attaches the given Pact to the Client, or detaches it, if nil. Clones of the Client share its Pact.
+func (sc *Client) RecordPact(pact *Pact) *Client {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.pact = pact
+
+	return sc
+}
+
+// currentPact returns the Pact attached to the Client, if any.
+func (sc *Client) currentPact() *Pact {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	return sc.pact
+}
+
+// Given sets the provider state of the interactions recorded from now on, such as "user 42 exists", which the
+// provider sets up before verifying them. An empty state clears it.
+func (p *Pact) Given(state string) *Pact {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.state = state
+
+	return p
+}
+
+// Len returns the number of interactions recorded so far.
+func (p *Pact) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.interactions)
+}
+
+// pactFile is a Pact contract.
+type pactFile struct {
+	Consumer     pactParticipant   `json:"consumer"`
+	Provider     pactParticipant   `json:"provider"`
+	Interactions []pactInteraction `json:"interactions"`
+	Metadata     struct {
+		PactSpecification struct {
+			Version string `json:"version"`
+		} `json:"pactSpecification"`
+	} `json:"metadata"`
+}
+
+// pactParticipant names the consumer or provider of a Pact.
+type pactParticipant struct {
+	Name string `json:"name"`
+}
+
+// pactInteraction is a single request and its expected response.
+type pactInteraction struct {
+	Description   string       `json:"description"`
+	ProviderState string       `json:"providerState,omitempty"`
+	Request       pactRequest  `json:"request"`
+	Response      pactResponse `json:"response"`
+}
+
+// pactRequest is the request of an interaction.
+type pactRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Query   string            `json:"query,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"`
+}
+
+// pactResponse is the expected response of an interaction.
+type pactResponse struct {
+	Status        int                          `json:"status"`
+	Headers       map[string]string            `json:"headers,omitempty"`
+	Body          interface{}                  `json:"body,omitempty"`
+	MatchingRules map[string]map[string]string `json:"matchingRules,omitempty"`
+}
+
+// record records an interaction for the given request for the operation at the given path specifier, and its
+// response. An interaction identical to one already recorded is not recorded again, and an interaction with the same
+// description and provider state as a different one is numbered, as both must be unique.
+func (p *Pact) record(spec string, req *http.Request, resp ClientResponse) {
+	interaction := pactInteraction{
+		Description: fmt.Sprintf("%s returning %d", spec, resp.StatusCode),
+		Request: pactRequest{
+			Method: req.Method,
+			Path:   req.URL.EscapedPath(),
+			Query:  req.URL.RawQuery,
+		},
+		Response: pactResponse{Status: resp.StatusCode},
+	}
+
+	if ct := req.Header.Get("Content-Type"); ct != "" {
+		interaction.Request.Headers = map[string]string{"Content-Type": ct}
+	}
+	interaction.Request.Body = pactBody(requestBody(req), req.Header.Get("Content-Type"))
+
+	ct := http.Header(resp.HeaderValues).Get("Content-Type")
+	if ct != "" {
+		interaction.Response.Headers = map[string]string{"Content-Type": ct}
+	}
+	interaction.Response.Body = pactBody(resp.Body, ct)
+	if interaction.Response.Body != nil {
+		interaction.Response.MatchingRules = map[string]map[string]string{"$.body": {"match": "type"}}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	interaction.ProviderState = p.state
+	description := interaction.Description
+	for n := 2; ; n++ {
+		duplicate := false
+		for _, existing := range p.interactions {
+			if existing.Description != interaction.Description || existing.ProviderState != interaction.ProviderState {
+				continue
+			}
+			if jsonString(existing) == jsonString(interaction) {
+				return
+			}
+			duplicate = true
+		}
+
+		if !duplicate {
+			break
+		}
+		interaction.Description = fmt.Sprintf("%s (%d)", description, n)
+	}
+
+	p.interactions = append(p.interactions, interaction)
+}
+
+// pactBody returns the given body, with the given Content-Type, as it is given in a Pact: parsed, if it is JSON, and
+// otherwise as a string. An empty body is nil.
+func pactBody(body, contentType string) interface{} {
+	if body == "" {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		var value interface{}
+		if err := json.Unmarshal([]byte(body), &value); err == nil {
+			return value
+		}
+	}
+
+	return body
+}
+
+// WritePact writes the recorded interactions to the given directory as a Pact file, named for the consumer and
+// provider as Pact tooling expects, such as "web-users.json". The directory is created if it doesn't exist.
+func (p *Pact) WritePact(dir string) error {
+	out := pactFile{Consumer: pactParticipant{Name: p.Consumer}, Provider: pactParticipant{Name: p.Provider}}
+	out.Metadata.PactSpecification.Version = "2.0.0"
+
+	p.mu.Lock()
+	out.Interactions = append([]pactInteraction{}, p.interactions...)
+	p.mu.Unlock()
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("WritePact: %s", err.Error())
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("WritePact: %s", err.Error())
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.json", p.Consumer, p.Provider))
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("WritePact: %s", err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,408 @@
+package gointegration
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/btm6084/gojson"
+)
+
+// postmanVariable matches a Postman variable, such as {{baseUrl}}.
+var postmanVariable = regexp.MustCompile(`\{\{([^}]+)\}\}`)
+
+// BuildClientFromPostman creates a new Client from a Postman collection (v2.1) on the filesystem, for teams which
+// maintain Postman collections rather than swagger docs. The environment is consulted as with BuildClient.
+//
+// Each request in the collection becomes a route. Requests within a folder are tagged with the name of the
+// top-level folder, and others with "default". The operation ID is the name of the request with everything but
+// letters and digits removed, so "Get User" becomes GetUser. Path variables, such as :id or {{id}}, become
+// required path parameters, and the query parameters and headers of the request become optional parameters. A
+// request body becomes the parameter named "body", or form parameters for urlencoded and form-data bodies. Saved
+// example responses are declared as the responses of the route. Bearer, basic, and apiKey auth become security
+// schemes of the same names, whose credentials are set with WithAuth.
+//
+// The scheme, host, and port of each request, with any collection variables filled in, are added to Servers, and can
+// be used with UseServer.
+func BuildClientFromPostman(path string) (*Client, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := gojson.NewJSONReader(data)
+	if err != nil {
+		return nil, fmt.Errorf("BuildClientFromPostman: %s", err.Error())
+	}
+
+	if !reader.KeyExists("item") {
+		return nil, fmt.Errorf("BuildClientFromPostman: %s is not a Postman collection", path)
+	}
+
+	sc := newClientFromEnv(nil)
+	sc.resolver = newRefResolver(document{location: path, reader: reader}, sc.Client)
+	sc.Endpoints = make(map[string]Endpoints)
+	sc.SecuritySchemes = make(map[string]SecurityScheme)
+	sc.title = reader.GetString("info.name")
+
+	p := postmanImport{sc: sc, variables: make(map[string]string)}
+	for _, v := range members(reader.Get("variable")) {
+		p.variables[v.GetString("key")] = v.GetString("value")
+	}
+
+	p.items(reader.Get("item"), "default", reader.Get("auth"))
+
+	if len(sc.Servers) > 0 {
+		if u, err := url.Parse(sc.Servers[0]); err == nil {
+			sc.BasePath = u.Path
+		}
+	}
+
+	if err := sc.useEnvProfile(); err != nil {
+		return nil, err
+	}
+
+	return sc, nil
+}
+
+// postmanImport holds the state of a Postman collection being imported.
+type postmanImport struct {
+	sc        *Client
+	variables map[string]string
+}
+
+// items adds routes for each of the given items of a collection, in the given tag, and with the given inherited auth.
+// Folders at the top of the collection name the tag of the requests within them.
+func (p *postmanImport) items(items *gojson.JSONReader, tag string, auth *gojson.JSONReader) {
+	for _, item := range members(items) {
+		itemAuth := auth
+		if item.KeyExists("auth") {
+			itemAuth = item.Get("auth")
+		}
+
+		if item.KeyExists("item") {
+			folderTag := tag
+			if tag == "default" {
+				folderTag = postmanName(item.GetString("name"))
+			}
+			p.items(item.Get("item"), folderTag, itemAuth)
+			continue
+		}
+
+		if item.KeyExists("request") {
+			p.request(&item, tag, itemAuth)
+		}
+	}
+}
+
+// request adds a route for the given request item, in the given tag.
+func (p *postmanImport) request(item *gojson.JSONReader, tag string, auth *gojson.JSONReader) {
+	request := item.Get("request")
+
+	// A request may be given as just its URL.
+	method := "get"
+	rawURL := request.GetString("")
+	if request.Type == gojson.JSONObject {
+		method = strings.ToLower(request.GetString("method"))
+		if method == "" {
+			method = "get"
+		}
+		rawURL = ""
+		if request.KeyExists("auth") {
+			auth = request.Get("auth")
+		}
+	}
+
+	r := Route{
+		Method:     method,
+		Parameters: make(map[string]ParamSpec),
+		Responses:  make(map[string]ResponseSpec),
+	}
+
+	u := request.Get("url")
+	if request.Type == gojson.JSONObject && u.Type == gojson.JSONString {
+		rawURL = u.ToString()
+	}
+
+	var segments []string
+	if u.Type == gojson.JSONObject {
+		segments = u.GetStringSlice("path")
+		p.server(u.GetString("protocol"), strings.Join(u.GetStringSlice("host"), "."), u.GetString("port"))
+
+		for _, q := range members(u.Get("query")) {
+			if !q.GetBool("disabled") {
+				r.Parameters[q.GetString("key")] = ParamSpec{FoundIn: "query", Name: q.GetString("key"), Type: "string"}
+			}
+		}
+	} else {
+		segments = p.parseURL(rawURL, r.Parameters)
+	}
+
+	for i, segment := range segments {
+		name := ""
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			name = segment[1:]
+		case postmanVariable.MatchString(segment) && postmanVariable.FindString(segment) == segment:
+			name = postmanVariable.FindStringSubmatch(segment)[1]
+		default:
+			continue
+		}
+
+		segments[i] = "{" + name + "}"
+		r.Parameters[name] = ParamSpec{FoundIn: "path", Name: name, Required: true, Type: "string"}
+	}
+	r.Path = "/" + strings.Join(segments, "/")
+
+	contentType := ""
+	for _, h := range members(request.Get("header")) {
+		if h.GetBool("disabled") {
+			continue
+		}
+		if strings.EqualFold(h.GetString("key"), "Content-Type") {
+			contentType = h.GetString("value")
+			continue
+		}
+		r.Parameters[h.GetString("key")] = ParamSpec{FoundIn: "header", Name: h.GetString("key"), Type: "string"}
+	}
+
+	p.body(&r, request.Get("body"), contentType)
+
+	for _, resp := range members(item.Get("response")) {
+		p.response(&r, &resp)
+	}
+
+	if scheme := p.auth(auth); scheme != "" {
+		r.Security = [][]string{{scheme}}
+	}
+
+	if p.sc.Endpoints[tag] == nil {
+		p.sc.Endpoints[tag] = make(Endpoints)
+	}
+
+	// Operation IDs must be unique within a tag, so repeated names are numbered.
+	id := postmanName(item.GetString("name"))
+	for n := 2; ; n++ {
+		if _, isset := p.sc.Endpoints[tag][id]; !isset {
+			break
+		}
+		id = postmanName(item.GetString("name")) + strconv.Itoa(n)
+	}
+
+	r.ID = id
+	p.sc.Endpoints[tag][id] = r
+}
+
+// parseURL returns the path segments of the given raw URL, adding its query parameters to the given parameters and
+// its scheme, host, and port to the Servers of the Client.
+func (p *postmanImport) parseURL(raw string, params map[string]ParamSpec) []string {
+	pieces := strings.SplitN(raw, "?", 2)
+	if len(pieces) == 2 {
+		for _, pair := range strings.Split(pieces[1], "&") {
+			if key := strings.SplitN(pair, "=", 2)[0]; key != "" {
+				params[key] = ParamSpec{FoundIn: "query", Name: key, Type: "string"}
+			}
+		}
+	}
+
+	rest := pieces[0]
+	scheme := ""
+	if i := strings.Index(rest, "://"); i >= 0 {
+		scheme, rest = rest[:i], rest[i+3:]
+	}
+
+	host := rest
+	path := ""
+	if i := strings.Index(rest, "/"); i >= 0 {
+		host, path = rest[:i], rest[i+1:]
+	}
+
+	port := ""
+	if i := strings.LastIndex(host, ":"); i >= 0 && !strings.Contains(host[i:], "}") {
+		host, port = host[:i], host[i+1:]
+	}
+	p.server(scheme, host, port)
+
+	if path == "" {
+		return nil
+	}
+
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// server adds the server with the given scheme, host, and port to the Servers of the Client, if it isn't there,
+// after filling in any collection variables. A host which is a variable may hold a whole URL, such as {{baseUrl}}.
+func (p *postmanImport) server(scheme, host, port string) {
+	host = p.expand(host)
+	if host == "" || postmanVariable.MatchString(host) {
+		return
+	}
+
+	server := host
+	if !strings.Contains(host, "://") {
+		if scheme == "" {
+			scheme = "https"
+		}
+		server = p.expand(scheme) + "://" + host
+	}
+	if port != "" {
+		server += ":" + p.expand(port)
+	}
+	server = strings.TrimRight(server, "/")
+
+	for _, s := range p.sc.Servers {
+		if s == server {
+			return
+		}
+	}
+
+	p.sc.Servers = append(p.sc.Servers, server)
+}
+
+// expand fills in the collection variables in the given string. Unknown variables are left as-is.
+func (p *postmanImport) expand(s string) string {
+	return postmanVariable.ReplaceAllStringFunc(s, func(v string) string {
+		if value, isset := p.variables[postmanVariable.FindStringSubmatch(v)[1]]; isset {
+			return value
+		}
+		return v
+	})
+}
+
+// body adds the parameters for the given request body to the route. The Content-Type header of the request, if
+// any, is used as the content type of a raw body.
+func (p *postmanImport) body(r *Route, body *gojson.JSONReader, contentType string) {
+	switch body.GetString("mode") {
+	case "raw":
+		if contentType == "" {
+			switch body.GetString("options.raw.language") {
+			case "json":
+				contentType = "application/json"
+			case "xml":
+				contentType = "application/xml"
+			default:
+				contentType = "text/plain"
+			}
+		}
+		r.Consumes = []string{contentType}
+		r.Parameters["body"] = ParamSpec{FoundIn: "body", Name: "body", Required: true, ContentType: contentType}
+
+	case "graphql":
+		r.Consumes = []string{"application/json"}
+		r.Parameters["body"] = ParamSpec{FoundIn: "body", Name: "body", Required: true, Type: "object", ContentType: "application/json"}
+
+	case "urlencoded", "formdata":
+		r.Consumes = []string{formContentType}
+		if body.GetString("mode") == "formdata" {
+			r.Consumes = []string{multipartContentType}
+		}
+
+		for _, field := range members(body.Get(body.GetString("mode"))) {
+			if field.GetBool("disabled") {
+				continue
+			}
+
+			kind := "string"
+			if field.GetString("type") == "file" {
+				kind = "file"
+			}
+			r.Parameters[field.GetString("key")] = ParamSpec{FoundIn: "formData", Name: field.GetString("key"), Type: kind}
+		}
+	}
+}
+
+// response declares the given saved example response on the route, keyed by its status code. JSON bodies are kept
+// as the example for the response.
+func (p *postmanImport) response(r *Route, resp *gojson.JSONReader) {
+	code := resp.GetInt("code")
+	if code == 0 {
+		return
+	}
+
+	spec := ResponseSpec{
+		Description: resp.GetString("name"),
+		schemas:     make(map[string]schemaRef),
+		examples:    make(map[string]*gojson.JSONReader),
+	}
+
+	for _, h := range members(resp.Get("header")) {
+		if strings.EqualFold(h.GetString("key"), "Content-Type") {
+			spec.Produces = []string{strings.TrimSpace(strings.Split(h.GetString("value"), ";")[0])}
+		}
+	}
+
+	if body := resp.GetString("body"); body != "" {
+		if example, err := gojson.NewJSONReader([]byte(body)); err == nil {
+			if len(spec.Produces) == 0 {
+				spec.Produces = []string{"application/json"}
+			}
+			spec.examples[spec.Produces[0]] = example
+		}
+	}
+
+	key := strconv.Itoa(code)
+	if _, isset := r.Responses[key]; !isset {
+		r.Responses[key] = spec
+	}
+
+	for _, t := range spec.Produces {
+		if !stringInSlice(t, r.Produces) {
+			r.Produces = append(r.Produces, t)
+		}
+	}
+}
+
+// auth declares the security scheme for the given Postman auth, returning its name, or "" if there is none.
+func (p *postmanImport) auth(auth *gojson.JSONReader) string {
+	kind := auth.GetString("type")
+
+	switch kind {
+	case "bearer", "oauth2", "jwt":
+		p.sc.SecuritySchemes["bearer"] = SecurityScheme{Type: "bearer"}
+		return "bearer"
+
+	case "basic":
+		p.sc.SecuritySchemes["basic"] = SecurityScheme{Type: "basic"}
+		return "basic"
+
+	case "apikey":
+		scheme := SecurityScheme{Type: "apiKey", In: "header"}
+		for _, attr := range members(auth.Get("apikey")) {
+			switch attr.GetString("key") {
+			case "key":
+				scheme.Name = attr.GetString("value")
+			case "in":
+				scheme.In = attr.GetString("value")
+			}
+		}
+		p.sc.SecuritySchemes["apikey"] = scheme
+		return "apikey"
+	}
+
+	return ""
+}
+
+// postmanName returns the given Postman name with everything but letters and digits removed, and the first letter
+// of each word capitalized, for use as a tag or operation ID.
+func postmanName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,309 @@
+package gointegration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+// postmanSchema is the schema URL of a Postman collection v2.1.
+const postmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// postmanCollection is a Postman collection v2.1, as written by ExportPostman.
+type postmanCollection struct {
+	Info     postmanInfo       `json:"info"`
+	Item     []postmanItem     `json:"item"`
+	Variable []postmanKeyValue `json:"variable"`
+}
+
+// postmanInfo describes a Postman collection.
+type postmanInfo struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema"`
+}
+
+// postmanItem is a folder of items, or a single request.
+type postmanItem struct {
+	Name    string          `json:"name"`
+	Item    []postmanItem   `json:"item,omitempty"`
+	Request *postmanRequest `json:"request,omitempty"`
+}
+
+// postmanRequest is a request in a Postman collection.
+type postmanRequest struct {
+	Method string            `json:"method"`
+	Header []postmanKeyValue `json:"header"`
+	URL    postmanURL        `json:"url"`
+	Body   *postmanBody      `json:"body,omitempty"`
+	Auth   *postmanAuth      `json:"auth,omitempty"`
+}
+
+// postmanURL is the URL of a request, with the collection's baseUrl variable as its host.
+type postmanURL struct {
+	Raw      string            `json:"raw"`
+	Host     []string          `json:"host"`
+	Path     []string          `json:"path"`
+	Query    []postmanKeyValue `json:"query,omitempty"`
+	Variable []postmanKeyValue `json:"variable,omitempty"`
+}
+
+// postmanBody is the body of a request.
+type postmanBody struct {
+	Mode       string            `json:"mode"`
+	Raw        string            `json:"raw,omitempty"`
+	URLEncoded []postmanKeyValue `json:"urlencoded,omitempty"`
+	FormData   []postmanKeyValue `json:"formdata,omitempty"`
+	Options    interface{}       `json:"options,omitempty"`
+}
+
+// postmanAuth is the auth of a request, whose attributes are given under a key named for its type.
+type postmanAuth map[string]interface{}
+
+// postmanKeyValue is a header, query parameter, form field, variable, or auth attribute.
+type postmanKeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Type  string `json:"type,omitempty"`
+}
+
+// ExportPostman writes the routes of the Client to the file at the given path as a Postman collection (v2.1), for
+// manual exploration of the service, such as by QA. Routes are grouped into a folder per tag, with those tagged
+// "default" at the top of the collection. The collection's baseUrl variable holds the Scheme, Hostname, Port, and
+// BasePath of the Client.
+//
+// Once RecordPostmanExamples has been called, the params of the last request made for each operation fill in the
+// parameters of its request, and other parameters are left empty. Auth is given with variables, such as {{token}},
+// to be filled in within Postman, as are headers which are redacted when logged, such as Authorization and Cookie,
+// and headers and query parameters holding API keys. Other params, such as bodies, are exported as they were sent.
+func (sc *Client) ExportPostman(path string) error {
+	out := postmanCollection{
+		Info:     postmanInfo{Name: sc.title, Schema: postmanSchema},
+		Item:     []postmanItem{},
+		Variable: []postmanKeyValue{{Key: "baseUrl", Value: strings.TrimRight(sc.buildURL("", nil), "/")}},
+	}
+	if out.Info.Name == "" {
+		out.Info.Name = "gointegration"
+	}
+
+	tags := make([]string, 0, len(sc.Endpoints))
+	for tag := range sc.Endpoints {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	for _, tag := range tags {
+		ids := make([]string, 0, len(sc.Endpoints[tag]))
+		for id := range sc.Endpoints[tag] {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		var items []postmanItem
+		for _, id := range ids {
+			route := sc.Endpoints[tag][id]
+			items = append(items, postmanItem{Name: id, Request: sc.postmanRequest(route, sc.example(specifier(tag, id)))})
+		}
+
+		if tag == "default" {
+			out.Item = append(out.Item, items...)
+			continue
+		}
+
+		out.Item = append(out.Item, postmanItem{Name: tag, Item: items})
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ExportPostman: %s", err.Error())
+	}
+
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("ExportPostman: %s", err.Error())
+	}
+
+	return nil
+}
+
+// RecordPostmanExamples keeps the params of the last request made for each operation by Exec and its variants, by
+// this Client and its clones, to fill in the requests written by ExportPostman. Params are only kept from then on.
+func (sc *Client) RecordPostmanExamples() *Client {
+	sc.mu.Lock()
+	if sc.coverage == nil {
+		sc.coverage = &coverage{counts: make(map[string]int)}
+	}
+	cov := sc.coverage
+	sc.mu.Unlock()
+
+	cov.mu.Lock()
+	defer cov.mu.Unlock()
+
+	if cov.examples == nil {
+		cov.examples = make(map[string]map[string]interface{})
+	}
+
+	return sc
+}
+
+// postmanRequest returns the Postman request for the given route, with its parameters filled in from the given
+// example params, if any.
+func (sc *Client) postmanRequest(route Route, example map[string]interface{}) *postmanRequest {
+	req := &postmanRequest{
+		Method: strings.ToUpper(route.Method),
+		Header: []postmanKeyValue{},
+		URL:    postmanURL{Host: []string{"{{baseUrl}}"}},
+	}
+
+	// Example params may repeat a query parameter with a positional designator, such as "filter{1}".
+	values := map[string][]interface{}{}
+	for name, val := range example {
+		name = multiParamPattern.ReplaceAllString(name, "")
+		values[name] = append(values[name], expand(val)...)
+	}
+
+	first := func(name string) string {
+		if len(values[name]) == 0 {
+			return ""
+		}
+		return cast.ToString(values[name][0])
+	}
+
+	for _, segment := range strings.Split(strings.Trim(route.Path, "/"), "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			name := segment[1 : len(segment)-1]
+			req.URL.Variable = append(req.URL.Variable, postmanKeyValue{Key: name, Value: first(name)})
+			segment = ":" + name
+		}
+		if segment != "" {
+			req.URL.Path = append(req.URL.Path, segment)
+		}
+	}
+
+	names := make([]string, 0, len(route.Parameters))
+	for name := range route.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Secrets are exported as variables, named for their param.
+	secretHeaders := sc.secretHeaders()
+	secretQuery := sc.secretQuery()
+
+	contentType := ""
+	var form []postmanKeyValue
+	hasFile := false
+	for _, name := range names {
+		ps := route.Parameters[name]
+
+		switch ps.FoundIn {
+		case "query":
+			if len(values[name]) == 0 {
+				req.URL.Query = append(req.URL.Query, postmanKeyValue{Key: name})
+			}
+			for _, v := range values[name] {
+				value := cast.ToString(v)
+				if secretQuery[name] {
+					value = "{{" + name + "}}"
+				}
+				req.URL.Query = append(req.URL.Query, postmanKeyValue{Key: name, Value: value})
+			}
+
+		case "header":
+			value := first(name)
+			if value != "" && secretHeaders[http.CanonicalHeaderKey(name)] {
+				value = "{{" + name + "}}"
+			}
+			req.Header = append(req.Header, postmanKeyValue{Key: name, Value: value})
+
+		case "formData":
+			kind := "text"
+			if ps.Type == "file" {
+				kind = "file"
+				hasFile = true
+			}
+			form = append(form, postmanKeyValue{Key: name, Value: first(name), Type: kind})
+
+		case "body":
+			contentType = ps.ContentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+
+			raw := ""
+			if val, isset := example[name]; isset {
+				if b, isBytes := val.([]byte); isBytes {
+					raw = string(b)
+				} else if b, err := json.MarshalIndent(val, "", "  "); err == nil {
+					raw = string(b)
+				}
+			}
+
+			req.Body = &postmanBody{Mode: "raw", Raw: raw}
+			if strings.Contains(contentType, "json") {
+				req.Body.Options = map[string]interface{}{"raw": map[string]string{"language": "json"}}
+			}
+		}
+	}
+
+	if len(form) > 0 {
+		if hasFile || prefersMultipart(route.Consumes) {
+			req.Body = &postmanBody{Mode: "formdata", FormData: form}
+		} else {
+			req.Body = &postmanBody{Mode: "urlencoded", URLEncoded: form}
+			contentType = formContentType
+		}
+	}
+
+	if contentType != "" {
+		req.Header = append(req.Header, postmanKeyValue{Key: "Content-Type", Value: contentType})
+	}
+
+	req.Auth = sc.postmanAuth(route)
+
+	raw := "{{baseUrl}}/" + strings.Join(req.URL.Path, "/")
+	var query []string
+	for _, q := range req.URL.Query {
+		query = append(query, q.Key+"="+q.Value)
+	}
+	if len(query) > 0 {
+		raw += "?" + strings.Join(query, "&")
+	}
+	req.URL.Raw = raw
+
+	return req
+}
+
+// postmanAuth returns the Postman auth for the first security scheme of the given route which Postman supports, with
+// its secrets given as variables.
+func (sc *Client) postmanAuth(route Route) *postmanAuth {
+	for _, requirement := range route.Security {
+		for _, name := range requirement {
+			scheme, isset := sc.SecuritySchemes[name]
+			if !isset {
+				continue
+			}
+
+			switch scheme.Type {
+			case "bearer":
+				return &postmanAuth{"type": "bearer", "bearer": []postmanKeyValue{{Key: "token", Value: "{{token}}", Type: "string"}}}
+			case "basic":
+				return &postmanAuth{"type": "basic", "basic": []postmanKeyValue{
+					{Key: "username", Value: "{{username}}", Type: "string"},
+					{Key: "password", Value: "{{password}}", Type: "string"},
+				}}
+			case "apiKey":
+				return &postmanAuth{"type": "apikey", "apikey": []postmanKeyValue{
+					{Key: "key", Value: scheme.Name, Type: "string"},
+					{Key: "value", Value: "{{apiKey}}", Type: "string"},
+					{Key: "in", Value: scheme.In, Type: "string"},
+				}}
+			}
+		}
+	}
+
+	return nil
+}
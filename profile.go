@@ -0,0 +1,207 @@
+package gointegration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+)
+
+// Profile holds the settings of an environment the suite runs against, such as local, dev, or staging, as loaded by
+// LoadProfiles. Empty settings are left as the Client has them. Headers are sent with every request, unless the
+// request sets them itself. BearerToken, or Username and Password, set the Authorization of the Client, as with
+// SetBearerToken and SetBasicAuth, while Credentials satisfy the security schemes of the swagger doc, as with
+// WithAuth.
+type Profile struct {
+	Scheme      string                `json:"scheme"`
+	Host        string                `json:"host"`
+	Port        int                   `json:"port"`
+	BasePath    string                `json:"base_path"`
+	Headers     map[string]string     `json:"headers"`
+	BearerToken string                `json:"bearer_token"`
+	Username    string                `json:"username"`
+	Password    string                `json:"password"`
+	Credentials map[string]Credential `json:"credentials"`
+}
+
+// LoadProfiles returns the profiles in the JSON file at the given path, an object of profiles keyed by name:
+//
+//	{
+//		"local": {"scheme": "http", "host": "localhost", "port": 4080},
+//		"staging": {
+//			"scheme": "https",
+//			"host": "api.staging.example.com",
+//			"headers": {"X-Tenant": "integration"},
+//			"bearer_token": "${STAGING_TOKEN}"
+//		}
+//	}
+//
+// References to environment variables in string settings, such as "${STAGING_TOKEN}", are expanded, so that
+// secrets can be kept out of the file. Only the ${NAME} form is expanded, so that values such as "pa$$word" are kept
+// as-is, and a reference to a variable which isn't set is left as written.
+func LoadProfiles(path string) (map[string]Profile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("LoadProfiles: %s", err.Error())
+	}
+
+	var profiles map[string]Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("LoadProfiles: unable to parse '%s': %s", path, err.Error())
+	}
+
+	for name, p := range profiles {
+		profiles[name] = p.expand()
+	}
+
+	return profiles, nil
+}
+
+// envReferencePattern matches a reference to an environment variable, such as ${STAGING_TOKEN}, capturing its name.
+var envReferencePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv returns the given string with references to environment variables which are set replaced by their values.
+func expandEnv(s string) string {
+	return envReferencePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if v, isset := os.LookupEnv(ref[2 : len(ref)-1]); isset {
+			return v
+		}
+		return ref
+	})
+}
+
+// expand returns a copy of the profile with references to environment variables in its string settings expanded.
+func (p Profile) expand() Profile {
+	p.Scheme = expandEnv(p.Scheme)
+	p.Host = expandEnv(p.Host)
+	p.BasePath = expandEnv(p.BasePath)
+	p.BearerToken = expandEnv(p.BearerToken)
+	p.Username = expandEnv(p.Username)
+	p.Password = expandEnv(p.Password)
+
+	headers := make(map[string]string, len(p.Headers))
+	for k, v := range p.Headers {
+		headers[k] = expandEnv(v)
+	}
+	p.Headers = headers
+
+	creds := make(map[string]Credential, len(p.Credentials))
+	for name, cred := range p.Credentials {
+		creds[name] = Credential{
+			Username: expandEnv(cred.Username),
+			Password: expandEnv(cred.Password),
+			Token:    expandEnv(cred.Token),
+		}
+	}
+	p.Credentials = creds
+
+	return p
+}
+
+// WithProfile configures the Client from the profile with the given name, in the profiles file at the given path.
+// If the name is empty, the profile named by PROFILE is used, and if that is unset too, no profile is used. The
+// profile is used in place of the one named by PROFILES and PROFILE, if both are set. Refer to LoadProfiles and
+// UseProfile.
+func WithProfile(path, name string) Option {
+	return func(sc *Client) error {
+		if name == "" {
+			name = os.Getenv("PROFILE")
+		}
+		if name == "" {
+			return nil
+		}
+
+		if err := sc.useProfileFile(path, name); err != nil {
+			return fmt.Errorf("WithProfile: %s", err.Error())
+		}
+
+		sc.profileGiven = true
+		return nil
+	}
+}
+
+// useEnvProfile configures the Client from the profile named by PROFILE, in the profiles file at the path given by
+// PROFILES, if both are set.
+func (sc *Client) useEnvProfile() error {
+	path, name := os.Getenv("PROFILES"), os.Getenv("PROFILE")
+	if path == "" || name == "" {
+		return nil
+	}
+
+	if err := sc.useProfileFile(path, name); err != nil {
+		return fmt.Errorf("Unable to use profile '%s': %s", name, err.Error())
+	}
+
+	sc.profileFromEnv = true
+	return nil
+}
+
+// useProfileFile configures the Client from the profile with the given name, in the profiles file at the given path.
+func (sc *Client) useProfileFile(path, name string) error {
+	profiles, err := LoadProfiles(path)
+	if err != nil {
+		return err
+	}
+
+	profile, isset := profiles[name]
+	if !isset {
+		return fmt.Errorf("no profile named '%s' in %s", name, path)
+	}
+
+	return sc.UseProfile(profile)
+}
+
+// UseProfile directs all requests to the environment described by the given profile, overriding the Scheme, Hostname,
+// Port, and BasePath of the Client with those it sets, and adding its headers and credentials. When the profile sets
+// a host but no port, the port defaults to 443 for https and 80 otherwise.
+func (sc *Client) UseProfile(profile Profile) error {
+	if profile.Port < 0 || profile.Port > 65535 {
+		return fmt.Errorf("UseProfile: invalid port %d", profile.Port)
+	}
+
+	if profile.Scheme != "" {
+		sc.Scheme = profile.Scheme
+	}
+
+	switch {
+	case profile.Port != 0:
+		sc.Port = profile.Port
+	case profile.Host != "" && sc.Scheme == "https":
+		sc.Port = 443
+	case profile.Host != "":
+		sc.Port = 80
+	}
+
+	if profile.Host != "" {
+		sc.Hostname = profile.Host
+	}
+
+	if profile.BasePath != "" {
+		sc.BasePath = profile.BasePath
+	}
+
+	switch {
+	case profile.BearerToken != "":
+		sc.SetBearerToken(profile.BearerToken)
+	case profile.Username != "" || profile.Password != "":
+		sc.SetBasicAuth(profile.Username, profile.Password)
+	}
+
+	for name, cred := range profile.Credentials {
+		sc.WithAuth(name, cred)
+	}
+
+	if len(profile.Headers) > 0 {
+		sc.mu.Lock()
+		if sc.profileHeaders == nil {
+			sc.profileHeaders = make(map[string]string, len(profile.Headers))
+		}
+		for k, v := range profile.Headers {
+			sc.profileHeaders[k] = v
+		}
+		sc.mu.Unlock()
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package gointegration
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket, holding up to burst tokens, which refills at rate tokens per second. Each request
+// takes a token, waiting for one if the bucket is empty.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a rateLimiter allowing the given number of requests per second, with bursts of up to the
+// given number of requests. The bucket starts full. A burst of less than 1 is taken as 1.
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	b := math.Max(float64(burst), 1)
+
+	return &rateLimiter{rate: rps, burst: b, tokens: b, last: time.Now()}
+}
+
+// wait takes a token, waiting until one is available or the given context is done, in which case its error is
+// returned and no token is taken.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	// The token is reserved now, taking the bucket into debt, so that concurrent requests queue in order.
+	l.tokens--
+	delay := time.Duration(0)
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Return the reserved token, so that requests queued behind this one aren't held up by it.
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// WithRateLimit limits the Client to the given number of requests per second. Refer to SetRateLimit.
+func WithRateLimit(rps float64, burst int) Option {
+	return func(sc *Client) error {
+		sc.SetRateLimit(rps, burst)
+		return nil
+	}
+}
+
+// SetRateLimit limits the Client to the given number of requests per second, allowing bursts of up to the given
+// number of requests, such as to keep a soak suite under the rate limits of the service, or from overwhelming a
+// shared environment. Requests over the limit wait their turn, in order; the wait isn't counted in their
+// RequestDuration, but is bounded by their timeout. Each retry counts as a request. The limit is shared with clones
+// of the Client, so that parallel subtests stay within it together. A rate of 0 or less removes the limit.
+func (sc *Client) SetRateLimit(rps float64, burst int) *Client {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.rateLimiter = nil
+	if rps > 0 {
+		sc.rateLimiter = newRateLimiter(rps, burst)
+	}
+
+	return sc
+}
+
+// currentRateLimiter returns the rate limiter of the Client, if it has one.
+func (sc *Client) currentRateLimiter() *rateLimiter {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	return sc.rateLimiter
+}
@@ -0,0 +1,289 @@
+package gointegration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RequestOptions customizes a single request. Headers and Cookies are added to the request, and Query is added to
+// its URL. Body is sent as-is if it is a []byte or string, is streamed if it is an io.Reader, and is otherwise
+// marshalled as JSON. ContentType sets the Content-Type header, which defaults to application/json when there is a
+// Body, or application/octet-stream when it is an io.Reader. Body and ContentType are only used by ExecRaw, as
+// requests for routes in the swagger doc take their body from the params.
+type RequestOptions struct {
+	Headers     map[string]string
+	Cookies     []*http.Cookie
+	Query       url.Values
+	Body        interface{}
+	ContentType string
+
+	// Timeout, when set, limits the time allowed for this request.
+	Timeout time.Duration
+
+	// FollowRedirects, when set, overrides the FollowRedirects of the Client for this request.
+	FollowRedirects *bool
+
+	// GzipBody, when set, overrides the GzipRequests of the Client for this request.
+	GzipBody *bool
+
+	// Chunked sends the body with chunked transfer encoding, rather than with a Content-Length. Bodies given as an
+	// io.Reader of unknown length, such as an *os.File, are always sent chunked.
+	Chunked bool
+
+	// Address, when set, sends the request to the given host and port, such as "localhost:50051", rather than to the
+	// Hostname and Port of the Client.
+	Address string
+
+	// HTTPClient, when set, makes the request with the given http.Client in place of the Client's own, such as one
+	// which only speaks HTTP/2. The middlewares, ResponseCache, and rate limit of the Client still apply.
+	HTTPClient *http.Client
+}
+
+// readyInterval is the time WaitForReady waits between attempts.
+var readyInterval = 250 * time.Millisecond
+
+// followRedirectsKey is the context key under which a per-request FollowRedirects override is stored.
+type followRedirectsKey struct{}
+
+// ExecWithOptions behaves as Exec, additionally applying the given options to the request. This allows one-off
+// headers, cookies, and query parameters without declaring them in the swagger doc.
+func (sc *Client) ExecWithOptions(specifier string, params map[string]interface{}, opts RequestOptions) ClientResponse {
+	ctx, cancel := opts.context(context.Background())
+	defer cancel()
+
+	req, err := sc.newRequest(ctx, specifier, params)
+	if err != nil {
+		return ClientResponse{Error: err}
+	}
+
+	opts.apply(req)
+
+	return sc.MakeRequest(req)
+}
+
+// ExecJSONWithOptions behaves as ExecJSON, additionally applying the given options to the request.
+func (sc *Client) ExecJSONWithOptions(specifier string, params map[string]interface{}, opts RequestOptions) JSONResponse {
+	return toJSONResponse(sc.ExecWithOptions(specifier, params, opts))
+}
+
+// ExecRaw makes a request with the given method to the given path, which need not be declared in the swagger doc.
+// This is useful for undocumented endpoints, such as health checks or debug routes, and for deliberately invalid
+// paths. The request is made to the Client's Scheme, Hostname, and Port, but the BasePath is not prepended.
+func (sc *Client) ExecRaw(method, path string, opts RequestOptions) ClientResponse {
+	return sc.ExecRawContext(context.Background(), method, path, opts)
+}
+
+// ExecRawContext behaves as ExecRaw, except that the request is bound to the given context.
+func (sc *Client) ExecRawContext(ctx context.Context, method, path string, opts RequestOptions) ClientResponse {
+	ctx, cancel := opts.context(ctx)
+	defer cancel()
+
+	req, err := sc.newRawRequest(ctx, method, path, opts)
+	if err != nil {
+		return ClientResponse{Error: err}
+	}
+
+	return sc.MakeRequest(req)
+}
+
+// ExecRawQuiet behaves as ExecRawContext, except that the request is made once, without retries, hooks, tracing,
+// logging, or recorders, for requests which support a test rather than being part of it, such as the server
+// reflection calls of the gointegration/grpc package.
+func (sc *Client) ExecRawQuiet(ctx context.Context, method, path string, opts RequestOptions) ClientResponse {
+	ctx, cancel := opts.context(ctx)
+	defer cancel()
+
+	req, err := sc.buildRawRequest(ctx, method, path, opts)
+	if err != nil {
+		return ClientResponse{Error: err}
+	}
+
+	return sc.doRequest(req)
+}
+
+// ExecRawJSON behaves as ExecRaw, except that the response is returned as a JSONResponse.
+func (sc *Client) ExecRawJSON(method, path string, opts RequestOptions) JSONResponse {
+	return toJSONResponse(sc.ExecRaw(method, path, opts))
+}
+
+// WaitForReady polls the given path, such as a health check, with GET requests until it responds with 200 OK or the
+// timeout expires, for use before a suite starts against a service that is still starting up. The path is requested
+// as in ExecRaw, but without retries or hooks. If the service isn't ready in time, the error wraps ErrTimeout and
+// describes the last attempt.
+func (sc *Client) WaitForReady(path string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		req, err := sc.buildRawRequest(ctx, http.MethodGet, path, RequestOptions{})
+		if err != nil {
+			cancel()
+			return fmt.Errorf("WaitForReady: %s", err.Error())
+		}
+
+		resp := sc.makeRequest(req)
+		cancel()
+
+		if resp.Error == nil && resp.StatusCode == http.StatusOK {
+			return nil
+		}
+
+		if time.Now().Add(readyInterval).After(deadline) {
+			last := fmt.Sprintf("status %d", resp.StatusCode)
+			if resp.Error != nil {
+				last = resp.Error.Error()
+			}
+			return fmt.Errorf("%w: WaitForReady: %s was not ready after %s, last attempt: %s", ErrTimeout, path, timeout, last)
+		}
+
+		time.Sleep(readyInterval)
+	}
+}
+
+// newRawRequest builds the request for ExecRaw, after running any hooks.
+func (sc *Client) newRawRequest(ctx context.Context, method, path string, opts RequestOptions) (*http.Request, error) {
+	if err := sc.runBeforeHooks(); err != nil {
+		return nil, err
+	}
+
+	return sc.buildRawRequest(ctx, method, path, opts)
+}
+
+// buildRawRequest builds the request for ExecRaw.
+func (sc *Client) buildRawRequest(ctx context.Context, method, path string, opts RequestOptions) (*http.Request, error) {
+	path, err := sc.render(path)
+	if err != nil {
+		return nil, fmt.Errorf("ExecRaw: %s", err.Error())
+	}
+
+	resolved := opts.Body
+	if _, isBytes := resolved.([]byte); !isBytes {
+		resolved, err = sc.resolveVariable(resolved)
+		if err != nil {
+			return nil, fmt.Errorf("ExecRaw: %s", err.Error())
+		}
+	}
+
+	var body []byte
+	var reader io.Reader
+	_, isReader := resolved.(io.Reader)
+	switch b := resolved.(type) {
+	case nil:
+	case []byte:
+		body = b
+	case io.Reader:
+		reader = b
+		ctx = withStreamedBody(ctx)
+	case string:
+		body = []byte(b)
+	default:
+		body, err = json.Marshal(b)
+		if err != nil {
+			return nil, fmt.Errorf("ExecRaw: Marshal of body failed with message: %s", err.Error())
+		}
+	}
+
+	u := fmt.Sprintf("%s://%s:%d/%s", sc.Scheme, sc.Hostname, sc.Port, strings.TrimLeft(path, "/"))
+
+	if reader == nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), u, reader)
+	if err != nil {
+		return nil, fmt.Errorf("ExecRaw: %s", err.Error())
+	}
+
+	switch {
+	case opts.ContentType != "":
+		req.Header.Set("Content-Type", opts.ContentType)
+	case isReader:
+		req.Header.Set("Content-Type", "application/octet-stream")
+	case body != nil:
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	// Routes outside the swagger doc have no security requirements, so only the Client's Authorization applies.
+	sc.mu.RLock()
+	if sc.Authorization != "" {
+		req.Header.Set("Authorization", sc.Authorization)
+	}
+	for k, v := range sc.profileHeaders {
+		req.Header.Set(k, v)
+	}
+	sc.mu.RUnlock()
+
+	// Indentify ourself as an integration test to the service.
+	req.Header.Set(sc.IdentityHeader, "true")
+
+	opts.apply(req)
+
+	return req, nil
+}
+
+// apply adds the headers, cookies, and query parameters of the options to the given request, and sets its address
+// and transfer encoding.
+func (opts RequestOptions) apply(req *http.Request) {
+	if opts.Address != "" {
+		req.URL.Host = opts.Address
+		req.Host = opts.Address
+	}
+
+	for k, v := range opts.Headers {
+		req.Header.Set(k, v)
+	}
+
+	for _, c := range opts.Cookies {
+		req.AddCookie(c)
+	}
+
+	if len(opts.Query) > 0 {
+		q := opts.Query.Encode()
+		if req.URL.RawQuery != "" {
+			q = req.URL.RawQuery + "&" + q
+		}
+		req.URL.RawQuery = q
+	}
+
+	if opts.Chunked && req.Body != nil && req.Body != http.NoBody {
+		req.TransferEncoding = []string{"chunked"}
+	}
+}
+
+// context derives the context for a request from the given context, applying the Timeout, FollowRedirects,
+// GzipBody, and HTTPClient options.
+func (opts RequestOptions) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if opts.HTTPClient != nil {
+		ctx = context.WithValue(ctx, httpClientKey{}, opts.HTTPClient)
+	}
+
+	if opts.FollowRedirects != nil {
+		ctx = context.WithValue(ctx, followRedirectsKey{}, *opts.FollowRedirects)
+	}
+
+	if opts.GzipBody != nil {
+		ctx = context.WithValue(ctx, gzipRequestsKey{}, *opts.GzipBody)
+	}
+
+	if opts.Timeout > 0 {
+		return context.WithTimeout(ctx, opts.Timeout)
+	}
+
+	return ctx, func() {}
+}
+
+// followRedirects returns whether the request with the given context should follow redirects.
+func (sc *Client) followRedirects(ctx context.Context) bool {
+	if follow, ok := ctx.Value(followRedirectsKey{}).(bool); ok {
+		return follow
+	}
+
+	return sc.FollowRedirects
+}
@@ -0,0 +1,310 @@
+package gointegration
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Recorder records every request made by the Clients it is attached to with Record, and the outcome of every
+// assertion made on their responses, so that the results of a run can be exported for CI systems, such as by
+// WriteJUnit, or shared, such as by WriteHTML.
+//
+// Each assertion is recorded as it is made, and passes unless it fails: Expect methods which make no assertion
+// when they pass, such as ExpectStatusIn, are only recorded if they fail.
+type Recorder struct {
+	mu       sync.Mutex
+	requests []RequestRecord
+	results  []AssertionResult
+}
+
+// maxRecordedBody is the number of bytes of each request and response body kept by a Recorder.
+const maxRecordedBody = 64 * 1024
+
+// RequestRecord is a single request made by a Client with a Recorder, and its response. IDs count from 1.
+// Bodies are truncated to 64KB.
+type RequestRecord struct {
+	ID           int
+	Method       string
+	URL          string
+	RequestBody  string
+	StatusCode   int
+	Duration     time.Duration
+	Error        string
+	ResponseBody string
+	Time         time.Time
+}
+
+// AssertionResult is the outcome of a single assertion. Test is the name of the test it was made in, Assertion is
+// the name of the Expect method, and URL is the URL of the request the response was for. Request is the ID of the
+// RequestRecord for that request, or 0 if it wasn't recorded. Message is the failure message of a failed assertion.
+type AssertionResult struct {
+	Test      string
+	Assertion string
+	URL       string
+	Request   int
+	Passed    bool
+	Message   string
+	Time      time.Time
+}
+
+// NewRecorder returns an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Record attaches the given Recorder to the Client, or detaches it, if nil. Clones of the Client share its Recorder.
+func (sc *Client) Record(rec *Recorder) *Client {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.recorder = rec
+
+	return sc
+}
+
+// Requests returns every request recorded so far, in the order they were made.
+func (r *Recorder) Requests() []RequestRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]RequestRecord(nil), r.requests...)
+}
+
+// recordRequest records the given request and its response, returning its ID.
+func (r *Recorder) recordRequest(req *http.Request, resp ClientResponse) int {
+	record := RequestRecord{
+		Method:       req.Method,
+		URL:          req.URL.String(),
+		StatusCode:   resp.StatusCode,
+		Duration:     resp.RequestDuration,
+		ResponseBody: truncate(resp.Body, maxRecordedBody),
+		Time:         time.Now().Add(-resp.RequestDuration),
+	}
+
+	if resp.Error != nil {
+		record.Error = resp.Error.Error()
+	}
+
+	record.RequestBody = truncate(requestBody(req), maxRecordedBody)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record.ID = len(r.requests) + 1
+	r.requests = append(r.requests, record)
+
+	return record.ID
+}
+
+// requestBody returns the body of the given request, which has been sent, by reading a copy, if the request can give
+// one.
+func requestBody(req *http.Request) string {
+	if req.GetBody == nil {
+		return ""
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+
+	data, _ := ioutil.ReadAll(body)
+
+	return string(data)
+}
+
+// truncate returns at most the first n bytes of the given string.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return s[:n]
+}
+
+// Results returns the outcome of every assertion recorded so far, in the order they were made.
+func (r *Recorder) Results() []AssertionResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]AssertionResult(nil), r.results...)
+}
+
+// track records a new, passing assertion, returning a TestingT which forwards to the given TestingT and marks the
+// assertion as failed if it fails.
+func (r *Recorder) track(t assert.TestingT, test, assertion string, resp ClientResponse) assert.TestingT {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.results = append(r.results, AssertionResult{Test: test, Assertion: assertion, URL: resp.RequestURL, Request: resp.recordID, Passed: true, Time: time.Now()})
+
+	return &recordedAssertion{TestingT: t, rec: r, index: len(r.results) - 1}
+}
+
+// recordedAssertion is the TestingT given to a single recorded assertion.
+type recordedAssertion struct {
+	assert.TestingT
+
+	rec   *Recorder
+	index int
+}
+
+// Errorf marks the assertion as failed, then forwards the failure. It implements assert.TestingT.
+func (a *recordedAssertion) Errorf(format string, args ...interface{}) {
+	a.rec.mu.Lock()
+	result := &a.rec.results[a.index]
+	result.Passed = false
+	result.Message = strings.TrimSpace(fmt.Sprintf(format, args...))
+	a.rec.mu.Unlock()
+
+	a.TestingT.Errorf(format, args...)
+}
+
+// recorder returns the Recorder attached to the Client that made the request, if any.
+func (c ClientResponse) recorder() *Recorder {
+	if c.client == nil {
+		return nil
+	}
+
+	return c.client.currentRecorder()
+}
+
+// currentRecorder returns the Recorder attached to the Client, if any.
+func (sc *Client) currentRecorder() *Recorder {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	return sc.recorder
+}
+
+// assertionName returns the name of the Expect or Optional method making the current assertion, found by walking
+// up the stack from its caller.
+func assertionName() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+
+	for {
+		frame, more := frames.Next()
+
+		name := frame.Function[strings.LastIndex(frame.Function, ".")+1:]
+		if strings.HasPrefix(name, "Expect") || strings.HasPrefix(name, "Optional") {
+			return name
+		}
+
+		if !more {
+			return "Expect"
+		}
+	}
+}
+
+// junitTestSuites is the root element of a JUnit XML report.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite holds the test cases of a single top-level test.
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase is a single assertion.
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+// junitFailure describes a failed assertion.
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",cdata"`
+}
+
+// WriteJUnit writes the recorded results to the file at the given path as a JUnit XML report. Each top-level test
+// is a test suite, and each assertion is a test case, named for the Expect method and the request URL.
+func (r *Recorder) WriteJUnit(path string) error {
+	out := junitTestSuites{}
+	suites := map[string]int{}
+
+	for _, result := range r.Results() {
+		suite := strings.SplitN(result.Test, "/", 2)[0]
+
+		i, isset := suites[suite]
+		if !isset {
+			i = len(out.Suites)
+			suites[suite] = i
+			out.Suites = append(out.Suites, junitTestSuite{Name: suite, Timestamp: result.Time.Format("2006-01-02T15:04:05")})
+		}
+
+		tc := junitTestCase{ClassName: result.Test, Name: fmt.Sprintf("%d %s %s", out.Suites[i].Tests+1, result.Assertion, result.URL)}
+		if !result.Passed {
+			tc.Failure = &junitFailure{Message: failureSummary(result.Message), Text: result.Message}
+			out.Suites[i].Failures++
+			out.Failures++
+		}
+
+		out.Suites[i].Cases = append(out.Suites[i].Cases, tc)
+		out.Suites[i].Tests++
+		out.Tests++
+	}
+
+	data, err := xml.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("WriteJUnit: %s", err.Error())
+	}
+
+	if err := ioutil.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0644); err != nil {
+		return fmt.Errorf("WriteJUnit: %s", err.Error())
+	}
+
+	return nil
+}
+
+// failureSummary returns a one-line summary of the given failure message, as formatted by testify: its Messages,
+// which hold the message given by the Expect method, or failing that, its Error.
+func failureSummary(msg string) string {
+	fields := map[string]string{}
+	for _, line := range strings.Split(msg, "\n") {
+		line = strings.TrimSpace(line)
+		for _, field := range []string{"Error:", "Messages:"} {
+			if strings.HasPrefix(line, field) {
+				fields[field] = strings.TrimSpace(strings.TrimPrefix(line, field))
+			}
+		}
+	}
+
+	if fields["Messages:"] != "" {
+		return fields["Messages:"]
+	}
+	if fields["Error:"] != "" {
+		return fields["Error:"]
+	}
+
+	return strings.TrimSpace(strings.SplitN(msg, "\n", 2)[0])
+}
+
+// testName returns the name of the given test, or "" if there is none.
+func testName(t *testing.T) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.Name()
+}
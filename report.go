@@ -0,0 +1,124 @@
+package gointegration
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"time"
+)
+
+// htmlReport is the data of an HTML run report.
+type htmlReport struct {
+	Generated  time.Time
+	Requests   []htmlRequest
+	Unlinked   []AssertionResult
+	Assertions int
+	Failures   int
+	Failed     int
+}
+
+// htmlRequest is a single request in an HTML run report, with the assertions made on its response.
+type htmlRequest struct {
+	RequestRecord
+
+	Assertions []AssertionResult
+	Failed     bool
+}
+
+// WriteHTML writes the recorded requests and results to the file at the given path as a standalone HTML report, for
+// sharing the outcome of a run. Each request is listed with its URL, body, status, and latency, and the assertions
+// made on its response. Response bodies are only included for requests with a failed assertion or error.
+func (r *Recorder) WriteHTML(path string) error {
+	report := htmlReport{Generated: time.Now()}
+	index := map[int]int{}
+
+	for _, record := range r.Requests() {
+		index[record.ID] = len(report.Requests)
+		report.Requests = append(report.Requests, htmlRequest{RequestRecord: record, Failed: record.Error != ""})
+	}
+
+	for _, result := range r.Results() {
+		report.Assertions++
+		if !result.Passed {
+			report.Failures++
+		}
+
+		i, isset := index[result.Request]
+		if !isset {
+			report.Unlinked = append(report.Unlinked, result)
+			continue
+		}
+
+		report.Requests[i].Assertions = append(report.Requests[i].Assertions, result)
+		if !result.Passed {
+			report.Requests[i].Failed = true
+		}
+	}
+
+	for _, req := range report.Requests {
+		if req.Failed {
+			report.Failed++
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := htmlReportTemplate.Execute(&buf, report); err != nil {
+		return fmt.Errorf("WriteHTML: %s", err.Error())
+	}
+
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("WriteHTML: %s", err.Error())
+	}
+
+	return nil
+}
+
+// htmlReportTemplate renders an htmlReport. The report has no external assets, so it can be shared as a single file.
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ms": func(d time.Duration) string {
+		return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+	},
+	"stamp": func(t time.Time) string {
+		return t.Format(time.RFC3339)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Integration Test Report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { margin-bottom: 0.2em; }
+.summary { color: #555; margin-bottom: 2em; }
+.request { border: 1px solid #ccc; border-left: 6px solid #2a2; border-radius: 4px; margin-bottom: 1em; padding: 0.5em 1em; }
+.request.failed { border-left-color: #c22; }
+.line { font-family: monospace; font-size: 1.05em; }
+.method { font-weight: bold; }
+.meta { color: #555; margin-left: 1em; }
+ul { list-style: none; padding-left: 0; }
+li.pass::before { content: "\2713  "; color: #2a2; }
+li.fail::before { content: "\2717  "; color: #c22; }
+pre { background: #f6f6f6; padding: 0.5em; overflow-x: auto; white-space: pre-wrap; }
+.error { color: #c22; }
+</style>
+</head>
+<body>
+<h1>Integration Test Report</h1>
+<div class="summary">Generated {{stamp .Generated}}: {{len .Requests}} requests, {{.Failed}} failed; {{.Assertions}} assertions, {{.Failures}} failed.</div>
+{{range .Requests}}<div class="request{{if .Failed}} failed{{end}}">
+<div class="line"><span class="method">{{.Method}}</span> {{.URL}}<span class="meta">{{if .StatusCode}}{{.StatusCode}}{{else}}no response{{end}} in {{ms .Duration}} at {{stamp .Time}}</span></div>
+{{if .Error}}<div class="error">{{.Error}}</div>
+{{end}}{{if .RequestBody}}<details><summary>Request body</summary><pre>{{.RequestBody}}</pre></details>
+{{end}}{{if .Assertions}}<ul>
+{{range .Assertions}}<li class="{{if .Passed}}pass{{else}}fail{{end}}">{{.Assertion}} <span class="meta">{{.Test}}</span>{{if not .Passed}}<pre>{{.Message}}</pre>{{end}}</li>
+{{end}}</ul>
+{{end}}{{if and .Failed .ResponseBody}}<details open><summary>Response body</summary><pre>{{.ResponseBody}}</pre></details>
+{{end}}</div>
+{{end}}{{if .Unlinked}}<h2>Other assertions</h2>
+<ul>
+{{range .Unlinked}}<li class="{{if .Passed}}pass{{else}}fail{{end}}">{{.Assertion}} {{.URL}} <span class="meta">{{.Test}}</span>{{if not .Passed}}<pre>{{.Message}}</pre>{{end}}</li>
+{{end}}</ul>
+{{end}}</body>
+</html>
+`))
@@ -1,26 +1,74 @@
 package gointegration
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/btm6084/gojson"
+	"github.com/spf13/cast"
 	"github.com/stretchr/testify/assert"
 )
 
 // ClientResponse holds the pertinent information returned from a third party request.
+// Headers holds the first value received for each header, while HeaderValues holds all of them.
+// Attempts is the number of times the request was made, including any retries. Proto is the protocol the
+// response was received over, such as "HTTP/1.1" or "HTTP/2.0". RequestDuration is the time taken by the
+// request, of which RequestTime is the string form, and Timings breaks it down into its DNS lookup, connect, TLS
+// handshake, and time to first byte. Trailers holds the trailers received after the body, if any,
+// such as the status of a gRPC call. Cached is true if the response was served from the ResponseCache of the
+// Client, rather than by the service. TraceID is the ID of the trace the request was sent with, when
+// the Client has Tracing set.
+//
+// BodyBytes holds the body as received, after any content coding is reversed, for binary payloads such as images,
+// PDFs, or protobufs, while Body holds it as text, transcoded to UTF-8 from the charset named by its Content-Type,
+// such as ISO-8859-1 or UTF-16, unless the Client has RawCharset set. RawBody holds the body as read, before any
+// gzip, deflate, or br content coding named by the Content-Encoding header is reversed.
 type ClientResponse struct {
-	Body        string            `json:"body"`
-	Cookies     []*http.Cookie    `json:"cookies"`
-	Error       error             `json:"error"`
-	Headers     map[string]string `json:"headers"`
-	RequestTime string            `json:"request_time"`
-	RequestURL  string            `json:"request_url"`
-	Status      string            `json:"status"`
-	StatusCode  int               `json:"status_code"`
+	Attempts        int                 `json:"attempts"`
+	Body            string              `json:"body"`
+	BodyBytes       []byte              `json:"-"`
+	RawBody         []byte              `json:"-"`
+	BytesReceived   int64               `json:"bytes_received"`
+	Cached          bool                `json:"cached,omitempty"`
+	ContentLength   int64               `json:"content_length"`
+	Cookies         []*http.Cookie      `json:"cookies"`
+	Error           error               `json:"error"`
+	Headers         map[string]string   `json:"headers"`
+	HeaderValues    map[string][]string `json:"header_values"`
+	Proto           string              `json:"proto"`
+	RequestDuration time.Duration       `json:"request_duration"`
+	RequestTime     string              `json:"request_time"`
+	RequestURL      string              `json:"request_url"`
+	Status          string              `json:"status"`
+	StatusCode      int                 `json:"status_code"`
+	Timings         RequestTimings      `json:"timings"`
+	Trailers        map[string][]string `json:"trailers,omitempty"`
+	TraceID         string              `json:"trace_id,omitempty"`
+
+	// client is the Client that made the request, for assertions that consult the swagger doc.
+	client *Client
+
+	// charset is the charset the Body was transcoded to UTF-8 from, if any.
+	charset string
+
+	// soft collects failed assertions in soft mode, as set by Soft.
+	soft *softAssertions
+
+	// recordID is the ID of the request in the Recorder of the Client, if it has one.
+	recordID int
+
+	// request is the request the response is for, and failures marks the first failed assertion on it, which is
+	// given the context of the failure, such as for CurlOnFailure.
+	request  *http.Request
+	failures *sync.Once
 }
 
 // ExpectError is used to assert that a certain error condition has occured.
@@ -31,16 +79,28 @@ func (c ClientResponse) ExpectError(t *testing.T, err error) ClientResponse {
 			return c
 		}
 
-		assert.True(t, false, fmt.Sprintf("expected no error, got error `%v` instead", c.Error))
+		assert.True(c.reporter(t), false, fmt.Sprintf("expected no error, got error `%v` instead", c.Error))
 		return c
 	}
 
 	if c.Error == nil {
-		assert.True(t, false, fmt.Sprintf("expected error `%v`, had nil instead", err))
+		assert.True(c.reporter(t), false, fmt.Sprintf("expected error `%v`, had nil instead", err))
 		return c
 	}
 
-	assert.Equal(t, err, c.Error, fmt.Sprintf("expected error with message `%v`, got error with message `%v`", err, c.Error))
+	assert.Equal(c.reporter(t), err, c.Error, fmt.Sprintf("expected error with message `%v`, got error with message `%v`", err, c.Error))
+
+	return c
+}
+
+// ExpectErrorIs is used to assert that the error condition that occured wraps the given error, such as ErrTimeout or ErrCanceled.
+func (c ClientResponse) ExpectErrorIs(t *testing.T, err error) ClientResponse {
+	if c.Error == nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected error `%v`, had nil instead", err))
+		return c
+	}
+
+	assert.True(c.reporter(t), errors.Is(c.Error, err), fmt.Sprintf("expected error wrapping `%v`, got error with message `%v`", err, c.Error))
 
 	return c
 }
@@ -58,7 +118,7 @@ func (c ClientResponse) Expect(t *testing.T, eval func(c ClientResponse) error)
 	if err != nil {
 		msg = err.Error()
 	}
-	assert.Nil(t, err, msg)
+	assert.Nil(c.reporter(t), err, msg)
 
 	return c
 }
@@ -69,349 +129,1815 @@ func (c ClientResponse) ExpectStatus(t *testing.T, status int) ClientResponse {
 		return c
 	}
 
-	assert.Equal(t, status, c.StatusCode, fmt.Sprintf("expected statuscode '%d', got '%d' instead", status, c.StatusCode))
+	assert.Equal(c.reporter(t), status, c.StatusCode, fmt.Sprintf("expected statuscode '%d', got '%d' instead", status, c.StatusCode))
 
 	return c
 }
 
-// ExpectHeaderEmpty asserts that there was no header value set at a given key.
-func (c ClientResponse) ExpectHeaderEmpty(t *testing.T, key string) ClientResponse {
+// ExpectStatusNot asserts that the status code received is not the given status code.
+func (c ClientResponse) ExpectStatusNot(t *testing.T, status int) ClientResponse {
 	if c.Error != nil {
 		return c
 	}
 
-	if _, isset := c.Headers[key]; !isset {
-		return c
-	}
-
-	assert.Fail(t, fmt.Sprintf("expected no header with key '%s' set", key))
+	assert.NotEqual(c.reporter(t), status, c.StatusCode, fmt.Sprintf("expected statuscode other than '%d'", status))
 
 	return c
 }
 
-// ExpectHeaderValue asserts that the header value at the given key will match the given value.
-func (c ClientResponse) ExpectHeaderValue(t *testing.T, key string, value string) ClientResponse {
+// ExpectStatusIn asserts that the status code received is one of the given status codes.
+func (c ClientResponse) ExpectStatusIn(t *testing.T, statuses ...int) ClientResponse {
 	if c.Error != nil {
 		return c
 	}
 
-	if _, isset := c.Headers[key]; !isset {
-		assert.True(t, isset, fmt.Sprintf("no header with key '%s' set", key))
-		return c
+	for _, status := range statuses {
+		if c.StatusCode == status {
+			return c
+		}
 	}
 
-	assert.Equal(t, value, c.Headers[key], fmt.Sprintf("expected header '%s' to have value '%s', got '%s' instead", key, value, c.Headers[key]))
+	assert.Fail(c.reporter(t), fmt.Sprintf("expected statuscode in %v, got '%d' instead", statuses, c.StatusCode))
 
 	return c
 }
 
-// OptionalHeaderValue differs from ExpectHeaderValue in that it can only fail if the given key exists. If the key is missing entirely, the test will pass.
-func (c ClientResponse) OptionalHeaderValue(t *testing.T, key string, value string) ClientResponse {
-	if _, isset := c.Headers[key]; !isset {
+// ExpectStatusRange asserts that the status code received is between min and max, inclusive.
+func (c ClientResponse) ExpectStatusRange(t *testing.T, min, max int) ClientResponse {
+	if c.Error != nil {
 		return c
 	}
 
-	return c.ExpectHeaderValue(t, key, value)
+	assert.True(c.reporter(t), c.StatusCode >= min && c.StatusCode <= max, fmt.Sprintf("expected statuscode between '%d' and '%d', got '%d' instead", min, max, c.StatusCode))
+
+	return c
 }
 
-// ExpectHeaderMatch asserts that the header value at the given key will match the given regular expression.
-func (c ClientResponse) ExpectHeaderMatch(t *testing.T, key string, re *regexp.Regexp) ClientResponse {
+// ExpectRequestTimeUnder asserts that the request took less than the given duration.
+func (c ClientResponse) ExpectRequestTimeUnder(t *testing.T, limit time.Duration) ClientResponse {
 	if c.Error != nil {
 		return c
 	}
 
-	if _, isset := c.Headers[key]; !isset {
-		assert.True(t, isset, fmt.Sprintf("no header with key '%s' set", key))
+	assert.True(c.reporter(t), c.RequestDuration < limit, fmt.Sprintf("expected request to take less than %s, took %s instead", limit, c.RequestDuration))
+
+	return c
+}
+
+// ExpectProto asserts that the response was received over the given protocol, such as "HTTP/2.0".
+func (c ClientResponse) ExpectProto(t *testing.T, proto string) ClientResponse {
+	if c.Error != nil {
 		return c
 	}
 
-	val := c.Headers[key]
-	assert.True(t, re.Match([]byte(val)), fmt.Sprintf("expect header match error: '%s' did not pass the regex test `%s`", val, re.String()))
+	assert.Equal(c.reporter(t), proto, c.Proto, fmt.Sprintf("expected protocol '%s', got '%s' instead", proto, c.Proto))
 
 	return c
 }
 
-// OptionalHeaderMatch differs from ExpectHeaderMatch in that it can only fail if the given key exists. If the key is missing entirely, the test will pass.
-func (c ClientResponse) OptionalHeaderMatch(t *testing.T, key string, re *regexp.Regexp) ClientResponse {
-	if _, isset := c.Headers[key]; !isset {
+// ExpectContentLengthAccurate asserts that the number of bytes received matches the declared Content-Length.
+// Responses that did not declare a Content-Length, such as chunked responses, always pass.
+func (c ClientResponse) ExpectContentLengthAccurate(t *testing.T) ClientResponse {
+	if c.Error != nil || c.ContentLength < 0 {
 		return c
 	}
 
-	return c.ExpectHeaderMatch(t, key, re)
-}
+	assert.Equal(c.reporter(t), c.ContentLength, c.BytesReceived, fmt.Sprintf("expected %d bytes from Content-Length, received %d", c.ContentLength, c.BytesReceived))
 
-// JSONResponse is a ClientResponse with added functionality specifically for dealing with json API responses.
-type JSONResponse struct {
-	ClientResponse
-	Reader *gojson.JSONReader `json:"-"`
+	return c
 }
 
-// ExpectError is used to assert that a certain error condition has occured.
-func (c JSONResponse) ExpectError(t *testing.T, err error) JSONResponse {
-	// To avoid a panic inside assert, we will handle nil values explicitly
-	if err == nil {
-		if c.Error == nil {
-			return c
-		}
+// ExpectBodyEquals asserts that the body of the response is exactly the given value.
+func (c ClientResponse) ExpectBodyEquals(t *testing.T, body string) ClientResponse {
+	if c.Error != nil {
+		return c
+	}
 
-		assert.True(t, false, fmt.Sprintf("expected no error, got error `%v` instead", c.Error))
+	if diff := bodyDiff(body, c.Body); len(diff) > 0 {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected body to equal the given value:\n\t%s", strings.Join(diff, "\n\t")))
 		return c
 	}
 
-	if c.Error == nil {
-		assert.True(t, false, fmt.Sprintf("expected error `%v`, had nil instead", err))
+	assert.Equal(c.reporter(t), body, c.Body, fmt.Sprintf("expected body '%s', got '%s' instead", body, c.Body))
+
+	return c
+}
+
+// ExpectBodySHA256 asserts that the SHA-256 digest of the body of the response is the given hex encoded digest, such
+// as to check a binary payload without keeping a copy of it.
+func (c ClientResponse) ExpectBodySHA256(t *testing.T, digest string) ClientResponse {
+	return c.ExpectChecksum(t, "sha256", digest)
+}
+
+// ExpectBodySize asserts that the body of the response is exactly the given number of bytes, after any content
+// coding is reversed.
+func (c ClientResponse) ExpectBodySize(t *testing.T, size int) ClientResponse {
+	if c.Error != nil {
 		return c
 	}
 
-	assert.Equal(t, err, c.Error, fmt.Sprintf("expected error with message `%v`, got error with message `%v`", err, c.Error))
+	assert.Equal(c.reporter(t), size, len(c.BodyBytes), fmt.Sprintf("expected body of %d bytes, got %d bytes instead", size, len(c.BodyBytes)))
 
 	return c
 }
 
-// Expect allows custom assertions to be run.
-// A error returned from the eval function will cause the test to be failed.
-func (c JSONResponse) Expect(t *testing.T, eval func(c JSONResponse) error) JSONResponse {
+// ExpectBodyContains asserts that the body of the response contains the given value.
+func (c ClientResponse) ExpectBodyContains(t *testing.T, value string) ClientResponse {
 	if c.Error != nil {
 		return c
 	}
 
-	err := eval(c)
+	assert.True(c.reporter(t), strings.Contains(c.Body, value), fmt.Sprintf("expected body to contain '%s', got '%s' instead", value, c.Body))
 
-	msg := ""
-	if err != nil {
-		msg = err.Error()
+	return c
+}
+
+// ExpectBodyNotContains asserts that the body of the response does not contain the given value.
+func (c ClientResponse) ExpectBodyNotContains(t *testing.T, value string) ClientResponse {
+	if c.Error != nil {
+		return c
 	}
-	assert.Nil(t, err, msg)
+
+	assert.False(c.reporter(t), strings.Contains(c.Body, value), fmt.Sprintf("expected body not to contain '%s'", value))
 
 	return c
 }
 
-// ExpectStatus asserts that a specific status code was received.
-func (c JSONResponse) ExpectStatus(t *testing.T, status int) JSONResponse {
+// ExpectBodyMatch asserts that the body of the response will pass the given regex test.
+func (c ClientResponse) ExpectBodyMatch(t *testing.T, re *regexp.Regexp) ClientResponse {
 	if c.Error != nil {
 		return c
 	}
 
-	assert.Equal(t, status, c.StatusCode, fmt.Sprintf("expected statuscode '%d', got '%d' instead", status, c.StatusCode))
+	assert.True(c.reporter(t), re.MatchString(c.Body), fmt.Sprintf("expect body match error: '%s' did not pass the regex test `%s`", c.Body, re.String()))
 
 	return c
 }
 
-// ExpectType asserts the data type at the given key will match the given JSON data type.
-func (c JSONResponse) ExpectType(t *testing.T, key, typ string) JSONResponse {
+// ExpectSnapshot asserts that the body is identical to the stored snapshot with the given name, kept in SnapshotDir.
+// When the UPDATE_SNAPSHOTS environment variable is set, the body is stored as the new snapshot instead.
+func (c ClientResponse) ExpectSnapshot(t *testing.T, name string) ClientResponse {
 	if c.Error != nil {
 		return c
 	}
 
-	r := c.Reader.Get(key)
+	stored, err := readSnapshot(name, []byte(c.Body))
+	if err != nil {
+		assert.Fail(c.reporter(t), "ExpectSnapshot: "+err.Error())
+		return c
+	}
 
-	// Allow for int or float when it's not important.
-	if typ == "number" && (r.Type == gojson.JSONInt || r.Type == gojson.JSONFloat) {
+	if diff := bodyDiff(string(stored), c.Body); len(diff) > 0 {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected body to match snapshot '%s':\n\t%s", name, strings.Join(diff, "\n\t")))
 		return c
 	}
 
-	assert.Equal(t, typ, r.Type, fmt.Sprintf("expected value at key `%s` to be `%s`, got `%s` instead", key, typ, r.Type))
+	assert.Equal(c.reporter(t), string(stored), c.Body, fmt.Sprintf("expected body to match snapshot '%s'", name))
 
 	return c
 }
 
-// ExpectTypes asserts the data type at the given key will match the given JSON data types.
-func (c JSONResponse) ExpectTypes(t *testing.T, key string, typ ...string) JSONResponse {
+// ExpectHeaderEmpty asserts that there was no header value set at a given key.
+func (c ClientResponse) ExpectHeaderEmpty(t *testing.T, key string) ClientResponse {
 	if c.Error != nil {
 		return c
 	}
 
-	r := c.Reader.Get(key)
+	if _, isset := c.header(key); !isset {
+		return c
+	}
 
-	for _, check := range typ {
-		if check == r.Type {
-			return c
-		}
+	assert.Fail(c.reporter(t), fmt.Sprintf("expected no header with key '%s' set", key))
 
-		// Allow for int or float when it's not important.
-		if check == "number" && (r.Type == gojson.JSONInt || r.Type == gojson.JSONFloat) {
-			return c
-		}
+	return c
+}
+
+// ExpectHeaderValue asserts that the header value at the given key will match the given value.
+func (c ClientResponse) ExpectHeaderValue(t *testing.T, key string, value string) ClientResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	actual, isset := c.header(key)
+	if !isset {
+		assert.True(c.reporter(t), isset, fmt.Sprintf("no header with key '%s' set", key))
+		return c
 	}
 
-	assert.Equal(t, typ, r.Type, fmt.Sprintf("expected value at key `%s` to be `%s`, got `%s` instead", key, strings.Join(typ, `, `), r.Type))
+	assert.Equal(c.reporter(t), value, actual, fmt.Sprintf("expected header '%s' to have value '%s', got '%s' instead", key, value, actual))
 
 	return c
 }
 
-// OptionalType differs from ExpectType in that it can only fail if the given key exists. If the key is missing entirely, the test will pass.
-func (c JSONResponse) OptionalType(t *testing.T, key, typ string) JSONResponse {
-	if !c.Reader.KeyExists(key) {
+// ExpectHeaderValueNot asserts that the header value at the given key does not match the given value. A missing header passes.
+func (c ClientResponse) ExpectHeaderValueNot(t *testing.T, key string, value string) ClientResponse {
+	if c.Error != nil {
 		return c
 	}
 
-	return c.ExpectType(t, key, typ)
+	actual, isset := c.header(key)
+	if !isset {
+		return c
+	}
+
+	assert.NotEqual(c.reporter(t), value, actual, fmt.Sprintf("expected header '%s' to have a value other than '%s'", key, value))
+
+	return c
 }
 
-// OptionalTypes differs from ExpectTypes in that it can only fail if the given key exists. If the key is missing entirely, the test will pass.
-func (c JSONResponse) OptionalTypes(t *testing.T, key string, typ ...string) JSONResponse {
-	if !c.Reader.KeyExists(key) {
+// ExpectHeaderValues asserts that the header at the given key was received with exactly the given values, in order.
+func (c ClientResponse) ExpectHeaderValues(t *testing.T, key string, values []string) ClientResponse {
+	if c.Error != nil {
 		return c
 	}
 
-	return c.ExpectTypes(t, key, typ...)
+	actual, isset := c.headerValues(key)
+	if !isset {
+		assert.True(c.reporter(t), isset, fmt.Sprintf("no header with key '%s' set", key))
+		return c
+	}
+
+	assert.Equal(c.reporter(t), values, actual, fmt.Sprintf("expected header '%s' to have values '%s', got '%s' instead", key, strings.Join(values, "', '"), strings.Join(actual, "', '")))
+
+	return c
 }
 
-// ExpectValue asserts the value at the given key will match the given value.
-func (c JSONResponse) ExpectValue(t *testing.T, key string, b interface{}) JSONResponse {
+// ExpectHeaderContains asserts that one of the values received for the header at the given key contains the given
+// substring, such as "application/json" in "application/json; charset=utf-8".
+func (c ClientResponse) ExpectHeaderContains(t *testing.T, key string, substr string) ClientResponse {
 	if c.Error != nil {
 		return c
 	}
 
-	a := c.Reader.GetInterface(key)
-	assert.Equal(t, b, a, fmt.Sprintf("expected '%s' to equal '%s'", b, a))
+	actual, isset := c.headerValues(key)
+	if !isset {
+		assert.True(c.reporter(t), isset, fmt.Sprintf("no header with key '%s' set", key))
+		return c
+	}
+
+	for _, v := range actual {
+		if strings.Contains(v, substr) {
+			return c
+		}
+	}
+
+	assert.Fail(c.reporter(t), fmt.Sprintf("expected header '%s' to contain '%s', got '%s' instead", key, substr, strings.Join(actual, "', '")))
 
 	return c
 }
 
-// ExpectValueString asserts the value at the given key will match the given value. All comparisons are done as string comparisons.
-func (c JSONResponse) ExpectValueString(t *testing.T, key, b string) JSONResponse {
+// OptionalHeaderValue differs from ExpectHeaderValue in that it can only fail if the given key exists. If the key is missing entirely, the test will pass.
+func (c ClientResponse) OptionalHeaderValue(t *testing.T, key string, value string) ClientResponse {
+	if _, isset := c.header(key); !isset {
+		return c
+	}
+
+	return c.ExpectHeaderValue(t, key, value)
+}
+
+// ExpectHeaderMatch asserts that the header value at the given key will match the given regular expression.
+func (c ClientResponse) ExpectHeaderMatch(t *testing.T, key string, re *regexp.Regexp) ClientResponse {
 	if c.Error != nil {
 		return c
 	}
 
-	a := c.Reader.GetString(key)
-	assert.Equal(t, b, a, fmt.Sprintf("expected '%s' to equal '%s'", b, a))
+	val, isset := c.header(key)
+	if !isset {
+		assert.True(c.reporter(t), isset, fmt.Sprintf("no header with key '%s' set", key))
+		return c
+	}
+
+	assert.True(c.reporter(t), re.Match([]byte(val)), fmt.Sprintf("expect header match error: '%s' did not pass the regex test `%s`", val, re.String()))
 
 	return c
 }
 
-// OptionalValue differs from ExpectValue in that it can only fail if the given key exists. If the key is missing entirely, the test will pass.
-func (c JSONResponse) OptionalValue(t *testing.T, key string, b interface{}) JSONResponse {
-	if !c.Reader.KeyExists(key) {
+// OptionalHeaderMatch differs from ExpectHeaderMatch in that it can only fail if the given key exists. If the key is missing entirely, the test will pass.
+func (c ClientResponse) OptionalHeaderMatch(t *testing.T, key string, re *regexp.Regexp) ClientResponse {
+	if _, isset := c.header(key); !isset {
 		return c
 	}
 
-	return c.ExpectValue(t, key, b)
+	return c.ExpectHeaderMatch(t, key, re)
 }
 
-// ExpectValueMatch asserts that the value at the given key will match the given regular expression.
-func (c JSONResponse) ExpectValueMatch(t *testing.T, key string, re *regexp.Regexp) JSONResponse {
+// ExpectCookie asserts that a cookie with the given name was set.
+func (c ClientResponse) ExpectCookie(t *testing.T, name string) ClientResponse {
 	if c.Error != nil {
 		return c
 	}
 
-	val := c.Reader.GetString(key)
-	assert.True(t, re.Match([]byte(val)), fmt.Sprintf("expect value match error: '%s' did not pass the regex test `%s`", val, re.String()))
+	assert.NotNil(c.reporter(t), c.cookie(name), fmt.Sprintf("no cookie with name '%s' set", name))
 
 	return c
 }
 
-// OptionalValueMatch differs from ExpectValueMatch in that it can only fail if the given key exists. If the key is missing entirely, the test will pass.
-func (c JSONResponse) OptionalValueMatch(t *testing.T, key string, re *regexp.Regexp) JSONResponse {
-	if !c.Reader.KeyExists(key) {
+// ExpectNoCookie asserts that no cookie with the given name was set.
+func (c ClientResponse) ExpectNoCookie(t *testing.T, name string) ClientResponse {
+	if c.Error != nil {
 		return c
 	}
 
-	return c.ExpectValueMatch(t, key, re)
+	assert.Nil(c.reporter(t), c.cookie(name), fmt.Sprintf("expected no cookie with name '%s' set", name))
+
+	return c
 }
 
-// ExpectValueCountCompare asserts the aggregate data type at the given key will have the given number of child nodes.
-func (c JSONResponse) ExpectValueCountCompare(t *testing.T, key string, comp string, count int) JSONResponse {
+// ExpectCookieValue asserts that the cookie with the given name will have the given value.
+func (c ClientResponse) ExpectCookieValue(t *testing.T, name string, value string) ClientResponse {
 	if c.Error != nil {
 		return c
 	}
 
-	r := c.Reader.Get(key)
+	cookie := c.cookie(name)
+	if cookie == nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("no cookie with name '%s' set", name))
+		return c
+	}
 
-	switch comp {
-	case "=":
-		assert.Equal(t, count, len(r.Keys), fmt.Sprintf("expected count to not be %d items, found %d", count, len(r.Keys)))
-	case "!=":
-		assert.NotEqual(t, count, len(r.Keys), fmt.Sprintf("expected exactly %d items, found %d", count, len(r.Keys)))
-	case ">":
-		assert.True(t, len(r.Keys) > count, fmt.Sprintf("expected more than %d items, found %d", count, len(r.Keys)))
-	case ">=":
-		assert.True(t, len(r.Keys) >= count, fmt.Sprintf("expected at least %d items, found %d", count, len(r.Keys)))
-	case "<":
-		assert.True(t, len(r.Keys) < count, fmt.Sprintf("expected less than %d items, found %d", count, len(r.Keys)))
-	case "<=":
-		assert.True(t, len(r.Keys) <= count, fmt.Sprintf("expected a minimum of %d items, found %d", count, len(r.Keys)))
+	assert.Equal(c.reporter(t), value, cookie.Value, fmt.Sprintf("expected cookie '%s' to have value '%s', got '%s' instead", name, value, cookie.Value))
+
+	return c
+}
 
+// ExpectCookieMatch asserts that the value of the cookie with the given name will match the given regular expression.
+func (c ClientResponse) ExpectCookieMatch(t *testing.T, name string, re *regexp.Regexp) ClientResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	cookie := c.cookie(name)
+	if cookie == nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("no cookie with name '%s' set", name))
+		return c
 	}
 
+	assert.True(c.reporter(t), re.MatchString(cookie.Value), fmt.Sprintf("expect cookie match error: '%s' did not pass the regex test `%s`", cookie.Value, re.String()))
+
 	return c
 }
 
-// ExpectValueCount asserts the aggregate data type at the given key will have the given number of child nodes.
-func (c JSONResponse) ExpectValueCount(t *testing.T, key string, count int) JSONResponse {
+// ExpectCookieHttpOnly asserts that the cookie with the given name was set with the HttpOnly attribute.
+func (c ClientResponse) ExpectCookieHttpOnly(t *testing.T, name string) ClientResponse {
 	if c.Error != nil {
 		return c
 	}
 
-	r := c.Reader.Get(key)
-	assert.Equal(t, count, len(r.Keys), fmt.Sprintf("expected exactly %d items, found %d", count, len(r.Keys)))
+	cookie := c.cookie(name)
+	if cookie == nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("no cookie with name '%s' set", name))
+		return c
+	}
+
+	assert.True(c.reporter(t), cookie.HttpOnly, fmt.Sprintf("expected cookie '%s' to be HttpOnly", name))
 
 	return c
 }
 
-// ExpectHeaderEmpty asserts that there was no header value set at a given key.
-func (c JSONResponse) ExpectHeaderEmpty(t *testing.T, key string) JSONResponse {
+// ExpectCookieSecure asserts that the cookie with the given name was set with the Secure attribute.
+func (c ClientResponse) ExpectCookieSecure(t *testing.T, name string) ClientResponse {
 	if c.Error != nil {
 		return c
 	}
 
-	if _, isset := c.Headers[key]; !isset {
+	cookie := c.cookie(name)
+	if cookie == nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("no cookie with name '%s' set", name))
 		return c
 	}
 
-	assert.Fail(t, fmt.Sprintf("expected no header with key '%s' set", key))
+	assert.True(c.reporter(t), cookie.Secure, fmt.Sprintf("expected cookie '%s' to be Secure", name))
 
 	return c
 }
 
-// ExpectHeaderValue asserts that the header value at the given key will match the given value.
-func (c JSONResponse) ExpectHeaderValue(t *testing.T, key string, value string) JSONResponse {
+// ExpectCookieMaxAge asserts that the cookie with the given name was set with the given Max-Age, in seconds.
+func (c ClientResponse) ExpectCookieMaxAge(t *testing.T, name string, maxAge int) ClientResponse {
 	if c.Error != nil {
 		return c
 	}
 
-	if _, isset := c.Headers[key]; !isset {
-		assert.True(t, isset, fmt.Sprintf("no header with key '%s' set", key))
+	cookie := c.cookie(name)
+	if cookie == nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("no cookie with name '%s' set", name))
 		return c
 	}
 
-	assert.Equal(t, value, c.Headers[key], fmt.Sprintf("expected header '%s' to have value '%s', got '%s' instead", key, value, c.Headers[key]))
+	assert.Equal(c.reporter(t), maxAge, cookie.MaxAge, fmt.Sprintf("expected cookie '%s' to have Max-Age %d, got %d instead", name, maxAge, cookie.MaxAge))
 
 	return c
 }
 
-// OptionalHeaderValue differs from ExpectHeaderValue in that it can only fail if the given key exists. If the key is missing entirely, the test will pass.
-func (c JSONResponse) OptionalHeaderValue(t *testing.T, key string, value string) JSONResponse {
-	if _, isset := c.Headers[key]; !isset {
+// ExpectCookieSameSite asserts that the cookie with the given name has the given SameSite mode.
+func (c ClientResponse) ExpectCookieSameSite(t *testing.T, name string, mode http.SameSite) ClientResponse {
+	if c.Error != nil {
 		return c
 	}
 
-	return c.ExpectHeaderValue(t, key, value)
+	cookie := c.cookie(name)
+	if cookie == nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("no cookie with name '%s' set", name))
+		return c
+	}
+
+	assert.Equal(c.reporter(t), mode, cookie.SameSite, fmt.Sprintf("expected cookie '%s' to have SameSite %s, got %s instead", name, sameSiteName(mode), sameSiteName(cookie.SameSite)))
+
+	return c
 }
 
-// ExpectHeaderMatch asserts that the header value at the given key will match the given regular expression.
-func (c JSONResponse) ExpectHeaderMatch(t *testing.T, key string, re *regexp.Regexp) JSONResponse {
+// ExpectCookieExpiresAfter asserts that the cookie with the given name expires after the given time, according to
+// its Max-Age or Expires attributes. Session cookies, which have neither, fail.
+func (c ClientResponse) ExpectCookieExpiresAfter(t *testing.T, name string, after time.Time) ClientResponse {
 	if c.Error != nil {
 		return c
 	}
 
-	if _, isset := c.Headers[key]; !isset {
-		assert.True(t, isset, fmt.Sprintf("no header with key '%s' set", key))
+	cookie := c.cookie(name)
+	if cookie == nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("no cookie with name '%s' set", name))
+		return c
+	}
+
+	expires, isset := cookieExpiry(cookie)
+	if !isset {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected cookie '%s' to expire after %s, but it is a session cookie", name, after.Format(time.RFC1123)))
 		return c
 	}
 
-	val := c.Headers[key]
-	assert.True(t, re.Match([]byte(val)), fmt.Sprintf("expect header match error: '%s' did not pass the regex test `%s`", val, re.String()))
+	assert.True(c.reporter(t), expires.After(after), fmt.Sprintf("expected cookie '%s' to expire after %s, expires %s instead", name, after.Format(time.RFC1123), expires.Format(time.RFC1123)))
 
 	return c
 }
 
-// OptionalHeaderMatch differs from ExpectHeaderMatch in that it can only fail if the given key exists. If the key is missing entirely, the test will pass.
-func (c JSONResponse) OptionalHeaderMatch(t *testing.T, key string, re *regexp.Regexp) JSONResponse {
-	if _, isset := c.Headers[key]; !isset {
-		return c
+// Header returns the first value received for the header at the given key. The key is matched case-insensitively.
+func (c ClientResponse) Header(key string) string {
+	val, _ := c.header(key)
+	return val
+}
+
+// HeaderValuesFor returns all values received for the header at the given key, in the order they were received,
+// such as each Set-Cookie or Vary header. The key is matched case-insensitively.
+func (c ClientResponse) HeaderValuesFor(key string) []string {
+	vals, _ := c.headerValues(key)
+	return vals
+}
+
+// header returns the first value received for the header at the given key, matched case-insensitively.
+// The bool return is false if no such header was received.
+func (c ClientResponse) header(key string) (string, bool) {
+	if val, isset := c.Headers[key]; isset {
+		return val, true
 	}
 
-	return c.ExpectHeaderMatch(t, key, re)
+	for k, val := range c.Headers {
+		if strings.EqualFold(k, key) {
+			return val, true
+		}
+	}
+
+	return "", false
+}
+
+// headerValues returns all values received for the header at the given key, matched case-insensitively.
+// The bool return is false if no such header was received.
+func (c ClientResponse) headerValues(key string) ([]string, bool) {
+	if vals, isset := c.HeaderValues[key]; isset {
+		return vals, true
+	}
+
+	for k, vals := range c.HeaderValues {
+		if strings.EqualFold(k, key) {
+			return vals, true
+		}
+	}
+
+	return nil, false
+}
+
+// cookie returns the last cookie set with the given name, or nil if there is none.
+func (c ClientResponse) cookie(name string) *http.Cookie {
+	var found *http.Cookie
+	for _, cookie := range c.Cookies {
+		if cookie.Name == name {
+			found = cookie
+		}
+	}
+
+	return found
+}
+
+// fieldOf returns the value of the given field of an element, or the element itself if the field is empty.
+func fieldOf(element *gojson.JSONReader, field string) (*gojson.JSONReader, bool) {
+	if field == "" {
+		return element, true
+	}
+
+	if !element.KeyExists(field) {
+		return nil, false
+	}
+
+	return element.Get(field), true
+}
+
+// compareValues compares two JSON values, returning -1, 0, or 1 as a is less than, equal to, or greater than b.
+// Only two numbers or two strings can be compared.
+func compareValues(a, b *gojson.JSONReader) (int, error) {
+	isNumber := func(r *gojson.JSONReader) bool { return r.Type == gojson.JSONInt || r.Type == gojson.JSONFloat }
+
+	switch {
+	case isNumber(a) && isNumber(b):
+		x, y := a.ToFloat(), b.ToFloat()
+		switch {
+		case x < y:
+			return -1, nil
+		case x > y:
+			return 1, nil
+		}
+		return 0, nil
+	case a.Type == gojson.JSONString && b.Type == gojson.JSONString:
+		return strings.Compare(a.ToString(), b.ToString()), nil
+	}
+
+	return 0, fmt.Errorf("can't compare `%s` with `%s`", a.Type, b.Type)
+}
+
+// cookieExpiry returns the time the given cookie expires. Max-Age takes precedence over Expires, and is counted from
+// now. The bool return is false for session cookies, which have neither.
+func cookieExpiry(cookie *http.Cookie) (time.Time, bool) {
+	switch {
+	case cookie.MaxAge > 0:
+		return time.Now().Add(time.Duration(cookie.MaxAge) * time.Second), true
+	case cookie.MaxAge < 0:
+		return time.Unix(0, 0), true
+	case !cookie.Expires.IsZero():
+		return cookie.Expires, true
+	}
+
+	return time.Time{}, false
+}
+
+// sameSiteName returns the name of the given SameSite mode, as it appears in a Set-Cookie header.
+func sameSiteName(mode http.SameSite) string {
+	switch mode {
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteNoneMode:
+		return "None"
+	}
+
+	return "unset"
+}
+
+// JSONResponse is a ClientResponse with added functionality specifically for dealing with json API responses.
+type JSONResponse struct {
+	ClientResponse
+	Reader *gojson.JSONReader `json:"-"`
+}
+
+// ExpectError is used to assert that a certain error condition has occured.
+func (c JSONResponse) ExpectError(t *testing.T, err error) JSONResponse {
+	// To avoid a panic inside assert, we will handle nil values explicitly
+	if err == nil {
+		if c.Error == nil {
+			return c
+		}
+
+		assert.True(c.reporter(t), false, fmt.Sprintf("expected no error, got error `%v` instead", c.Error))
+		return c
+	}
+
+	if c.Error == nil {
+		assert.True(c.reporter(t), false, fmt.Sprintf("expected error `%v`, had nil instead", err))
+		return c
+	}
+
+	assert.Equal(c.reporter(t), err, c.Error, fmt.Sprintf("expected error with message `%v`, got error with message `%v`", err, c.Error))
+
+	return c
+}
+
+// ExpectErrorIs is used to assert that the error condition that occured wraps the given error, such as ErrTimeout or ErrCanceled.
+func (c JSONResponse) ExpectErrorIs(t *testing.T, err error) JSONResponse {
+	if c.Error == nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected error `%v`, had nil instead", err))
+		return c
+	}
+
+	assert.True(c.reporter(t), errors.Is(c.Error, err), fmt.Sprintf("expected error wrapping `%v`, got error with message `%v`", err, c.Error))
+
+	return c
+}
+
+// Expect allows custom assertions to be run.
+// A error returned from the eval function will cause the test to be failed.
+func (c JSONResponse) Expect(t *testing.T, eval func(c JSONResponse) error) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	err := eval(c)
+
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	assert.Nil(c.reporter(t), err, msg)
+
+	return c
+}
+
+// ExpectStatus asserts that a specific status code was received.
+func (c JSONResponse) ExpectStatus(t *testing.T, status int) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.Equal(c.reporter(t), status, c.StatusCode, fmt.Sprintf("expected statuscode '%d', got '%d' instead", status, c.StatusCode))
+
+	return c
+}
+
+// ExpectStatusNot asserts that the status code received is not the given status code.
+func (c JSONResponse) ExpectStatusNot(t *testing.T, status int) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.NotEqual(c.reporter(t), status, c.StatusCode, fmt.Sprintf("expected statuscode other than '%d'", status))
+
+	return c
+}
+
+// ExpectStatusIn asserts that the status code received is one of the given status codes.
+func (c JSONResponse) ExpectStatusIn(t *testing.T, statuses ...int) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	for _, status := range statuses {
+		if c.StatusCode == status {
+			return c
+		}
+	}
+
+	assert.Fail(c.reporter(t), fmt.Sprintf("expected statuscode in %v, got '%d' instead", statuses, c.StatusCode))
+
+	return c
+}
+
+// ExpectStatusRange asserts that the status code received is between min and max, inclusive.
+func (c JSONResponse) ExpectStatusRange(t *testing.T, min, max int) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.True(c.reporter(t), c.StatusCode >= min && c.StatusCode <= max, fmt.Sprintf("expected statuscode between '%d' and '%d', got '%d' instead", min, max, c.StatusCode))
+
+	return c
+}
+
+// ExpectRequestTimeUnder asserts that the request took less than the given duration.
+func (c JSONResponse) ExpectRequestTimeUnder(t *testing.T, limit time.Duration) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.True(c.reporter(t), c.RequestDuration < limit, fmt.Sprintf("expected request to take less than %s, took %s instead", limit, c.RequestDuration))
+
+	return c
+}
+
+// ExpectProto asserts that the response was received over the given protocol, such as "HTTP/2.0".
+func (c JSONResponse) ExpectProto(t *testing.T, proto string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.Equal(c.reporter(t), proto, c.Proto, fmt.Sprintf("expected protocol '%s', got '%s' instead", proto, c.Proto))
+
+	return c
+}
+
+// ExpectType asserts the data type at the given key will match the given JSON data type.
+func (c JSONResponse) ExpectType(t *testing.T, key, typ string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if c.each(t, key, func(k string) { c.ExpectType(t, k, typ) }) {
+		return c
+	}
+
+	r := c.Reader.Get(key)
+
+	// Allow for int or float when it's not important.
+	if typ == "number" && (r.Type == gojson.JSONInt || r.Type == gojson.JSONFloat) {
+		return c
+	}
+
+	assert.Equal(c.reporter(t), typ, r.Type, fmt.Sprintf("expected value at key `%s` to be `%s`, got `%s` instead", key, typ, r.Type))
+
+	return c
+}
+
+// ExpectTypes asserts the data type at the given key will match the given JSON data types.
+func (c JSONResponse) ExpectTypes(t *testing.T, key string, typ ...string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if c.each(t, key, func(k string) { c.ExpectTypes(t, k, typ...) }) {
+		return c
+	}
+
+	r := c.Reader.Get(key)
+
+	for _, check := range typ {
+		if check == r.Type {
+			return c
+		}
+
+		// Allow for int or float when it's not important.
+		if check == "number" && (r.Type == gojson.JSONInt || r.Type == gojson.JSONFloat) {
+			return c
+		}
+	}
+
+	assert.Equal(c.reporter(t), typ, r.Type, fmt.Sprintf("expected value at key `%s` to be `%s`, got `%s` instead", key, strings.Join(typ, `, `), r.Type))
+
+	return c
+}
+
+// OptionalType differs from ExpectType in that it can only fail if the given key exists. If the key is missing entirely, the test will pass.
+func (c JSONResponse) OptionalType(t *testing.T, key, typ string) JSONResponse {
+	if !c.exists(key) {
+		return c
+	}
+
+	return c.ExpectType(t, key, typ)
+}
+
+// OptionalTypes differs from ExpectTypes in that it can only fail if the given key exists. If the key is missing entirely, the test will pass.
+func (c JSONResponse) OptionalTypes(t *testing.T, key string, typ ...string) JSONResponse {
+	if !c.exists(key) {
+		return c
+	}
+
+	return c.ExpectTypes(t, key, typ...)
+}
+
+// ExpectValue asserts the value at the given key will match the given value.
+func (c JSONResponse) ExpectValue(t *testing.T, key string, b interface{}) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if c.each(t, key, func(k string) { c.ExpectValue(t, k, b) }) {
+		return c
+	}
+
+	a := c.Reader.GetInterface(key)
+
+	// Objects and arrays are too large to read side by side, so list their differences by path instead.
+	if !assert.ObjectsAreEqual(b, a) {
+		if diff := valueDiff(key, b, a); len(diff) > 0 {
+			assert.Fail(c.reporter(t), fmt.Sprintf("expected value at key `%s` to match:\n\t%s", key, strings.Join(diff, "\n\t")))
+			return c
+		}
+	}
+
+	assert.Equal(c.reporter(t), b, a, fmt.Sprintf("expected '%s' to equal '%s'", b, a))
+
+	return c
+}
+
+// ExpectValueNot asserts the value at the given key will not match the given value. A missing key passes.
+func (c JSONResponse) ExpectValueNot(t *testing.T, key string, b interface{}) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if c.each(t, key, func(k string) { c.ExpectValueNot(t, k, b) }) {
+		return c
+	}
+
+	a := c.Reader.GetInterface(key)
+	assert.NotEqual(c.reporter(t), b, a, fmt.Sprintf("expected value at key `%s` not to equal '%v'", key, b))
+
+	return c
+}
+
+// ExpectValueIn asserts the value at the given key will match one of the allowed values, such as the members of an enum.
+// Values are compared as JSON: numbers match by value regardless of their type, so 1 matches int64(1) or 1.0, but
+// not 1.5 or the string "1".
+func (c JSONResponse) ExpectValueIn(t *testing.T, key string, allowed ...interface{}) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if c.each(t, key, func(k string) { c.ExpectValueIn(t, k, allowed...) }) {
+		return c
+	}
+
+	a := c.Reader.GetInterface(key)
+	for _, b := range allowed {
+		if jsonEqual(b, a) {
+			return c
+		}
+	}
+
+	assert.Fail(c.reporter(t), fmt.Sprintf("expected value at key `%s` to be one of %v, got '%v' instead", key, allowed, a))
+
+	return c
+}
+
+// ExpectValueString asserts the value at the given key will match the given value. All comparisons are done as string comparisons.
+func (c JSONResponse) ExpectValueString(t *testing.T, key, b string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if c.each(t, key, func(k string) { c.ExpectValueString(t, k, b) }) {
+		return c
+	}
+
+	a := c.Reader.GetString(key)
+	assert.Equal(c.reporter(t), b, a, fmt.Sprintf("expected '%s' to equal '%s'", b, a))
+
+	return c
+}
+
+// OptionalValue differs from ExpectValue in that it can only fail if the given key exists. If the key is missing entirely, the test will pass.
+func (c JSONResponse) OptionalValue(t *testing.T, key string, b interface{}) JSONResponse {
+	if !c.exists(key) {
+		return c
+	}
+
+	return c.ExpectValue(t, key, b)
+}
+
+// ExpectValueMatch asserts that the value at the given key will match the given regular expression.
+func (c JSONResponse) ExpectValueMatch(t *testing.T, key string, re *regexp.Regexp) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if c.each(t, key, func(k string) { c.ExpectValueMatch(t, k, re) }) {
+		return c
+	}
+
+	val := c.Reader.GetString(key)
+	assert.True(c.reporter(t), re.Match([]byte(val)), fmt.Sprintf("expect value match error: '%s' did not pass the regex test `%s`", val, re.String()))
+
+	return c
+}
+
+// OptionalValueMatch differs from ExpectValueMatch in that it can only fail if the given key exists. If the key is missing entirely, the test will pass.
+func (c JSONResponse) OptionalValueMatch(t *testing.T, key string, re *regexp.Regexp) JSONResponse {
+	if !c.exists(key) {
+		return c
+	}
+
+	return c.ExpectValueMatch(t, key, re)
+}
+
+// ExpectValueCountCompare asserts the aggregate data type at the given key will have the given number of child nodes.
+func (c JSONResponse) ExpectValueCountCompare(t *testing.T, key string, comp string, count int) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if c.each(t, key, func(k string) { c.ExpectValueCountCompare(t, k, comp, count) }) {
+		return c
+	}
+
+	r := c.Reader.Get(key)
+
+	switch comp {
+	case "=":
+		assert.Equal(c.reporter(t), count, len(r.Keys), fmt.Sprintf("expected count to not be %d items, found %d", count, len(r.Keys)))
+	case "!=":
+		assert.NotEqual(c.reporter(t), count, len(r.Keys), fmt.Sprintf("expected exactly %d items, found %d", count, len(r.Keys)))
+	case ">":
+		assert.True(c.reporter(t), len(r.Keys) > count, fmt.Sprintf("expected more than %d items, found %d", count, len(r.Keys)))
+	case ">=":
+		assert.True(c.reporter(t), len(r.Keys) >= count, fmt.Sprintf("expected at least %d items, found %d", count, len(r.Keys)))
+	case "<":
+		assert.True(c.reporter(t), len(r.Keys) < count, fmt.Sprintf("expected less than %d items, found %d", count, len(r.Keys)))
+	case "<=":
+		assert.True(c.reporter(t), len(r.Keys) <= count, fmt.Sprintf("expected a minimum of %d items, found %d", count, len(r.Keys)))
+
+	}
+
+	return c
+}
+
+// ExpectValueCompare asserts that the numeric value at the given key compares to the given value using the given operator.
+// Supported operators are =, !=, >, >=, <, and <=.
+func (c JSONResponse) ExpectValueCompare(t *testing.T, key string, comp string, value float64) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if c.each(t, key, func(k string) { c.ExpectValueCompare(t, k, comp, value) }) {
+		return c
+	}
+
+	r := c.Reader.Get(key)
+	if r.Type != gojson.JSONInt && r.Type != gojson.JSONFloat {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected value at key `%s` to be a number, got `%s` instead", key, r.Type))
+		return c
+	}
+
+	a := c.Reader.GetFloat(key)
+
+	switch comp {
+	case "=":
+		assert.Equal(c.reporter(t), value, a, fmt.Sprintf("expected value at key `%s` to be %v, found %v", key, value, a))
+	case "!=":
+		assert.NotEqual(c.reporter(t), value, a, fmt.Sprintf("expected value at key `%s` to not be %v", key, value))
+	case ">":
+		assert.True(c.reporter(t), a > value, fmt.Sprintf("expected value at key `%s` to be greater than %v, found %v", key, value, a))
+	case ">=":
+		assert.True(c.reporter(t), a >= value, fmt.Sprintf("expected value at key `%s` to be at least %v, found %v", key, value, a))
+	case "<":
+		assert.True(c.reporter(t), a < value, fmt.Sprintf("expected value at key `%s` to be less than %v, found %v", key, value, a))
+	case "<=":
+		assert.True(c.reporter(t), a <= value, fmt.Sprintf("expected value at key `%s` to be at most %v, found %v", key, value, a))
+	default:
+		assert.Fail(c.reporter(t), fmt.Sprintf("unknown comparison operator '%s'", comp))
+	}
+
+	return c
+}
+
+// OptionalValueCompare differs from ExpectValueCompare in that it can only fail if the given key exists. If the key is missing entirely, the test will pass.
+func (c JSONResponse) OptionalValueCompare(t *testing.T, key string, comp string, value float64) JSONResponse {
+	if !c.exists(key) {
+		return c
+	}
+
+	return c.ExpectValueCompare(t, key, comp, value)
+}
+
+// ExpectValueGreaterThan asserts that the numeric value at the given key is greater than the given value.
+// The value may be any integer or floating point type.
+func (c JSONResponse) ExpectValueGreaterThan(t *testing.T, key string, value interface{}) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	v, err := cast.ToFloat64E(value)
+	if err != nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected a number to compare key `%s` to, got '%v' instead", key, value))
+		return c
+	}
+
+	return c.ExpectValueCompare(t, key, ">", v)
+}
+
+// ExpectValueLessThan asserts that the numeric value at the given key is less than the given value.
+// The value may be any integer or floating point type.
+func (c JSONResponse) ExpectValueLessThan(t *testing.T, key string, value interface{}) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	v, err := cast.ToFloat64E(value)
+	if err != nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected a number to compare key `%s` to, got '%v' instead", key, value))
+		return c
+	}
+
+	return c.ExpectValueCompare(t, key, "<", v)
+}
+
+// ExpectValueBetween asserts that the numeric value at the given key is between min and max, inclusive.
+// The bounds may be any integer or floating point type.
+func (c JSONResponse) ExpectValueBetween(t *testing.T, key string, min, max interface{}) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if c.each(t, key, func(k string) { c.ExpectValueBetween(t, k, min, max) }) {
+		return c
+	}
+
+	lo, err := cast.ToFloat64E(min)
+	if err != nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected a number to compare key `%s` to, got '%v' instead", key, min))
+		return c
+	}
+
+	hi, err := cast.ToFloat64E(max)
+	if err != nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected a number to compare key `%s` to, got '%v' instead", key, max))
+		return c
+	}
+
+	r := c.Reader.Get(key)
+	if r.Type != gojson.JSONInt && r.Type != gojson.JSONFloat {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected value at key `%s` to be a number, got `%s` instead", key, r.Type))
+		return c
+	}
+
+	return c.ExpectValueCompare(t, key, ">=", lo).ExpectValueCompare(t, key, "<=", hi)
+}
+
+// ExpectValueTime asserts that the value at the given key is a string which parses as a time in the given layout,
+// as understood by time.Parse. An empty layout defaults to time.RFC3339.
+func (c JSONResponse) ExpectValueTime(t *testing.T, key, layout string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if c.each(t, key, func(k string) { c.ExpectValueTime(t, k, layout) }) {
+		return c
+	}
+
+	if _, err := c.timeAt(key, layout); err != nil {
+		assert.Fail(c.reporter(t), err.Error())
+	}
+
+	return c
+}
+
+// ExpectValueTimeBetween asserts that the value at the given key is a time in the given layout, as in ExpectValueTime,
+// which falls between start and end, inclusive.
+func (c JSONResponse) ExpectValueTimeBetween(t *testing.T, key, layout string, start, end time.Time) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if c.each(t, key, func(k string) { c.ExpectValueTimeBetween(t, k, layout, start, end) }) {
+		return c
+	}
+
+	ts, err := c.timeAt(key, layout)
+	if err != nil {
+		assert.Fail(c.reporter(t), err.Error())
+		return c
+	}
+
+	assert.True(c.reporter(t), !ts.Before(start) && !ts.After(end), fmt.Sprintf("expected time at key `%s` to be between %s and %s, got %s instead",
+		key, start.Format(time.RFC3339Nano), end.Format(time.RFC3339Nano), ts.Format(time.RFC3339Nano)))
+
+	return c
+}
+
+// timeAt parses the string value at the given key as a time in the given layout, defaulting to time.RFC3339.
+func (c JSONResponse) timeAt(key, layout string) (time.Time, error) {
+	if layout == "" {
+		layout = time.RFC3339
+	}
+
+	r := c.Reader.Get(key)
+	if r.Type != gojson.JSONString {
+		return time.Time{}, fmt.Errorf("expected value at key `%s` to be a time, got `%s` instead", key, r.Type)
+	}
+
+	ts, err := time.Parse(layout, r.ToString())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected value at key `%s` to be a time in the layout '%s', got '%s' instead", key, layout, r.ToString())
+	}
+
+	return ts, nil
+}
+
+// ExpectValueCount asserts the aggregate data type at the given key will have the given number of child nodes.
+func (c JSONResponse) ExpectValueCount(t *testing.T, key string, count int) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if c.each(t, key, func(k string) { c.ExpectValueCount(t, k, count) }) {
+		return c
+	}
+
+	r := c.Reader.Get(key)
+	assert.Equal(c.reporter(t), count, len(r.Keys), fmt.Sprintf("expected exactly %d items, found %d", count, len(r.Keys)))
+
+	return c
+}
+
+// ExpectEach runs the given evaluation function against each element of the array at the given key, failing the test
+// with the index of every element for which the function returns an error.
+func (c JSONResponse) ExpectEach(t *testing.T, key string, eval func(element *gojson.JSONReader) error) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if c.each(t, key, func(k string) { c.ExpectEach(t, k, eval) }) {
+		return c
+	}
+
+	r := c.Reader.Get(key)
+	if r.Type != gojson.JSONArray {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected value at key `%s` to be `array`, got `%s` instead", key, r.Type))
+		return c
+	}
+
+	for i, element := range members(r) {
+		if err := eval(&element); err != nil {
+			assert.Fail(c.reporter(t), fmt.Sprintf("element %d of key `%s`: %s", i, key, err.Error()))
+		}
+	}
+
+	return c
+}
+
+// ExpectThat asserts the value at the given key satisfies the given Matcher. A missing key fails.
+func (c JSONResponse) ExpectThat(t *testing.T, key string, m Matcher) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if c.each(t, key, func(k string) { c.ExpectThat(t, k, m) }) {
+		return c
+	}
+
+	if !c.Reader.KeyExists(key) {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected value at key `%s` to be %s, but the key does not exist", key, m.Describe()))
+		return c
+	}
+
+	if err := m.Match(c.Reader.GetInterface(key)); err != nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected value at key `%s` to be %s: %s", key, m.Describe(), err.Error()))
+	}
+
+	return c
+}
+
+// State describes the presence and content of the value at a given key.
+type State int
+
+const (
+	// Absent denotes a key that does not exist.
+	Absent State = iota
+
+	// Null denotes a key that exists with the value null.
+	Null
+
+	// Empty denotes a key that exists with an empty array, object, or string.
+	Empty
+
+	// NonEmpty denotes a key that exists with any other value.
+	NonEmpty
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case Absent:
+		return "absent"
+	case Null:
+		return "null"
+	case Empty:
+		return "empty"
+	case NonEmpty:
+		return "non-empty"
+	}
+
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// state returns the State of the value at the given key.
+func (c JSONResponse) state(key string) State {
+	if !c.Reader.KeyExists(key) {
+		return Absent
+	}
+
+	r := c.Reader.Get(key)
+
+	switch r.Type {
+	case gojson.JSONNull:
+		return Null
+	case gojson.JSONArray, gojson.JSONObject:
+		if len(r.Keys) == 0 {
+			return Empty
+		}
+	case gojson.JSONString:
+		if r.ToString() == "" {
+			return Empty
+		}
+	}
+
+	return NonEmpty
+}
+
+// ExpectState asserts that the value at the given key is in exactly the given state.
+func (c JSONResponse) ExpectState(t *testing.T, key string, state State) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	actual := c.state(key)
+	assert.Equal(c.reporter(t), state, actual, fmt.Sprintf("expected value at key `%s` to be %s, found %s instead", key, state, actual))
+
+	return c
+}
+
+// SortOrder is the direction in which an array is sorted.
+type SortOrder int
+
+const (
+	// Ascending denotes an array sorted from the lowest value to the highest.
+	Ascending SortOrder = iota
+
+	// Descending denotes an array sorted from the highest value to the lowest.
+	Descending
+)
+
+// String returns the name of the sort order.
+func (o SortOrder) String() string {
+	switch o {
+	case Ascending:
+		return "ascending"
+	case Descending:
+		return "descending"
+	}
+
+	return fmt.Sprintf("SortOrder(%d)", int(o))
+}
+
+// ExpectSorted asserts that the array at the given key is sorted in the given order by the value of the given field of
+// each element. An empty field sorts by the elements themselves. Numbers are compared by value and strings
+// lexicographically; equal neighbours are allowed.
+func (c JSONResponse) ExpectSorted(t *testing.T, arrayKey, fieldKey string, order SortOrder) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if c.each(t, arrayKey, func(k string) { c.ExpectSorted(t, k, fieldKey, order) }) {
+		return c
+	}
+
+	r := c.Reader.Get(arrayKey)
+	if r.Type != gojson.JSONArray {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected value at key `%s` to be `array`, got `%s` instead", arrayKey, r.Type))
+		return c
+	}
+
+	elements := members(r)
+	for i := 1; i < len(elements); i++ {
+		prev, ok := fieldOf(&elements[i-1], fieldKey)
+		if !ok {
+			assert.Fail(c.reporter(t), fmt.Sprintf("element %d of key `%s` has no field `%s`", i-1, arrayKey, fieldKey))
+			return c
+		}
+		next, ok := fieldOf(&elements[i], fieldKey)
+		if !ok {
+			assert.Fail(c.reporter(t), fmt.Sprintf("element %d of key `%s` has no field `%s`", i, arrayKey, fieldKey))
+			return c
+		}
+
+		result, err := compareValues(prev, next)
+		if err != nil {
+			assert.Fail(c.reporter(t), fmt.Sprintf("unable to sort elements %d and %d of key `%s`: %s", i-1, i, arrayKey, err.Error()))
+			return c
+		}
+
+		if (order == Ascending && result > 0) || (order == Descending && result < 0) {
+			by := ""
+			if fieldKey != "" {
+				by = fmt.Sprintf(" by `%s`", fieldKey)
+			}
+
+			assert.Fail(c.reporter(t), fmt.Sprintf("expected array at key `%s` to be sorted %s%s, but element %d (%s) is followed by element %d (%s)",
+				arrayKey, order, by, i-1, jsonString(prev.GetInterface("")), i, jsonString(next.GetInterface(""))))
+			return c
+		}
+	}
+
+	return c
+}
+
+// ExpectUnique asserts that no two elements of the array at the given key have the same value for the given field,
+// such as duplicate IDs in a listing. An empty field compares the elements themselves. Every duplicate is reported.
+func (c JSONResponse) ExpectUnique(t *testing.T, arrayKey, fieldKey string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if c.each(t, arrayKey, func(k string) { c.ExpectUnique(t, k, fieldKey) }) {
+		return c
+	}
+
+	r := c.Reader.Get(arrayKey)
+	if r.Type != gojson.JSONArray {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected value at key `%s` to be `array`, got `%s` instead", arrayKey, r.Type))
+		return c
+	}
+
+	seen := make(map[string]int)
+	for i, element := range members(r) {
+		value, ok := fieldOf(&element, fieldKey)
+		if !ok {
+			assert.Fail(c.reporter(t), fmt.Sprintf("element %d of key `%s` has no field `%s`", i, arrayKey, fieldKey))
+			continue
+		}
+
+		// Numbers are keyed by value, so that 1 and 1.0 are duplicates.
+		id := jsonString(value.GetInterface(""))
+		if value.Type == gojson.JSONInt || value.Type == gojson.JSONFloat {
+			id = strconv.FormatFloat(value.ToFloat(), 'g', -1, 64)
+		}
+
+		if first, isset := seen[id]; isset {
+			assert.Fail(c.reporter(t), fmt.Sprintf("expected unique values in array at key `%s`, but element %d duplicates element %d with %s", arrayKey, i, first, jsonString(value.GetInterface(""))))
+			continue
+		}
+		seen[id] = i
+	}
+
+	return c
+}
+
+// ExpectContentLengthAccurate asserts that the number of bytes received matches the declared Content-Length.
+// Responses that did not declare a Content-Length, such as chunked responses, always pass.
+func (c JSONResponse) ExpectContentLengthAccurate(t *testing.T) JSONResponse {
+	if c.Error != nil || c.ContentLength < 0 {
+		return c
+	}
+
+	assert.Equal(c.reporter(t), c.ContentLength, c.BytesReceived, fmt.Sprintf("expected %d bytes from Content-Length, received %d", c.ContentLength, c.BytesReceived))
+
+	return c
+}
+
+// ExpectBodyEquals asserts that the body of the response is exactly the given value.
+func (c JSONResponse) ExpectBodyEquals(t *testing.T, body string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if diff := bodyDiff(body, c.Body); len(diff) > 0 {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected body to equal the given value:\n\t%s", strings.Join(diff, "\n\t")))
+		return c
+	}
+
+	assert.Equal(c.reporter(t), body, c.Body, fmt.Sprintf("expected body '%s', got '%s' instead", body, c.Body))
+
+	return c
+}
+
+// ExpectBodyContains asserts that the body of the response contains the given value.
+func (c JSONResponse) ExpectBodyContains(t *testing.T, value string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.True(c.reporter(t), strings.Contains(c.Body, value), fmt.Sprintf("expected body to contain '%s', got '%s' instead", value, c.Body))
+
+	return c
+}
+
+// ExpectBodyNotContains asserts that the body of the response does not contain the given value.
+func (c JSONResponse) ExpectBodyNotContains(t *testing.T, value string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.False(c.reporter(t), strings.Contains(c.Body, value), fmt.Sprintf("expected body not to contain '%s'", value))
+
+	return c
+}
+
+// ExpectBodyMatch asserts that the body of the response will pass the given regex test.
+func (c JSONResponse) ExpectBodyMatch(t *testing.T, re *regexp.Regexp) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.True(c.reporter(t), re.MatchString(c.Body), fmt.Sprintf("expect body match error: '%s' did not pass the regex test `%s`", c.Body, re.String()))
+
+	return c
+}
+
+// ExpectHeaderEmpty asserts that there was no header value set at a given key.
+func (c JSONResponse) ExpectHeaderEmpty(t *testing.T, key string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if _, isset := c.header(key); !isset {
+		return c
+	}
+
+	assert.Fail(c.reporter(t), fmt.Sprintf("expected no header with key '%s' set", key))
+
+	return c
+}
+
+// ExpectHeaderValue asserts that the header value at the given key will match the given value.
+func (c JSONResponse) ExpectHeaderValue(t *testing.T, key string, value string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	actual, isset := c.header(key)
+	if !isset {
+		assert.True(c.reporter(t), isset, fmt.Sprintf("no header with key '%s' set", key))
+		return c
+	}
+
+	assert.Equal(c.reporter(t), value, actual, fmt.Sprintf("expected header '%s' to have value '%s', got '%s' instead", key, value, actual))
+
+	return c
+}
+
+// ExpectHeaderValueNot asserts that the header value at the given key does not match the given value. A missing header passes.
+func (c JSONResponse) ExpectHeaderValueNot(t *testing.T, key string, value string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	actual, isset := c.header(key)
+	if !isset {
+		return c
+	}
+
+	assert.NotEqual(c.reporter(t), value, actual, fmt.Sprintf("expected header '%s' to have a value other than '%s'", key, value))
+
+	return c
+}
+
+// ExpectHeaderValues asserts that the header at the given key was received with exactly the given values, in order.
+func (c JSONResponse) ExpectHeaderValues(t *testing.T, key string, values []string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	actual, isset := c.headerValues(key)
+	if !isset {
+		assert.True(c.reporter(t), isset, fmt.Sprintf("no header with key '%s' set", key))
+		return c
+	}
+
+	assert.Equal(c.reporter(t), values, actual, fmt.Sprintf("expected header '%s' to have values '%s', got '%s' instead", key, strings.Join(values, "', '"), strings.Join(actual, "', '")))
+
+	return c
+}
+
+// ExpectHeaderContains asserts that one of the values received for the header at the given key contains the given
+// substring, such as "application/json" in "application/json; charset=utf-8".
+func (c JSONResponse) ExpectHeaderContains(t *testing.T, key string, substr string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	actual, isset := c.headerValues(key)
+	if !isset {
+		assert.True(c.reporter(t), isset, fmt.Sprintf("no header with key '%s' set", key))
+		return c
+	}
+
+	for _, v := range actual {
+		if strings.Contains(v, substr) {
+			return c
+		}
+	}
+
+	assert.Fail(c.reporter(t), fmt.Sprintf("expected header '%s' to contain '%s', got '%s' instead", key, substr, strings.Join(actual, "', '")))
+
+	return c
+}
+
+// OptionalHeaderValue differs from ExpectHeaderValue in that it can only fail if the given key exists. If the key is missing entirely, the test will pass.
+func (c JSONResponse) OptionalHeaderValue(t *testing.T, key string, value string) JSONResponse {
+	if _, isset := c.header(key); !isset {
+		return c
+	}
+
+	return c.ExpectHeaderValue(t, key, value)
+}
+
+// ExpectHeaderMatch asserts that the header value at the given key will match the given regular expression.
+func (c JSONResponse) ExpectHeaderMatch(t *testing.T, key string, re *regexp.Regexp) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	val, isset := c.header(key)
+	if !isset {
+		assert.True(c.reporter(t), isset, fmt.Sprintf("no header with key '%s' set", key))
+		return c
+	}
+
+	assert.True(c.reporter(t), re.Match([]byte(val)), fmt.Sprintf("expect header match error: '%s' did not pass the regex test `%s`", val, re.String()))
+
+	return c
+}
+
+// OptionalHeaderMatch differs from ExpectHeaderMatch in that it can only fail if the given key exists. If the key is missing entirely, the test will pass.
+func (c JSONResponse) OptionalHeaderMatch(t *testing.T, key string, re *regexp.Regexp) JSONResponse {
+	if _, isset := c.header(key); !isset {
+		return c
+	}
+
+	return c.ExpectHeaderMatch(t, key, re)
+}
+
+// ExpectCookie asserts that a cookie with the given name was set.
+func (c JSONResponse) ExpectCookie(t *testing.T, name string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.NotNil(c.reporter(t), c.cookie(name), fmt.Sprintf("no cookie with name '%s' set", name))
+
+	return c
+}
+
+// ExpectNoCookie asserts that no cookie with the given name was set.
+func (c JSONResponse) ExpectNoCookie(t *testing.T, name string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.Nil(c.reporter(t), c.cookie(name), fmt.Sprintf("expected no cookie with name '%s' set", name))
+
+	return c
+}
+
+// ExpectCookieValue asserts that the cookie with the given name will have the given value.
+func (c JSONResponse) ExpectCookieValue(t *testing.T, name string, value string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	cookie := c.cookie(name)
+	if cookie == nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("no cookie with name '%s' set", name))
+		return c
+	}
+
+	assert.Equal(c.reporter(t), value, cookie.Value, fmt.Sprintf("expected cookie '%s' to have value '%s', got '%s' instead", name, value, cookie.Value))
+
+	return c
+}
+
+// ExpectCookieMatch asserts that the value of the cookie with the given name will match the given regular expression.
+func (c JSONResponse) ExpectCookieMatch(t *testing.T, name string, re *regexp.Regexp) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	cookie := c.cookie(name)
+	if cookie == nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("no cookie with name '%s' set", name))
+		return c
+	}
+
+	assert.True(c.reporter(t), re.MatchString(cookie.Value), fmt.Sprintf("expect cookie match error: '%s' did not pass the regex test `%s`", cookie.Value, re.String()))
+
+	return c
+}
+
+// ExpectCookieHttpOnly asserts that the cookie with the given name was set with the HttpOnly attribute.
+func (c JSONResponse) ExpectCookieHttpOnly(t *testing.T, name string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	cookie := c.cookie(name)
+	if cookie == nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("no cookie with name '%s' set", name))
+		return c
+	}
+
+	assert.True(c.reporter(t), cookie.HttpOnly, fmt.Sprintf("expected cookie '%s' to be HttpOnly", name))
+
+	return c
+}
+
+// ExpectCookieSecure asserts that the cookie with the given name was set with the Secure attribute.
+func (c JSONResponse) ExpectCookieSecure(t *testing.T, name string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	cookie := c.cookie(name)
+	if cookie == nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("no cookie with name '%s' set", name))
+		return c
+	}
+
+	assert.True(c.reporter(t), cookie.Secure, fmt.Sprintf("expected cookie '%s' to be Secure", name))
+
+	return c
+}
+
+// ExpectCookieMaxAge asserts that the cookie with the given name was set with the given Max-Age, in seconds.
+func (c JSONResponse) ExpectCookieMaxAge(t *testing.T, name string, maxAge int) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	cookie := c.cookie(name)
+	if cookie == nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("no cookie with name '%s' set", name))
+		return c
+	}
+
+	assert.Equal(c.reporter(t), maxAge, cookie.MaxAge, fmt.Sprintf("expected cookie '%s' to have Max-Age %d, got %d instead", name, maxAge, cookie.MaxAge))
+
+	return c
+}
+
+// ExpectCookieSameSite asserts that the cookie with the given name has the given SameSite mode.
+func (c JSONResponse) ExpectCookieSameSite(t *testing.T, name string, mode http.SameSite) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	cookie := c.cookie(name)
+	if cookie == nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("no cookie with name '%s' set", name))
+		return c
+	}
+
+	assert.Equal(c.reporter(t), mode, cookie.SameSite, fmt.Sprintf("expected cookie '%s' to have SameSite %s, got %s instead", name, sameSiteName(mode), sameSiteName(cookie.SameSite)))
+
+	return c
+}
+
+// ExpectCookieExpiresAfter asserts that the cookie with the given name expires after the given time, according to
+// its Max-Age or Expires attributes. Session cookies, which have neither, fail.
+func (c JSONResponse) ExpectCookieExpiresAfter(t *testing.T, name string, after time.Time) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	cookie := c.cookie(name)
+	if cookie == nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("no cookie with name '%s' set", name))
+		return c
+	}
+
+	expires, isset := cookieExpiry(cookie)
+	if !isset {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected cookie '%s' to expire after %s, but it is a session cookie", name, after.Format(time.RFC1123)))
+		return c
+	}
+
+	assert.True(c.reporter(t), expires.After(after), fmt.Sprintf("expected cookie '%s' to expire after %s, expires %s instead", name, after.Format(time.RFC1123), expires.Format(time.RFC1123)))
+
+	return c
+}
+
+// Capture stores the value at the given key into dest, so that it can be used in subsequent requests.
+func (c JSONResponse) Capture(key string, dest *interface{}) JSONResponse {
+	*dest = c.Reader.GetInterface(key)
+
+	return c
+}
+
+// CaptureString stores the value at the given key into dest as a string, so that it can be used in subsequent requests.
+func (c JSONResponse) CaptureString(key string, dest *string) JSONResponse {
+	*dest = c.Reader.GetString(key)
+
+	return c
+}
+
+// Values returns a snapshot of the top-level values of the response body.
+func (c JSONResponse) Values() map[string]interface{} {
+	return c.Reader.ToMapStringInterface()
+}
+
+// ExpectSchema asserts that the body matches the response schema declared in the swagger doc for the operation at the
+// given path specifier and status code. Every violation is reported, along with the key at which it occurred.
+func (c JSONResponse) ExpectSchema(t *testing.T, specifier string, statusCode int) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if c.client == nil {
+		assert.Fail(c.reporter(t), "ExpectSchema: response was not returned by a Client")
+		return c
+	}
+
+	schema, err := c.client.responseSchema(specifier, statusCode, c.Header("Content-Type"))
+	if err != nil {
+		assert.Fail(c.reporter(t), err.Error())
+		return c
+	}
+
+	violations, err := validateBody(c.client.resolver, schema, []byte(c.Body))
+	if err != nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected body to match schema for '%s' %d: %s", specifier, statusCode, err.Error()))
+		return c
+	}
+
+	if len(violations) > 0 {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected body to match schema for '%s' %d:\n\t%s", specifier, statusCode, strings.Join(violations, "\n\t")))
+	}
+
+	return c
+}
+
+// ExpectJSONSchema asserts that the body matches the given JSON Schema, independent of the swagger document. The schema
+// may be given as the path or URL of a schema file, or as a []byte holding the schema itself. Draft-07 and 2020-12
+// schemas are supported, including references to $defs and to other files. On mismatch, every violation is listed.
+func (c JSONResponse) ExpectJSONSchema(t *testing.T, schema interface{}) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	rr, ref, err := loadSchema(schema)
+	if err != nil {
+		assert.Fail(c.reporter(t), "ExpectJSONSchema: "+err.Error())
+		return c
+	}
+
+	violations, err := validateBody(rr, ref, []byte(c.Body))
+	if err != nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected body to match schema: %s", err.Error()))
+		return c
+	}
+
+	if len(violations) > 0 {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected body to match schema:\n\t%s", strings.Join(violations, "\n\t")))
+	}
+
+	return c
+}
+
+// ExpectJSONEquals asserts that the body is structurally equal to the expected JSON document, which may be given as
+// a string, a []byte, or any value that marshals to JSON. Keys or paths given in ignore, such as timestamps or
+// generated IDs, are skipped along with everything beneath them; "*" matches any single key or array index.
+// On mismatch, every difference is listed by path.
+func (c JSONResponse) ExpectJSONEquals(t *testing.T, expected interface{}, ignore ...string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	exp, err := toJSONValue(expected)
+	if err != nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("ExpectJSONEquals: expected document is not valid JSON: %s", err.Error()))
+		return c
+	}
+
+	actual, err := decodeJSON([]byte(c.Body))
+	if err != nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected body to equal the expected JSON document: body is not valid JSON: %s", err.Error()))
+		return c
+	}
+
+	if diff := jsonDiff("", exp, actual, ignore); len(diff) > 0 {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected body to equal the expected JSON document:\n\t%s", strings.Join(diff, "\n\t")))
+	}
+
+	return c
+}
+
+// ExpectSnapshot asserts that the body matches the stored snapshot with the given name, kept in SnapshotDir. The body
+// is normalized before comparison, with keys sorted and consistent indentation, and the values of any ignored paths
+// replaced, as in ExpectJSONEquals. When the UPDATE_SNAPSHOTS environment variable is set, the normalized body is
+// stored as the new snapshot instead. On mismatch, every difference is listed by path.
+func (c JSONResponse) ExpectSnapshot(t *testing.T, name string, ignore ...string) JSONResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	normalized, err := normalizeSnapshot([]byte(c.Body), ignore)
+	if err != nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected body to match snapshot '%s': body is not valid JSON: %s", name, err.Error()))
+		return c
+	}
+
+	stored, err := readSnapshot(name, normalized)
+	if err != nil {
+		assert.Fail(c.reporter(t), "ExpectSnapshot: "+err.Error())
+		return c
+	}
+
+	if bytes.Equal(stored, normalized) {
+		return c
+	}
+
+	expected, err := decodeJSON(stored)
+	if err != nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("ExpectSnapshot: snapshot '%s' is not valid JSON: %s", snapshotPath(name), err.Error()))
+		return c
+	}
+
+	actual, _ := decodeJSON(normalized)
+	if diff := jsonDiff("", expected, actual, ignore); len(diff) > 0 {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected body to match snapshot '%s':\n\t%s", name, strings.Join(diff, "\n\t")))
+	}
+
+	return c
+}
+
+// NDJSONResponse is the set of records returned from a newline-delimited JSON response, in the order received.
+type NDJSONResponse []JSONResponse
+
+// ExpectRecordCount asserts that exactly the given number of records were received.
+func (c NDJSONResponse) ExpectRecordCount(t *testing.T, n int) NDJSONResponse {
+	assert.Equal(t, n, len(c), fmt.Sprintf("expected exactly %d records, found %d", n, len(c)))
+
+	return c
 }
@@ -0,0 +1,82 @@
+package gointegration
+
+import (
+	"testing"
+	"time"
+)
+
+// Scenario is a multi-step workflow, such as creating, fetching, updating, and deleting a resource, run against a
+// single Client. Each step makes its requests and assertions, and steps share values through the Client's variable
+// store, by CaptureAs and Variable. Steps run in order as subtests, and a scenario stops at the first step to fail.
+type Scenario struct {
+	Name string
+
+	client *Client
+	steps  []scenarioStep
+}
+
+// scenarioStep is a single named step of a Scenario.
+type scenarioStep struct {
+	name string
+	run  func(t *testing.T, c *Client)
+}
+
+// StepResult is the outcome of a single step of a Scenario. Skipped steps were not run, as an earlier step failed.
+type StepResult struct {
+	Name     string
+	Passed   bool
+	Skipped  bool
+	Duration time.Duration
+}
+
+// Scenario returns a new, empty Scenario with the given name, run against the Client.
+func (sc *Client) Scenario(name string) *Scenario {
+	return &Scenario{Name: name, client: sc}
+}
+
+// Step adds a step with the given name to the end of the scenario.
+func (s *Scenario) Step(name string, run func(t *testing.T, c *Client)) *Scenario {
+	s.steps = append(s.steps, scenarioStep{name: name, run: run})
+
+	return s
+}
+
+// Run runs the scenario as a subtest of t, with each step as a subtest of it, stopping at the first step to fail.
+// The Client's BeforeAll hooks are run first, if they haven't been.
+// The time taken by each step is logged, and the result of every step, including any skipped, is returned.
+func (s *Scenario) Run(t *testing.T) []StepResult {
+	results := make([]StepResult, 0, len(s.steps))
+
+	t.Run(s.Name, func(t *testing.T) {
+		if err := s.client.ensureBeforeAll(); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		failed := false
+		for _, step := range s.steps {
+			if failed {
+				results = append(results, StepResult{Name: step.name, Skipped: true})
+				t.Logf("step '%s' skipped", step.name)
+				continue
+			}
+
+			start := time.Now()
+			passed := t.Run(step.name, func(t *testing.T) {
+				step.run(t, s.client)
+			})
+			elapsed := time.Since(start)
+
+			results = append(results, StepResult{Name: step.name, Passed: passed, Duration: elapsed})
+
+			if passed {
+				t.Logf("step '%s' passed in %s", step.name, elapsed)
+				continue
+			}
+
+			t.Logf("step '%s' failed in %s", step.name, elapsed)
+			failed = true
+		}
+	})
+
+	return results
+}
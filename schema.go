@@ -0,0 +1,608 @@
+package gointegration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btm6084/gojson"
+)
+
+// maxSchemaDepth bounds how deeply schemas are followed, so that recursive schemas can't loop forever.
+const maxSchemaDepth = 64
+
+// schemaRef is a schema node, along with the document it was found in so that its references can be resolved.
+type schemaRef struct {
+	doc  document
+	node *gojson.JSONReader
+}
+
+// responseSchema returns the schema declared for the response to the operation at the given path specifier, with the
+// given status code and content type. Status codes fall back to their range (e.g. "2XX"), and then to "default".
+func (sc *Client) responseSchema(specifier string, statusCode int, contentType string) (schemaRef, error) {
+	_, _, route, err := sc.lookup(specifier)
+	if err != nil {
+		return schemaRef{}, err
+	}
+
+	code := strconv.Itoa(statusCode)
+	spec, isset := route.Responses[code]
+	if !isset {
+		spec, isset = route.Responses[code[:1]+"XX"]
+	}
+	if !isset {
+		spec, isset = route.Responses["default"]
+	}
+	if !isset {
+		return schemaRef{}, fmt.Errorf("ExpectSchema: Route %s declares no response for status %d", specifier, statusCode)
+	}
+
+	if len(spec.schemas) == 0 {
+		return schemaRef{}, fmt.Errorf("ExpectSchema: Route %s declares no schema for status %d", specifier, statusCode)
+	}
+
+	// Prefer the schema for the media type received, ignoring parameters such as charset.
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	if schema, isset := spec.schemas[mediaType]; isset {
+		return schema, nil
+	}
+
+	if schema, isset := spec.schemas[""]; isset {
+		return schema, nil
+	}
+
+	// Otherwise, fall back to the first media type declared.
+	for _, t := range spec.Produces {
+		if schema, isset := spec.schemas[t]; isset {
+			return schema, nil
+		}
+	}
+
+	return schemaRef{}, fmt.Errorf("ExpectSchema: Route %s declares no schema for status %d", specifier, statusCode)
+}
+
+// loadSchema loads a standalone JSON Schema, given either as the path or URL of a schema file or as the schema
+// document itself. References within it are resolved relative to the file it was loaded from.
+func loadSchema(schema interface{}) (*refResolver, schemaRef, error) {
+	rr := &refResolver{docs: map[string]document{}}
+
+	var doc document
+	switch s := schema.(type) {
+	case string:
+		var err error
+		doc, err = rr.load(s)
+		if err != nil {
+			return nil, schemaRef{}, fmt.Errorf("unable to load schema from '%s': %s", s, err.Error())
+		}
+	case []byte:
+		if !json.Valid(s) {
+			return nil, schemaRef{}, fmt.Errorf("schema is not valid JSON")
+		}
+		reader, err := gojson.NewJSONReader(s)
+		if err != nil {
+			return nil, schemaRef{}, fmt.Errorf("schema is not valid JSON: %s", err.Error())
+		}
+		doc = document{reader: reader}
+		rr.docs[""] = doc
+	default:
+		return nil, schemaRef{}, fmt.Errorf("schema must be a path or a []byte, got %T", schema)
+	}
+
+	return rr, schemaRef{doc: doc, node: doc.reader}, nil
+}
+
+// validateBody validates the given JSON body against the given schema, returning a description of each violation.
+func validateBody(rr *refResolver, schema schemaRef, body []byte) ([]string, error) {
+	var value interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("body is not valid JSON: %s", err.Error())
+	}
+
+	v := schemaValidator{rr: rr}
+	v.validate("", schema, value, 0)
+
+	return v.violations, nil
+}
+
+// schemaValidator validates decoded JSON values against JSON Schema, as used by Swagger and OpenAPI documents.
+type schemaValidator struct {
+	rr         *refResolver
+	violations []string
+}
+
+// fail records a violation at the given key.
+func (v *schemaValidator) fail(key string, format string, args ...interface{}) {
+	if key == "" {
+		key = "(root)"
+	}
+
+	v.violations = append(v.violations, fmt.Sprintf("`%s`: %s", key, fmt.Sprintf(format, args...)))
+}
+
+// validate validates the value at the given key against the given schema.
+func (v *schemaValidator) validate(key string, schema schemaRef, value interface{}, depth int) {
+	if depth > maxSchemaDepth {
+		return
+	}
+
+	if v.rr != nil {
+		var err error
+		schema.doc, schema.node, err = v.rr.resolve(schema.doc, schema.node)
+		if err != nil {
+			v.fail(key, "%s", err.Error())
+			return
+		}
+	}
+	s := schema.node
+
+	// Boolean schemas either allow or forbid everything.
+	if s.Type == gojson.JSONBool {
+		if !s.ToBool() {
+			v.fail(key, "no value is allowed")
+		}
+		return
+	}
+
+	sub := func(k string) schemaRef {
+		return schemaRef{doc: schema.doc, node: s.Get(k)}
+	}
+
+	actual := jsonType(value)
+
+	if value == nil && (s.GetBool("nullable") || s.GetBool("x-nullable")) {
+		return
+	}
+
+	if s.KeyExists("type") {
+		types := s.GetStringSlice("type")
+		if !typeAllowed(types, actual) {
+			v.fail(key, "expected type %s, got %s", strings.Join(types, " or "), actual)
+			return
+		}
+	}
+
+	if s.KeyExists("enum") {
+		found := false
+		for _, e := range s.GetInterfaceSlice("enum") {
+			if jsonEqual(e, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(key, "value %s is not one of the allowed values %s", jsonString(value), string(s.GetByteSlice("enum")))
+		}
+	}
+
+	for _, k := range []string{"allOf", "anyOf", "oneOf"} {
+		if !s.KeyExists(k) {
+			continue
+		}
+
+		branches := members(s.Get(k))
+		passed := 0
+		for i := range branches {
+			branch := schemaValidator{rr: v.rr}
+			branch.validate(key, schemaRef{doc: schema.doc, node: &branches[i]}, value, depth+1)
+
+			if len(branch.violations) == 0 {
+				passed++
+			} else if k == "allOf" {
+				v.violations = append(v.violations, branch.violations...)
+			}
+		}
+
+		switch {
+		case k == "anyOf" && passed == 0:
+			v.fail(key, "value does not match any of the schemas in anyOf")
+		case k == "oneOf" && passed != 1:
+			v.fail(key, "value matches %d of the schemas in oneOf, expected exactly 1", passed)
+		}
+	}
+
+	if s.KeyExists("const") {
+		if expected := s.GetInterface("const"); !jsonEqual(expected, value) {
+			v.fail(key, "expected value %s, got %s", jsonString(expected), jsonString(value))
+		}
+	}
+
+	// A value which matches the if schema must also match then, while one which doesn't must match else.
+	if s.KeyExists("if") {
+		branch := schemaValidator{rr: v.rr}
+		branch.validate(key, sub("if"), value, depth+1)
+
+		next := "else"
+		if len(branch.violations) == 0 {
+			next = "then"
+		}
+		if s.KeyExists(next) {
+			v.validate(key, sub(next), value, depth+1)
+		}
+	}
+
+	if s.KeyExists("not") {
+		branch := schemaValidator{rr: v.rr}
+		branch.validate(key, sub("not"), value, depth+1)
+		if len(branch.violations) == 0 {
+			v.fail(key, "value must not match the schema in not")
+		}
+	}
+
+	switch val := value.(type) {
+	case json.Number:
+		v.validateNumber(key, s, val)
+	case string:
+		v.validateString(key, s, val)
+	case []interface{}:
+		v.validateArray(key, schema, val, depth)
+	case map[string]interface{}:
+		v.validateObject(key, schema, val, depth)
+	}
+}
+
+// validateNumber validates numeric keywords.
+func (v *schemaValidator) validateNumber(key string, s *gojson.JSONReader, val json.Number) {
+	n, err := val.Float64()
+	if err != nil {
+		return
+	}
+
+	if s.KeyExists("minimum") {
+		min := s.GetFloat("minimum")
+		if r := s.Get("exclusiveMinimum"); r.Type == gojson.JSONBool && r.ToBool() && n <= min {
+			v.fail(key, "expected value greater than %v, got %v", min, val)
+		} else if n < min {
+			v.fail(key, "expected value of at least %v, got %v", min, val)
+		}
+	}
+
+	if s.KeyExists("maximum") {
+		max := s.GetFloat("maximum")
+		if r := s.Get("exclusiveMaximum"); r.Type == gojson.JSONBool && r.ToBool() && n >= max {
+			v.fail(key, "expected value less than %v, got %v", max, val)
+		} else if n > max {
+			v.fail(key, "expected value of at most %v, got %v", max, val)
+		}
+	}
+
+	// Newer drafts give exclusive bounds as numbers, rather than as modifiers of minimum and maximum.
+	if r := s.Get("exclusiveMinimum"); r.Type == gojson.JSONInt || r.Type == gojson.JSONFloat {
+		if min := r.ToFloat(); n <= min {
+			v.fail(key, "expected value greater than %v, got %v", min, val)
+		}
+	}
+
+	if r := s.Get("exclusiveMaximum"); r.Type == gojson.JSONInt || r.Type == gojson.JSONFloat {
+		if max := r.ToFloat(); n >= max {
+			v.fail(key, "expected value less than %v, got %v", max, val)
+		}
+	}
+
+	if s.KeyExists("multipleOf") {
+		m := s.GetFloat("multipleOf")
+		if q := n / m; m != 0 && math.Abs(q-math.Round(q)) > 1e-9 {
+			v.fail(key, "expected a multiple of %v, got %v", m, val)
+		}
+	}
+}
+
+// validateString validates string keywords.
+func (v *schemaValidator) validateString(key string, s *gojson.JSONReader, val string) {
+	length := len([]rune(val))
+
+	if s.KeyExists("minLength") && length < s.GetInt("minLength") {
+		v.fail(key, "expected a length of at least %d, got %d", s.GetInt("minLength"), length)
+	}
+
+	if s.KeyExists("maxLength") && length > s.GetInt("maxLength") {
+		v.fail(key, "expected a length of at most %d, got %d", s.GetInt("maxLength"), length)
+	}
+
+	if s.KeyExists("pattern") {
+		re, err := regexp.Compile(s.GetString("pattern"))
+		if err == nil && !re.MatchString(val) {
+			v.fail(key, "'%s' does not match the pattern `%s`", val, re.String())
+		}
+	}
+
+	if format := s.GetString("format"); format != "" && !formatValid(format, val) {
+		v.fail(key, "'%s' is not a valid %s", val, format)
+	}
+}
+
+// uuidPattern matches a UUID in its canonical hyphenated form.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// formatValid returns true if the given string is valid for the given format. Formats which are not recognized,
+// including numeric formats such as int32, are always valid.
+func formatValid(format, val string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, val)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", val)
+		return err == nil
+	case "time":
+		_, err := time.Parse("15:04:05Z07:00", val)
+		return err == nil
+	case "email":
+		_, err := mail.ParseAddress(val)
+		return err == nil && !strings.ContainsAny(val, "<> ")
+	case "uuid":
+		return uuidPattern.MatchString(val)
+	case "uri":
+		u, err := url.Parse(val)
+		return err == nil && u.Scheme != ""
+	case "ipv4":
+		ip := net.ParseIP(val)
+		return ip != nil && ip.To4() != nil && !strings.Contains(val, ":")
+	case "ipv6":
+		ip := net.ParseIP(val)
+		return ip != nil && strings.Contains(val, ":")
+	}
+
+	return true
+}
+
+// validateArray validates array keywords, and each element against the items schema.
+func (v *schemaValidator) validateArray(key string, schema schemaRef, val []interface{}, depth int) {
+	s := schema.node
+
+	if s.KeyExists("minItems") && len(val) < s.GetInt("minItems") {
+		v.fail(key, "expected at least %d items, found %d", s.GetInt("minItems"), len(val))
+	}
+
+	if s.KeyExists("maxItems") && len(val) > s.GetInt("maxItems") {
+		v.fail(key, "expected at most %d items, found %d", s.GetInt("maxItems"), len(val))
+	}
+
+	if s.GetBool("uniqueItems") {
+		for i := range val {
+			for j := i + 1; j < len(val); j++ {
+				if jsonEqual(val[i], val[j]) {
+					v.fail(key, "expected unique items, items %d and %d are equal", i, j)
+				}
+			}
+		}
+	}
+
+	if s.KeyExists("contains") {
+		matched := 0
+		for _, item := range val {
+			branch := schemaValidator{rr: v.rr}
+			branch.validate(key, schemaRef{doc: schema.doc, node: s.Get("contains")}, item, depth+1)
+			if len(branch.violations) == 0 {
+				matched++
+			}
+		}
+
+		min := 1
+		if s.KeyExists("minContains") {
+			min = s.GetInt("minContains")
+		}
+		if matched < min {
+			v.fail(key, "expected at least %d items matching the schema in contains, found %d", min, matched)
+		}
+		if s.KeyExists("maxContains") && matched > s.GetInt("maxContains") {
+			v.fail(key, "expected at most %d items matching the schema in contains, found %d", s.GetInt("maxContains"), matched)
+		}
+	}
+
+	// Positional schemas are given by prefixItems in draft 2020-12, and by an array of items in earlier drafts.
+	// Items beyond them are validated against items or additionalItems respectively.
+	var positional []gojson.JSONReader
+	rest := s.Get("items")
+	switch {
+	case s.KeyExists("prefixItems"):
+		positional = members(s.Get("prefixItems"))
+	case rest.Type == gojson.JSONArray:
+		positional = members(rest)
+		rest = s.Get("additionalItems")
+	}
+
+	for i, item := range val {
+		itemKey := joinKey(key, strconv.Itoa(i))
+
+		if i < len(positional) {
+			v.validate(itemKey, schemaRef{doc: schema.doc, node: &positional[i]}, item, depth+1)
+			continue
+		}
+
+		switch {
+		case rest.Type == gojson.JSONObject:
+			v.validate(itemKey, schemaRef{doc: schema.doc, node: rest}, item, depth+1)
+		case rest.Type == gojson.JSONBool && !rest.ToBool():
+			v.fail(itemKey, "item is not allowed")
+		}
+	}
+}
+
+// validateObject validates object keywords, and each property against its schema.
+func (v *schemaValidator) validateObject(key string, schema schemaRef, val map[string]interface{}, depth int) {
+	s := schema.node
+
+	for _, name := range s.GetStringSlice("required") {
+		if _, isset := val[name]; !isset {
+			v.fail(joinKey(key, name), "required property is missing")
+		}
+	}
+
+	// Properties listed in dependentRequired require others to be present alongside them.
+	deps := s.Get("dependentRequired")
+	for i, dep := range members(deps) {
+		if _, isset := val[deps.Keys[i]]; !isset {
+			continue
+		}
+		for _, name := range dep.GetStringSlice("") {
+			if _, isset := val[name]; !isset {
+				v.fail(joinKey(key, name), "property is required when %s is present", deps.Keys[i])
+			}
+		}
+	}
+
+	if s.KeyExists("minProperties") && len(val) < s.GetInt("minProperties") {
+		v.fail(key, "expected at least %d properties, found %d", s.GetInt("minProperties"), len(val))
+	}
+
+	if s.KeyExists("maxProperties") && len(val) > s.GetInt("maxProperties") {
+		v.fail(key, "expected at most %d properties, found %d", s.GetInt("maxProperties"), len(val))
+	}
+
+	props := s.Get("properties")
+	propSchemas := members(props)
+	declared := make(map[string]*gojson.JSONReader, len(props.Keys))
+	for i := range propSchemas {
+		declared[props.Keys[i]] = &propSchemas[i]
+	}
+
+	// Validate in a stable order, so that violations are always reported the same way.
+	names := make([]string, 0, len(val))
+	for name := range val {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if prop, isset := declared[name]; isset {
+			v.validate(joinKey(key, name), schemaRef{doc: schema.doc, node: prop}, val[name], depth+1)
+			continue
+		}
+
+		if v.validatePatternProperties(key, schema, name, val[name], depth) {
+			continue
+		}
+
+		if !s.KeyExists("additionalProperties") {
+			continue
+		}
+
+		additional := s.Get("additionalProperties")
+		if additional.Type == gojson.JSONBool && !additional.ToBool() {
+			v.fail(joinKey(key, name), "property is not allowed")
+			continue
+		}
+
+		v.validate(joinKey(key, name), schemaRef{doc: schema.doc, node: additional}, val[name], depth+1)
+	}
+}
+
+// validatePatternProperties validates the property with the given name against each of the patternProperties whose
+// pattern it matches, returning true if there were any.
+func (v *schemaValidator) validatePatternProperties(key string, schema schemaRef, name string, value interface{}, depth int) bool {
+	patterns := schema.node.Get("patternProperties")
+	matched := false
+
+	for i, prop := range members(patterns) {
+		re, err := regexp.Compile(patterns.Keys[i])
+		if err != nil || !re.MatchString(name) {
+			continue
+		}
+
+		matched = true
+		v.validate(joinKey(key, name), schemaRef{doc: schema.doc, node: &prop}, value, depth+1)
+	}
+
+	return matched
+}
+
+// joinKey appends a child key to the given key, in the dotted form used by JSONResponse assertions.
+func joinKey(key, child string) string {
+	if key == "" {
+		return child
+	}
+
+	return key + "." + child
+}
+
+// jsonType returns the JSON Schema type of a decoded JSON value.
+func jsonType(value interface{}) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if f, err := val.Float64(); err == nil && f == math.Trunc(f) && !strings.ContainsAny(val.String(), ".eE") {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+
+	return reflect.TypeOf(value).String()
+}
+
+// typeAllowed returns true if the given JSON Schema type satisfies any of the allowed types.
+func typeAllowed(allowed []string, actual string) bool {
+	for _, t := range allowed {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// jsonEqual returns true if the two values are equal as JSON, regardless of how their numbers are represented.
+func jsonEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(normalizeJSON(a), normalizeJSON(b))
+}
+
+// normalizeJSON converts all numbers within a decoded JSON value to float64, so that values can be compared. Numbers
+// given as any Go integer type are converted as well, as may be given as expected values.
+func normalizeJSON(value interface{}) interface{} {
+	switch val := value.(type) {
+	case json.Number:
+		f, _ := val.Float64()
+		return f
+	case int, int8, int16, int32, int64:
+		return float64(reflect.ValueOf(val).Int())
+	case uint, uint8, uint16, uint32, uint64:
+		return float64(reflect.ValueOf(val).Uint())
+	case float32:
+		return float64(val)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i := range val {
+			out[i] = normalizeJSON(val[i])
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k := range val {
+			out[k] = normalizeJSON(val[k])
+		}
+		return out
+	}
+
+	return value
+}
+
+// jsonString returns the JSON encoding of a decoded JSON value, for use in messages.
+func jsonString(value interface{}) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(b)
+}
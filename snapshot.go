@@ -0,0 +1,104 @@
+package gointegration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+var (
+	// SnapshotDir is the directory in which ExpectSnapshot stores its golden files, relative to the directory of the
+	// test being run. Each snapshot is stored as <name>.golden, and names may contain "/" to use subdirectories.
+	SnapshotDir = filepath.Join("testdata", "snapshots")
+
+	// snapshotRedacted replaces values at ignored paths in a normalized snapshot.
+	snapshotRedacted = "<ignored>"
+)
+
+// updateSnapshots returns true if the UPDATE_SNAPSHOTS environment variable is set, in which case ExpectSnapshot
+// writes the body it receives as the new snapshot rather than comparing against the stored one.
+func updateSnapshots() bool {
+	update, _ := strconv.ParseBool(os.Getenv("UPDATE_SNAPSHOTS"))
+	return update
+}
+
+// snapshotPath returns the path of the golden file for the snapshot with the given name.
+func snapshotPath(name string) string {
+	return filepath.Join(SnapshotDir, filepath.FromSlash(name)+".golden")
+}
+
+// readSnapshot returns the stored snapshot with the given name. In update mode, the given data is written as the
+// snapshot first.
+func readSnapshot(name string, data []byte) ([]byte, error) {
+	path := snapshotPath(name)
+
+	if updateSnapshots() {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("unable to create snapshot directory: %s", err.Error())
+		}
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			return nil, fmt.Errorf("unable to write snapshot '%s': %s", path, err.Error())
+		}
+		return data, nil
+	}
+
+	stored, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("snapshot '%s' does not exist; run with UPDATE_SNAPSHOTS=1 to create it", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read snapshot '%s': %s", path, err.Error())
+	}
+
+	return stored, nil
+}
+
+// normalizeSnapshot re-encodes the given JSON document with sorted keys and consistent indentation, replacing the
+// values at any of the ignored paths, so that snapshots are stable across runs and readable in a diff.
+func normalizeSnapshot(data []byte, ignore []string) ([]byte, error) {
+	var value interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(redact("", value, ignore)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// redact returns the given decoded JSON value with every value at one of the ignored paths replaced.
+func redact(key string, value interface{}, ignore []string) interface{} {
+	if ignored(key, ignore) {
+		return snapshotRedacted
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, v := range val {
+			out[k] = redact(joinKey(key, k), v, ignore)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, v := range val {
+			out[i] = redact(joinKey(key, strconv.Itoa(i)), v, ignore)
+		}
+		return out
+	}
+
+	return value
+}
@@ -0,0 +1,152 @@
+package gointegration
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The namespaces of SOAP 1.1 and SOAP 1.2 envelopes.
+const (
+	soap11Namespace = "http://schemas.xmlsoap.org/soap/envelope/"
+	soap12Namespace = "http://www.w3.org/2003/05/soap-envelope"
+)
+
+// WithSOAP12 makes ExecSOAP send SOAP 1.2 envelopes, rather than SOAP 1.1. Refer to Client.SOAP12.
+func WithSOAP12() Option {
+	return func(sc *Client) error {
+		sc.SOAP12 = true
+		return nil
+	}
+}
+
+// ExecSOAP POSTs the given body, wrapped in a SOAP envelope, to the given path, which need not be declared in the
+// swagger doc, for the given SOAP action, returning the response as an XMLResponse. The body is sent as-is if it is a
+// []byte or string, and is otherwise marshalled with encoding/xml. When the Client has Templates set, placeholders
+// in a string body, such as {{.userID}}, are filled in from the variable store. As with ExecRaw, the path is not
+// prefixed with the BasePath.
+//
+// The envelope is SOAP 1.1, with the action in the SOAPAction header, unless the Client has SOAP12 set, in which
+// case it is SOAP 1.2, with the action in the Content-Type. Elements of the response are found by their local name,
+// such as "/Envelope/Body/GetUserResponse/name".
+func (sc *Client) ExecSOAP(path, action string, body interface{}) XMLResponse {
+	return sc.ExecSOAPWithHeader(path, action, nil, body)
+}
+
+// ExecSOAPWithHeader behaves as ExecSOAP, additionally sending the given header within the Header of the envelope,
+// such as for WS-Security. The header is given as the body is.
+func (sc *Client) ExecSOAPWithHeader(path, action string, header, body interface{}) XMLResponse {
+	fail := func(err error) XMLResponse {
+		return XMLResponse{ClientResponse: ClientResponse{Error: fmt.Errorf("ExecSOAP: %s", err.Error())}, Document: &XMLNode{}}
+	}
+
+	h, err := sc.soapContent(header)
+	if err != nil {
+		return fail(err)
+	}
+
+	b, err := sc.soapContent(body)
+	if err != nil {
+		return fail(err)
+	}
+
+	namespace := soap11Namespace
+	contentType := "text/xml; charset=utf-8"
+	headers := map[string]string{"SOAPAction": strconv.Quote(action)}
+	if sc.SOAP12 {
+		namespace = soap12Namespace
+		contentType = "application/soap+xml; charset=utf-8; action=" + strconv.Quote(action)
+		headers = nil
+	}
+
+	envelope := xml.Header + `<soap:Envelope xmlns:soap="` + namespace + `">`
+	if h != "" {
+		envelope += "<soap:Header>" + h + "</soap:Header>"
+	}
+	envelope += "<soap:Body>" + b + "</soap:Body></soap:Envelope>"
+
+	return toXMLResponse(sc.ExecRaw(http.MethodPost, path, RequestOptions{
+		Headers:     headers,
+		Body:        []byte(envelope),
+		ContentType: contentType,
+	}))
+}
+
+// soapContent returns the given header or body content of a SOAP envelope as XML.
+func (sc *Client) soapContent(content interface{}) (string, error) {
+	switch c := content.(type) {
+	case nil:
+		return "", nil
+	case []byte:
+		return string(c), nil
+	case string:
+		return sc.render(c)
+	}
+
+	b, err := xml.Marshal(content)
+	if err != nil {
+		return "", fmt.Errorf("Marshal of body failed with message: %s", err.Error())
+	}
+
+	return string(b), nil
+}
+
+// soapFault returns the code and reason of the SOAP fault in the Body of the response, of either SOAP 1.1 or 1.2,
+// and false if it has none.
+func (c XMLResponse) soapFault() (string, string, bool) {
+	faults, _ := c.Document.Find("/Envelope/Body/Fault")
+	if len(faults) == 0 {
+		return "", "", false
+	}
+
+	value := func(xpath string) string {
+		nodes, _ := faults[0].Find(xpath)
+		if len(nodes) == 0 {
+			return ""
+		}
+		return nodes[0].Value()
+	}
+
+	if code := value("faultcode"); code != "" {
+		return code, value("faultstring"), true
+	}
+
+	return value("Code/Value"), value("Reason/Text"), true
+}
+
+// ExpectNoSOAPFault asserts that the response is not a SOAP fault.
+func (c XMLResponse) ExpectNoSOAPFault(t *testing.T) XMLResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if code, reason, found := c.soapFault(); found {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected no SOAP fault, got fault `%s`: %s", code, reason))
+	}
+
+	return c
+}
+
+// ExpectSOAPFault asserts that the response is a SOAP fault with the given code, such as "soap:Client", or
+// "Client", which matches regardless of the namespace prefix.
+func (c XMLResponse) ExpectSOAPFault(t *testing.T, code string) XMLResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	actual, reason, found := c.soapFault()
+	if !found {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected SOAP fault `%s`, got no fault", code))
+		return c
+	}
+
+	if actual != code && localName(actual) != code {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected SOAP fault `%s`, got fault `%s` instead: %s", code, actual, reason))
+	}
+
+	return c
+}
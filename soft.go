@@ -0,0 +1,194 @@
+package gointegration
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// softAssertions collects the failures of assertions made on a response in soft mode, so that they can be
+// reported together by AssertAll rather than as each assertion is made.
+type softAssertions struct {
+	mu       sync.Mutex
+	failures []string
+}
+
+// Errorf records a failure. It implements assert.TestingT.
+func (s *softAssertions) Errorf(format string, args ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = append(s.failures, fmt.Sprintf(format, args...))
+}
+
+// pending returns the number of failures recorded since the last report. A nil softAssertions has none.
+func (s *softAssertions) pending() int {
+	if s == nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.failures)
+}
+
+// report fails the test with every failure recorded since the last report, if there were any.
+func (s *softAssertions) report(t *testing.T) {
+	s.mu.Lock()
+	failures := s.failures
+	s.failures = nil
+	s.mu.Unlock()
+
+	if len(failures) == 0 {
+		return
+	}
+
+	noun := "assertions"
+	if len(failures) == 1 {
+		noun = "assertion"
+	}
+
+	t.Errorf("%d soft %s failed:\n%s", len(failures), noun, strings.Join(failures, "\n"))
+}
+
+// reporter returns where the failures of assertions on the response are sent: the test itself, or in soft mode,
+// the collected failures. If the Client has a Recorder, the assertion is recorded too, and if it has CurlOnFailure or
+// Tracing, the first failure is given the curl command or trace ID of the request.
+func (c ClientResponse) reporter(t *testing.T) assert.TestingT {
+	var out assert.TestingT = t
+	if c.soft != nil {
+		out = c.soft
+	}
+
+	if c.client != nil && c.request != nil && (c.client.CurlOnFailure || c.TraceID != "") {
+		out = &failureContext{TestingT: out, resp: c}
+	}
+
+	if rec := c.recorder(); rec != nil {
+		return rec.track(out, testName(t), assertionName(), c)
+	}
+
+	return out
+}
+
+// failureContext is the TestingT given to assertions on a response when the Client has CurlOnFailure set, or the
+// request was traced. The curl command and trace ID of the request are only added to the first failure, which is
+// tracked by the failures of the response, as it is shared by copies of the response.
+type failureContext struct {
+	assert.TestingT
+
+	resp ClientResponse
+}
+
+// Errorf forwards the failure, with the context of the request added if it is the first. It implements
+// assert.TestingT.
+func (f *failureContext) Errorf(format string, args ...interface{}) {
+	first := false
+	f.resp.failures.Do(func() { first = true })
+
+	if !first {
+		f.TestingT.Errorf(format, args...)
+		return
+	}
+
+	if f.resp.TraceID != "" {
+		format += "\nTrace ID: %s"
+		args = append(args, f.resp.TraceID)
+	}
+
+	if f.resp.client.CurlOnFailure {
+		format += "\nReproduce with:\n%s"
+		args = append(args, curlCommand(f.resp.request, f.resp.client.secretHeaders(), f.resp.client.secretQuery()))
+	}
+
+	f.TestingT.Errorf(format, args...)
+}
+
+// Soft puts the response in soft mode, in which failed assertions are collected rather than reported to the test as
+// they are made. AssertAll must be called at the end of the chain to report them, all together.
+func (c ClientResponse) Soft() ClientResponse {
+	c.soft = &softAssertions{}
+
+	return c
+}
+
+// AssertAll fails the test with every assertion that has failed since Soft, or the last call to AssertAll.
+func (c ClientResponse) AssertAll(t *testing.T) ClientResponse {
+	if c.soft != nil {
+		c.soft.report(t)
+	}
+
+	return c
+}
+
+// Soft puts the response in soft mode, in which failed assertions are collected rather than reported to the test as
+// they are made. AssertAll must be called at the end of the chain to report them, all together.
+func (c JSONResponse) Soft() JSONResponse {
+	c.soft = &softAssertions{}
+
+	return c
+}
+
+// AssertAll fails the test with every assertion that has failed since Soft, or the last call to AssertAll.
+func (c JSONResponse) AssertAll(t *testing.T) JSONResponse {
+	if c.soft != nil {
+		c.soft.report(t)
+	}
+
+	return c
+}
+
+// Soft puts the response in soft mode, in which failed assertions are collected rather than reported to the test as
+// they are made. AssertAll must be called at the end of the chain to report them, all together.
+func (c XMLResponse) Soft() XMLResponse {
+	c.soft = &softAssertions{}
+
+	return c
+}
+
+// AssertAll fails the test with every assertion that has failed since Soft, or the last call to AssertAll.
+func (c XMLResponse) AssertAll(t *testing.T) XMLResponse {
+	if c.soft != nil {
+		c.soft.report(t)
+	}
+
+	return c
+}
+
+// Soft puts the response in soft mode, in which failed assertions are collected rather than reported to the test as
+// they are made. AssertAll must be called at the end of the chain to report them, all together.
+func (c SSEResponse) Soft() SSEResponse {
+	c.soft = &softAssertions{}
+
+	return c
+}
+
+// AssertAll fails the test with every assertion that has failed since Soft, or the last call to AssertAll.
+func (c SSEResponse) AssertAll(t *testing.T) SSEResponse {
+	if c.soft != nil {
+		c.soft.report(t)
+	}
+
+	return c
+}
+
+// Soft puts the response in soft mode, in which failed assertions are collected rather than reported to the test as
+// they are made. AssertAll must be called at the end of the chain to report them, all together.
+func (c StreamResponse) Soft() StreamResponse {
+	c.soft = &softAssertions{}
+
+	return c
+}
+
+// AssertAll fails the test with every assertion that has failed since Soft, or the last call to AssertAll.
+func (c StreamResponse) AssertAll(t *testing.T) StreamResponse {
+	if c.soft != nil {
+		c.soft.report(t)
+	}
+
+	return c
+}
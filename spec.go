@@ -0,0 +1,360 @@
+package gointegration
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/btm6084/gojson"
+)
+
+// operations is the set of keys in a path item that describe an operation, as opposed to
+// shared fields such as parameters, summary, or servers.
+var operations = map[string]bool{
+	"get":     true,
+	"put":     true,
+	"post":    true,
+	"delete":  true,
+	"options": true,
+	"head":    true,
+	"patch":   true,
+	"trace":   true,
+}
+
+// isOperation returns true if the given path item key describes an operation.
+func isOperation(key string) bool {
+	return operations[strings.ToLower(key)]
+}
+
+// members returns the child nodes of an object or array node, in the same order as its Keys. Unlike Get,
+// this is safe for keys which contain a dot, such as paths ("/v1.0/users") or media types ("application/vnd.api+json").
+func members(node *gojson.JSONReader) []gojson.JSONReader {
+	if len(node.Keys) == 0 {
+		return nil
+	}
+
+	return node.GetCollection("")
+}
+
+// document is a loaded swagger document, along with the location it was loaded from.
+// The location is used to resolve references to other documents relative to this one.
+type document struct {
+	location string
+	reader   *gojson.JSONReader
+}
+
+// refResolver resolves $ref pointers, both within a document (e.g. "#/components/parameters/id") and to other
+// documents relative to it (e.g. "common.json#/parameters/id" or "https://example.com/common.json#/parameters/id").
+type refResolver struct {
+	mu   sync.Mutex
+	docs map[string]document
+
+	// client fetches the documents referred to by URL.
+	client *http.Client
+}
+
+// newRefResolver creates a refResolver for the given root document, which fetches documents with the given client.
+func newRefResolver(root document, client *http.Client) *refResolver {
+	return &refResolver{docs: map[string]document{root.location: root}, client: client}
+}
+
+// setClient sets the client documents are fetched with.
+func (rr *refResolver) setClient(client *http.Client) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	rr.client = client
+}
+
+// resolve follows any $ref pointers from the given node, which was found in the given document, to the node it
+// refers to. The document containing the resolved node is also returned, as nested references are relative to it.
+// Nodes that are not references are returned as-is, while a reference which can't be followed, such as to a document
+// which can't be fetched or a node which doesn't exist, is an error.
+func (rr *refResolver) resolve(doc document, node *gojson.JSONReader) (document, *gojson.JSONReader, error) {
+	// Bound the number of hops so that circular references can't loop forever.
+	for i := 0; i < 32; i++ {
+		ref := node.GetString("$ref")
+		if ref == "" {
+			return doc, node, nil
+		}
+
+		pieces := strings.SplitN(ref, "#", 2)
+
+		target := doc
+		if pieces[0] != "" {
+			var err error
+			target, err = rr.load(resolveLocation(doc.location, pieces[0]))
+			if err != nil {
+				return doc, node, fmt.Errorf("Unable to resolve $ref '%s' in %s: %s", ref, doc.location, err.Error())
+			}
+		}
+
+		pointer := ""
+		if len(pieces) == 2 {
+			pointer = pieces[1]
+		}
+
+		resolved := lookupPointer(target.reader, pointer)
+		if resolved == nil {
+			return doc, node, fmt.Errorf("Unable to resolve $ref '%s' in %s: no such node in %s", ref, doc.location, target.location)
+		}
+
+		doc, node = target, resolved
+	}
+
+	return doc, node, fmt.Errorf("Unable to resolve $ref '%s' in %s: too many levels of $ref, which may be circular", node.GetString("$ref"), doc.location)
+}
+
+// load returns the document at the given location, fetching it if it has not been loaded before.
+func (rr *refResolver) load(location string) (document, error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if doc, isset := rr.docs[location]; isset {
+		return doc, nil
+	}
+
+	var data []byte
+	var err error
+	if isURL(location) {
+		data, err = fetch(rr.client, location, nil)
+	} else {
+		data, err = ioutil.ReadFile(location)
+	}
+	if err != nil {
+		return document{}, err
+	}
+
+	reader, err := gojson.NewJSONReader(data)
+	if err != nil {
+		return document{}, err
+	}
+
+	doc := document{location: location, reader: reader}
+	rr.docs[location] = doc
+
+	return doc, nil
+}
+
+// isURL returns true if the given location is an http or https URL.
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// resolveLocation resolves the location of a referenced document relative to the location of the referencing document.
+func resolveLocation(base string, ref string) string {
+	if isURL(ref) || filepath.IsAbs(ref) {
+		return ref
+	}
+
+	if isURL(base) {
+		b, err := url.Parse(base)
+		if err != nil {
+			return ref
+		}
+
+		r, err := url.Parse(ref)
+		if err != nil {
+			return ref
+		}
+
+		return b.ResolveReference(r).String()
+	}
+
+	return filepath.Join(filepath.Dir(base), ref)
+}
+
+// lookupPointer returns the node within doc that the given JSON pointer (e.g. "/components/parameters/id") refers to,
+// or nil if there is none. The empty pointer refers to the whole document.
+func lookupPointer(doc *gojson.JSONReader, pointer string) *gojson.JSONReader {
+	node := doc
+	if pointer == "" || pointer == "/" {
+		return node
+	}
+
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+
+	for _, seg := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		seg = unescape.Replace(seg)
+
+		// Walk key by key rather than using a dotted path, as keys may contain dots.
+		found := false
+		children := members(node)
+		for i, k := range node.Keys {
+			if k == seg && i < len(children) {
+				node = &children[i]
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil
+		}
+	}
+
+	return node
+}
+
+// parseParam builds a ParamSpec from a parameter object. OpenAPI 3.x declares the type inside a schema,
+// where Swagger 2.0 declares it on the parameter itself.
+func (rr *refResolver) parseParam(doc document, param *gojson.JSONReader) (ParamSpec, error) {
+	var p ParamSpec
+	p.FoundIn = param.GetString("in")
+	p.Name = param.GetString("name")
+	p.Required = param.GetBool("required")
+	p.Type = param.GetString("type")
+
+	if p.Type == "" && param.KeyExists("schema") {
+		_, schema, err := rr.resolve(doc, param.Get("schema"))
+		if err != nil {
+			return p, err
+		}
+		p.Type = schema.GetString("type")
+	}
+
+	return p, nil
+}
+
+// parseRequestBody builds a ParamSpec from an OpenAPI 3.x requestBody object. As request bodies are unnamed,
+// the body is passed to Exec as the parameter named "body".
+func (rr *refResolver) parseRequestBody(doc document, body *gojson.JSONReader) (ParamSpec, error) {
+	p := ParamSpec{
+		FoundIn:  "body",
+		Name:     "body",
+		Required: body.GetBool("required"),
+	}
+
+	if types := mediaTypes(body); len(types) > 0 {
+		p.ContentType = types[0]
+
+		media := members(body.Get("content"))[0]
+		if media.KeyExists("schema") {
+			_, schema, err := rr.resolve(doc, media.Get("schema"))
+			if err != nil {
+				return p, err
+			}
+			p.Type = schema.GetString("type")
+		}
+	}
+
+	return p, nil
+}
+
+// mediaTypes returns the media types declared in the content map of an OpenAPI 3.x request body or response.
+func mediaTypes(node *gojson.JSONReader) []string {
+	content := node.Get("content")
+	if content.Type != gojson.JSONObject {
+		return nil
+	}
+
+	return append([]string(nil), content.Keys...)
+}
+
+// parseResponses builds the set of ResponseSpec declared for an operation, keyed by status code.
+func (rr *refResolver) parseResponses(doc document, responses *gojson.JSONReader) (map[string]ResponseSpec, error) {
+	out := make(map[string]ResponseSpec, len(responses.Keys))
+
+	for i, response := range members(responses) {
+		respDoc, resp, err := rr.resolve(doc, &response)
+		if err != nil {
+			return nil, err
+		}
+
+		spec := ResponseSpec{
+			Description: resp.GetString("description"),
+			Produces:    mediaTypes(resp),
+			schemas:     make(map[string]schemaRef),
+			examples:    make(map[string]*gojson.JSONReader),
+		}
+
+		// Swagger 2.0 declares a single schema per response, where OpenAPI 3.x declares one per media type.
+		if resp.KeyExists("schema") {
+			spec.schemas[""] = schemaRef{doc: respDoc, node: resp.Get("schema")}
+		}
+
+		examples := resp.Get("examples")
+		for j := range members(examples) {
+			spec.examples[examples.Keys[j]] = examples.Get(examples.Keys[j])
+		}
+
+		content := resp.Get("content")
+		for j, media := range members(content) {
+			mediaType := content.Keys[j]
+
+			if media.KeyExists("schema") {
+				spec.schemas[mediaType] = schemaRef{doc: respDoc, node: media.Get("schema")}
+			}
+
+			// OpenAPI 3.x declares either a single example, or named Example objects, of which the first is used.
+			if media.KeyExists("example") {
+				spec.examples[mediaType] = media.Get("example")
+			} else if named := members(media.Get("examples")); len(named) > 0 {
+				_, example, err := rr.resolve(respDoc, &named[0])
+				if err != nil {
+					return nil, err
+				}
+				if example.KeyExists("value") {
+					spec.examples[mediaType] = example.Get("value")
+				}
+			}
+		}
+
+		out[responses.Keys[i]] = spec
+	}
+
+	return out, nil
+}
+
+// responseMediaTypes returns the distinct media types declared across the given responses, in status code order.
+func responseMediaTypes(responses map[string]ResponseSpec) []string {
+	codes := make([]string, 0, len(responses))
+	for code := range responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	var out []string
+	seen := make(map[string]bool)
+
+	for _, code := range codes {
+		for _, t := range responses[code].Produces {
+			if !seen[t] {
+				seen[t] = true
+				out = append(out, t)
+			}
+		}
+	}
+
+	return out
+}
+
+// serverVariable matches a server variable, such as {version} in "https://api.example.com/{version}".
+var serverVariable = regexp.MustCompile(`\{([^}]+)\}`)
+
+// parseServers returns the URLs of the servers declared in an OpenAPI 3.x document, with any server variables
+// replaced by their default values.
+func parseServers(servers *gojson.JSONReader) []string {
+	var out []string
+	for _, server := range members(servers) {
+		vars := server.Get("variables")
+
+		u := serverVariable.ReplaceAllStringFunc(server.GetString("url"), func(v string) string {
+			name := strings.Trim(v, "{}")
+			if vars.KeyExists(name) {
+				return vars.Get(name).GetString("default")
+			}
+			return v
+		})
+
+		out = append(out, u)
+	}
+
+	return out
+}
@@ -0,0 +1,322 @@
+package gointegration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btm6084/gojson"
+	"github.com/stretchr/testify/assert"
+)
+
+// bodyReaderKey is the context key under which a function reading the body of a response, in place of reading all
+// of it, is stored, such as for the event stream of ExecSSE.
+type bodyReaderKey struct{}
+
+// SSEEvent is a single server-sent event. Event is the type of the event, which defaults to "message", and Data
+// holds its data lines, joined by newlines. ID is the last event ID set by the stream, and Retry is the reconnection
+// time it last set, if any.
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration
+}
+
+// SSEResponse provides everything that ClientResponse does, along with the events received from an event stream,
+// in the order received. The Body holds the stream as received.
+type SSEResponse struct {
+	ClientResponse
+	Events []SSEEvent
+}
+
+// sseParser parses an event stream, line by line, as described by the HTML Living Standard.
+type sseParser struct {
+	event SSEEvent
+	data  []string
+	id    string
+	retry time.Duration
+}
+
+// line parses the given line of the stream, without its line ending, returning an event and true if the line
+// dispatched one.
+func (p *sseParser) line(line string) (SSEEvent, bool) {
+	if line == "" {
+		data := p.data
+		event := p.event
+		p.data = nil
+		p.event = SSEEvent{}
+
+		// Events without data are not dispatched.
+		if data == nil {
+			return SSEEvent{}, false
+		}
+
+		event.Data = strings.Join(data, "\n")
+		event.ID = p.id
+		event.Retry = p.retry
+		if event.Event == "" {
+			event.Event = "message"
+		}
+
+		return event, true
+	}
+
+	// Lines starting with a colon are comments, such as those sent to keep the connection alive.
+	if strings.HasPrefix(line, ":") {
+		return SSEEvent{}, false
+	}
+
+	field, value := line, ""
+	if i := strings.Index(line, ":"); i >= 0 {
+		field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+	}
+
+	switch field {
+	case "event":
+		p.event.Event = value
+	case "data":
+		p.data = append(p.data, value)
+	case "id":
+		if !strings.Contains(value, "\x00") {
+			p.id = value
+		}
+	case "retry":
+		if ms, err := strconv.Atoi(value); err == nil {
+			p.retry = time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	return SSEEvent{}, false
+}
+
+// sseLines calls fn with each complete line of the given stream, without its line ending, returning the part of the
+// stream after the last complete line. Lines may end with CRLF, LF, or CR, though a CR at the end of the stream is
+// left in the remainder, as it may be followed by an LF.
+func sseLines(stream string, fn func(line string) bool) string {
+	for {
+		i := strings.IndexAny(stream, "\r\n")
+		if i < 0 || (stream[i] == '\r' && i == len(stream)-1) {
+			return stream
+		}
+
+		line := stream[:i]
+		if strings.HasPrefix(stream[i:], "\r\n") {
+			stream = stream[i+2:]
+		} else {
+			stream = stream[i+1:]
+		}
+
+		if fn(line) {
+			return stream
+		}
+	}
+}
+
+// parseSSE returns the events of the given event stream. An event not terminated by a blank line is incomplete, and
+// is not returned.
+func parseSSE(stream string) []SSEEvent {
+	var p sseParser
+	var events []SSEEvent
+
+	sseLines(stream, func(line string) bool {
+		if event, dispatched := p.line(line); dispatched {
+			events = append(events, event)
+		}
+		return false
+	})
+
+	return events
+}
+
+// ExecSSE takes a path specifier and executes the corresponding functionality if found in the loaded swagger doc,
+// reading the response as an event stream. Events are collected until the stream ends, until returns true for the
+// events received so far, or the timeout elapses, whichever is first. Either way, the events received are returned;
+// reaching the timeout is not an error, so that the events can be asserted on. A nil until collects events until the
+// stream ends or the timeout elapses, and a timeout of 0 waits indefinitely. The timeout of the Client also applies.
+func (sc *Client) ExecSSE(specifier string, params map[string]interface{}, until func(events []SSEEvent) bool, timeout time.Duration) SSEResponse {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	ctx = context.WithValue(ctx, bodyReaderKey{}, func(body io.Reader) ([]byte, error) {
+		return readSSE(ctx, body, until)
+	})
+
+	req, err := sc.newRequest(ctx, specifier, params)
+	if err != nil {
+		return SSEResponse{ClientResponse: ClientResponse{Error: err}}
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+
+	resp := sc.MakeRequest(req)
+	if resp.Error != nil {
+		return SSEResponse{ClientResponse: resp}
+	}
+
+	return SSEResponse{ClientResponse: resp, Events: parseSSE(resp.Body)}
+}
+
+// readSSE reads the given event stream until it ends, until returns true for the events received so far, or the
+// given context is done, returning the stream as received.
+func readSSE(ctx context.Context, body io.Reader, until func(events []SSEEvent) bool) ([]byte, error) {
+	var raw bytes.Buffer
+	var p sseParser
+	var events []SSEEvent
+
+	pending := ""
+	buf := make([]byte, 4096)
+	for {
+		n, err := body.Read(buf)
+		raw.Write(buf[:n])
+
+		done := false
+		pending = sseLines(pending+string(buf[:n]), func(line string) bool {
+			event, dispatched := p.line(line)
+			if !dispatched {
+				return false
+			}
+
+			events = append(events, event)
+			done = until != nil && until(events)
+			return done
+		})
+
+		// The rest of the stream is left out, so that the body holds only the events until saw.
+		if done {
+			return raw.Bytes()[:raw.Len()-len(pending)], nil
+		}
+		if err == io.EOF {
+			return raw.Bytes(), nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return raw.Bytes(), nil
+			}
+			return raw.Bytes(), err
+		}
+	}
+}
+
+// ExpectStatus asserts that the status code received is the given status code.
+func (c SSEResponse) ExpectStatus(t *testing.T, status int) SSEResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.Equal(c.reporter(t), status, c.StatusCode, fmt.Sprintf("expected statuscode '%d', got '%d' instead", status, c.StatusCode))
+
+	return c
+}
+
+// ExpectHeaderValue asserts that the header at the given key has the given value.
+func (c SSEResponse) ExpectHeaderValue(t *testing.T, key string, value string) SSEResponse {
+	c.ClientResponse.ExpectHeaderValue(t, key, value)
+
+	return c
+}
+
+// ExpectEventCount asserts that exactly the given number of events were received.
+func (c SSEResponse) ExpectEventCount(t *testing.T, n int) SSEResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.Equal(c.reporter(t), n, len(c.Events), fmt.Sprintf("expected exactly %d events, found %d", n, len(c.Events)))
+
+	return c
+}
+
+// ExpectEventCountAtLeast asserts that at least the given number of events were received.
+func (c SSEResponse) ExpectEventCountAtLeast(t *testing.T, n int) SSEResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.True(c.reporter(t), len(c.Events) >= n, fmt.Sprintf("expected at least %d events, found %d", n, len(c.Events)))
+
+	return c
+}
+
+// event returns the event at the given index, failing the test if there is none.
+func (c SSEResponse) event(t *testing.T, i int) (SSEEvent, bool) {
+	if i < 0 || i >= len(c.Events) {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected an event at index %d, found %d events", i, len(c.Events)))
+		return SSEEvent{}, false
+	}
+
+	return c.Events[i], true
+}
+
+// ExpectEventData asserts that the data of the event at the given index is the given data.
+func (c SSEResponse) ExpectEventData(t *testing.T, i int, data string) SSEResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if event, found := c.event(t, i); found {
+		assert.Equal(c.reporter(t), data, event.Data, fmt.Sprintf("expected data of event %d to equal '%s', got '%s' instead", i, data, event.Data))
+	}
+
+	return c
+}
+
+// ExpectEventDataContains asserts that the data of the event at the given index contains the given value.
+func (c SSEResponse) ExpectEventDataContains(t *testing.T, i int, value string) SSEResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if event, found := c.event(t, i); found {
+		assert.True(c.reporter(t), strings.Contains(event.Data, value), fmt.Sprintf("expected data of event %d to contain '%s', got '%s' instead", i, value, event.Data))
+	}
+
+	return c
+}
+
+// ExpectEventType asserts that the type of the event at the given index is the given type.
+func (c SSEResponse) ExpectEventType(t *testing.T, i int, event string) SSEResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	if e, found := c.event(t, i); found {
+		assert.Equal(c.reporter(t), event, e.Event, fmt.Sprintf("expected type of event %d to equal '%s', got '%s' instead", i, event, e.Event))
+	}
+
+	return c
+}
+
+// EventJSON returns the event at the given index as a JSONResponse whose body is the data of the event, so that the
+// JSON assertions can be made on it. The Error of the response says why, if there is no event at the index or its
+// data is not JSON.
+func (c SSEResponse) EventJSON(i int) JSONResponse {
+	resp := c.ClientResponse
+	if resp.Error != nil {
+		return toJSONResponse(resp)
+	}
+
+	if i < 0 || i >= len(c.Events) {
+		resp.Error = fmt.Errorf("EventJSON: no event at index %d, found %d events", i, len(c.Events))
+		return toJSONResponse(resp)
+	}
+
+	resp.Body = c.Events[i].Data
+
+	reader, err := gojson.NewJSONReader([]byte(resp.Body))
+	if err != nil {
+		resp.Error = fmt.Errorf("EventJSON: unable to parse data of event %d: %s", i, err.Error())
+		return toJSONResponse(resp)
+	}
+
+	return JSONResponse{ClientResponse: resp, Reader: reader}
+}
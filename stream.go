@@ -0,0 +1,281 @@
+package gointegration
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+)
+
+// bodySinkKey is the context key under which the bodySink a response body is streamed to, in place of being read
+// into memory, is stored.
+type bodySinkKey struct{}
+
+// StreamResponse provides everything that ClientResponse does, though its Body and BodyBytes are empty, as the body
+// was streamed to a file or writer rather than read into memory. Path is the temporary file the body was written to,
+// if no writer was given. Size is the size of the body, after any content coding is reversed, and Checksums holds its
+// hex encoded digest by each algorithm ExpectChecksum supports, such as "sha256".
+type StreamResponse struct {
+	ClientResponse
+	Path      string
+	Size      int64
+	Checksums map[string]string
+}
+
+// bodySink receives the body of a response, counting and hashing it as it is written.
+type bodySink struct {
+	w        io.Writer
+	size     int64
+	received int64
+	hashes   map[string]hash.Hash
+}
+
+// rewinder is implemented by writers which can be emptied between attempts, such as *os.File.
+type rewinder interface {
+	io.Seeker
+	Truncate(size int64) error
+}
+
+// stream writes the given body, with the given Content-Encoding reversed, to the writer of the sink. Any body written
+// by an earlier attempt is discarded first, if the writer can be rewound.
+func (s *bodySink) stream(body io.Reader, encoding string) error {
+	if r, ok := s.w.(rewinder); ok {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if err := r.Truncate(0); err != nil {
+			return err
+		}
+	}
+
+	s.size, s.received = 0, 0
+	s.hashes = map[string]hash.Hash{}
+	writers := []io.Writer{s.w}
+	for name, h := range checksums {
+		s.hashes[name] = h()
+		writers = append(writers, s.hashes[name])
+	}
+
+	counted := &countingReader{r: body, n: &s.received}
+	decoded, err := decodeStream(counted, encoding)
+	if err != nil {
+		return err
+	}
+
+	s.size, err = io.Copy(io.MultiWriter(writers...), decoded)
+
+	return err
+}
+
+// checksums returns the hex encoded digest of the body by each algorithm.
+func (s *bodySink) checksums() map[string]string {
+	sums := map[string]string{}
+	for name, h := range s.hashes {
+		sums[name] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	return sums
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+
+	return n, err
+}
+
+// decodeStream behaves as decodeBody, reversing the content codings listed in the given Content-Encoding header as
+// the body is read. If any coding is unrecognized, the raw body is returned instead. As the body can't be reread,
+// a stream which can't be opened is an error.
+func decodeStream(body io.Reader, encoding string) (io.Reader, error) {
+	if encoding == "" {
+		return body, nil
+	}
+
+	codings := strings.Split(encoding, ",")
+	for _, coding := range codings {
+		switch strings.ToLower(strings.TrimSpace(coding)) {
+		case "", "identity", "gzip", "x-gzip", "deflate", "br":
+		default:
+			return body, nil
+		}
+	}
+
+	r := body
+	for i := len(codings) - 1; i >= 0; i-- {
+		switch strings.ToLower(strings.TrimSpace(codings[i])) {
+		case "gzip", "x-gzip":
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			r = gz
+		case "deflate":
+			// Deflate is supposed to be zlib wrapped, but some servers send raw deflate data instead. A zlib stream
+			// is recognized by its two byte header, which is checked without consuming it.
+			br := bufio.NewReader(r)
+			if head, _ := br.Peek(2); len(head) == 2 && head[0]&0x0f == 8 && (uint(head[0])<<8|uint(head[1]))%31 == 0 {
+				zr, err := zlib.NewReader(br)
+				if err != nil {
+					return nil, err
+				}
+				r = zr
+			} else {
+				r = flate.NewReader(br)
+			}
+		case "br":
+			r = brotli.NewReader(r)
+		}
+	}
+
+	return r, nil
+}
+
+// ExecStream takes a path specifier and executes the corresponding functionality if found in the loaded swagger doc,
+// streaming the body of the response to the given writer rather than reading it into memory, such as for export
+// endpoints whose bodies are gigabytes in size. If the writer is nil, the body is written to a temporary file, whose
+// path is in the Path of the response; remove it when done.
+//
+// If the request is retried, the body of each attempt is written in turn, unless the writer can be rewound, as a
+// file can, in which case only the body of the last attempt is kept.
+func (sc *Client) ExecStream(specifier string, params map[string]interface{}, w io.Writer) StreamResponse {
+	return sc.execStream(w, func(ctx context.Context) ClientResponse {
+		req, err := sc.newRequest(ctx, specifier, params)
+		if err != nil {
+			return ClientResponse{Error: err}
+		}
+
+		return sc.MakeRequest(req)
+	})
+}
+
+// ExecRawStream behaves as ExecStream, but makes the request as ExecRaw does.
+func (sc *Client) ExecRawStream(method, path string, opts RequestOptions, w io.Writer) StreamResponse {
+	return sc.execStream(w, func(ctx context.Context) ClientResponse {
+		return sc.ExecRawContext(ctx, method, path, opts)
+	})
+}
+
+// execStream runs the given request with its body streamed to the given writer, or a temporary file if it is nil.
+func (sc *Client) execStream(w io.Writer, exec func(ctx context.Context) ClientResponse) StreamResponse {
+	var out StreamResponse
+
+	if w == nil {
+		f, err := ioutil.TempFile("", "gointegration-*")
+		if err != nil {
+			return StreamResponse{ClientResponse: ClientResponse{Error: fmt.Errorf("ExecStream: %s", err.Error())}}
+		}
+		defer f.Close()
+
+		w = f
+		out.Path = f.Name()
+	}
+
+	sink := &bodySink{w: w}
+	out.ClientResponse = exec(context.WithValue(context.Background(), bodySinkKey{}, sink))
+	if out.Error == nil {
+		out.Size = sink.size
+		out.Checksums = sink.checksums()
+	}
+
+	return out
+}
+
+// ExpectStatus asserts that the status code received is the given status code.
+func (c StreamResponse) ExpectStatus(t *testing.T, status int) StreamResponse {
+	c.ClientResponse.ExpectStatus(t, status)
+
+	return c
+}
+
+// ExpectHeaderValue asserts that the header at the given key has the given value.
+func (c StreamResponse) ExpectHeaderValue(t *testing.T, key string, value string) StreamResponse {
+	c.ClientResponse.ExpectHeaderValue(t, key, value)
+
+	return c
+}
+
+// ExpectFilename asserts that the Content-Disposition header of the response names the given file.
+func (c StreamResponse) ExpectFilename(t *testing.T, filename string) StreamResponse {
+	c.ClientResponse.ExpectFilename(t, filename)
+
+	return c
+}
+
+// ExpectBodySize asserts that the streamed body is exactly the given number of bytes, after any content coding is
+// reversed.
+func (c StreamResponse) ExpectBodySize(t *testing.T, size int64) StreamResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.Equal(c.reporter(t), size, c.Size, fmt.Sprintf("expected body of %d bytes, got %d bytes instead", size, c.Size))
+
+	return c
+}
+
+// ExpectSizeBetween asserts that the streamed body is at least min and at most max bytes, after any content coding is
+// reversed.
+func (c StreamResponse) ExpectSizeBetween(t *testing.T, min, max int64) StreamResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.True(c.reporter(t), c.Size >= min && c.Size <= max, fmt.Sprintf("expected body of %d to %d bytes, got %d bytes instead", min, max, c.Size))
+
+	return c
+}
+
+// ExpectBodySHA256 asserts that the SHA-256 digest of the streamed body is the given hex encoded digest.
+func (c StreamResponse) ExpectBodySHA256(t *testing.T, digest string) StreamResponse {
+	return c.ExpectChecksum(t, "sha256", digest)
+}
+
+// ExpectChecksum asserts that the digest of the streamed body, by the given algorithm, is the given hex encoded digest.
+// The algorithm is one of "md5", "sha1", "sha256", or "sha512".
+func (c StreamResponse) ExpectChecksum(t *testing.T, algorithm, digest string) StreamResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	actual, ok := c.Checksums[strings.ToLower(algorithm)]
+	if !ok {
+		assert.Fail(c.reporter(t), fmt.Sprintf("unknown checksum algorithm '%s'", algorithm))
+		return c
+	}
+
+	assert.True(c.reporter(t), strings.EqualFold(digest, actual), fmt.Sprintf("expected body with %s digest '%s', got '%s' instead", algorithm, digest, actual))
+
+	return c
+}
+
+// Remove removes the temporary file the body was written to, if any.
+func (c StreamResponse) Remove() error {
+	if c.Path == "" {
+		return nil
+	}
+
+	if err := os.Remove(c.Path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Remove: %s", err.Error())
+	}
+
+	return nil
+}
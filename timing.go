@@ -0,0 +1,188 @@
+package gointegration
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RequestTimings breaks down the time taken by a request, as traced by net/http/httptrace. DNSLookup, Connect, and
+// TLSHandshake are the time taken to resolve the host, open the TCP connection, and negotiate TLS, each of which is 0
+// when the connection was reused, as ConnReused reports, or the step wasn't needed. TTFB is the time to first byte,
+// from the request being started until the first byte of the response was received, including connecting.
+//
+// When redirects are followed, the connection timings are those of the last request made, while TTFB runs from the
+// first.
+type RequestTimings struct {
+	DNSLookup    time.Duration `json:"dns_lookup"`
+	Connect      time.Duration `json:"connect"`
+	TLSHandshake time.Duration `json:"tls_handshake"`
+	TTFB         time.Duration `json:"ttfb"`
+	ConnReused   bool          `json:"conn_reused"`
+}
+
+// requestTrace collects the RequestTimings of a request. Its hooks may be called from the goroutines dialing for the
+// request, even after the request is done, such as when a pooled connection became free first, so it is guarded.
+type requestTrace struct {
+	mu       sync.Mutex
+	start    time.Time
+	dns      time.Time
+	connects map[string]time.Time
+	tls      time.Time
+	timings  RequestTimings
+}
+
+// newRequestTrace returns a requestTrace for a request started at the given time.
+func newRequestTrace(start time.Time) *requestTrace {
+	return &requestTrace{start: start, connects: make(map[string]time.Time)}
+}
+
+// trace returns the httptrace hooks which fill in the timings.
+func (r *requestTrace) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.dns = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.timings.DNSLookup = time.Since(r.dns)
+		},
+		// Several addresses may be dialed at once, such as for IPv4 and IPv6, so the first to connect is timed.
+		ConnectStart: func(network, addr string) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.connects[network+" "+addr] = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if err == nil && r.timings.Connect == 0 {
+				r.timings.Connect = time.Since(r.connects[network+" "+addr])
+			}
+		},
+		TLSHandshakeStart: func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.tls = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.timings.TLSHandshake = time.Since(r.tls)
+		},
+		// Each request of a redirect chain gets a connection, which resets the timings of the one before it.
+		GetConn: func(string) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.timings = RequestTimings{TTFB: r.timings.TTFB}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.timings.ConnReused = info.Reused
+		},
+		GotFirstResponseByte: func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.timings.TTFB = time.Since(r.start)
+		},
+	}
+}
+
+// withTrace returns a copy of the given request which is traced by the given requestTrace, along with any trace
+// already set on its context.
+func withTrace(req *http.Request, r *requestTrace) *http.Request {
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), r.trace()))
+}
+
+// result returns the timings collected so far.
+func (r *requestTrace) result() RequestTimings {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.timings
+}
+
+// ExpectTTFBUnder asserts that the first byte of the response was received less than the given duration after the
+// request was started.
+func (c ClientResponse) ExpectTTFBUnder(t *testing.T, limit time.Duration) ClientResponse {
+	return c.expectTimingUnder(t, "time to first byte", c.Timings.TTFB, limit)
+}
+
+// ExpectDNSLookupUnder asserts that resolving the host of the request took less than the given duration.
+func (c ClientResponse) ExpectDNSLookupUnder(t *testing.T, limit time.Duration) ClientResponse {
+	return c.expectTimingUnder(t, "DNS lookup", c.Timings.DNSLookup, limit)
+}
+
+// ExpectConnectUnder asserts that opening the TCP connection for the request took less than the given duration.
+func (c ClientResponse) ExpectConnectUnder(t *testing.T, limit time.Duration) ClientResponse {
+	return c.expectTimingUnder(t, "connect", c.Timings.Connect, limit)
+}
+
+// ExpectTLSHandshakeUnder asserts that negotiating TLS for the request took less than the given duration.
+func (c ClientResponse) ExpectTLSHandshakeUnder(t *testing.T, limit time.Duration) ClientResponse {
+	return c.expectTimingUnder(t, "TLS handshake", c.Timings.TLSHandshake, limit)
+}
+
+// ExpectConnReused asserts that the request was sent over a connection kept alive from an earlier request, such as
+// to check that the service supports keep-alive.
+func (c ClientResponse) ExpectConnReused(t *testing.T) ClientResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.True(c.reporter(t), c.Timings.ConnReused, "expected the request to reuse a connection, a new connection was opened instead")
+
+	return c
+}
+
+// expectTimingUnder asserts that the given step of the request took less than the given duration.
+func (c ClientResponse) expectTimingUnder(t *testing.T, name string, actual, limit time.Duration) ClientResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.True(c.reporter(t), actual < limit, fmt.Sprintf("expected %s to take less than %s, took %s instead", name, limit, actual))
+
+	return c
+}
+
+// ExpectTTFBUnder asserts that the first byte of the response was received less than the given duration after the
+// request was started.
+func (c JSONResponse) ExpectTTFBUnder(t *testing.T, limit time.Duration) JSONResponse {
+	c.ClientResponse.ExpectTTFBUnder(t, limit)
+	return c
+}
+
+// ExpectDNSLookupUnder asserts that resolving the host of the request took less than the given duration.
+func (c JSONResponse) ExpectDNSLookupUnder(t *testing.T, limit time.Duration) JSONResponse {
+	c.ClientResponse.ExpectDNSLookupUnder(t, limit)
+	return c
+}
+
+// ExpectConnectUnder asserts that opening the TCP connection for the request took less than the given duration.
+func (c JSONResponse) ExpectConnectUnder(t *testing.T, limit time.Duration) JSONResponse {
+	c.ClientResponse.ExpectConnectUnder(t, limit)
+	return c
+}
+
+// ExpectTLSHandshakeUnder asserts that negotiating TLS for the request took less than the given duration.
+func (c JSONResponse) ExpectTLSHandshakeUnder(t *testing.T, limit time.Duration) JSONResponse {
+	c.ClientResponse.ExpectTLSHandshakeUnder(t, limit)
+	return c
+}
+
+// ExpectConnReused asserts that the request was sent over a connection kept alive from an earlier request.
+func (c JSONResponse) ExpectConnReused(t *testing.T) JSONResponse {
+	c.ClientResponse.ExpectConnReused(t)
+	return c
+}
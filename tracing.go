@@ -0,0 +1,147 @@
+package gointegration
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// traceparentHeader is the header carrying the W3C trace context of a request.
+const traceparentHeader = "traceparent"
+
+// traceparentPattern matches a version 00 W3C traceparent header, capturing its trace ID, parent span ID, and flags.
+var traceparentPattern = regexp.MustCompile(`^00-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+
+// Span is the client side of a single request made by a Client with Tracing set, as given to OnSpan. Its IDs are
+// those of the W3C trace context sent with the request, so that the spans of the service handling it are its
+// children. Attributes follow the OpenTelemetry semantic conventions for HTTP clients.
+type Span struct {
+	Name         string
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Sampled      bool
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]string
+
+	// Error is the error of the request, if it failed without a response.
+	Error error
+}
+
+// Tracer creates the spans of the requests made by a Client with Tracing set, in place of its own W3C trace context
+// propagation, such as the OpenTelemetry tracer of the gointegration/otel package, which exports them to a tracing
+// backend.
+type Tracer interface {
+	// Start starts the span of the given request, whose Name, Start, and Attributes are set, setting its IDs and
+	// Sampled. It returns the request bound to the context of the span, with its trace context set in its headers.
+	Start(req *http.Request, span *Span) *http.Request
+
+	// End ends the span started in the given context, once the End, Error, and Attributes of the response are set.
+	End(ctx context.Context, span Span)
+}
+
+// WithTracing sends a W3C trace context with every request, and calls the given function, if any, with the span of
+// each request once it is done. Refer to Client.Tracing.
+func WithTracing(onSpan func(span Span)) Option {
+	return func(sc *Client) error {
+		sc.Tracing = true
+		sc.OnSpan = onSpan
+		return nil
+	}
+}
+
+// startSpan starts the span of the given request, returning the request with its trace context set in the
+// traceparent header, bound to the context of the span if the Client has a Tracer. A request which already has a
+// traceparent continues its trace, as a child of its span, and is sampled as its parent was. It returns nil, and the
+// request as-is, if the Client doesn't have Tracing set.
+func (sc *Client) startSpan(req *http.Request) (*http.Request, *Span) {
+	if !sc.Tracing {
+		return req, nil
+	}
+
+	span := &Span{
+		Name:  "HTTP " + req.Method,
+		Start: time.Now(),
+		Attributes: map[string]string{
+			"http.request.method": req.Method,
+			"url.full":            req.URL.String(),
+			"server.address":      req.URL.Hostname(),
+		},
+	}
+
+	if spec, isset := req.Context().Value(specifierKey{}).(string); isset {
+		span.Name = spec
+		span.Attributes["gointegration.operation"] = spec
+	}
+
+	if sc.Tracer != nil {
+		return sc.Tracer.Start(req, span), span
+	}
+
+	span.SpanID = randomHex(8)
+	span.Sampled = true
+	if parent := traceparentPattern.FindStringSubmatch(req.Header.Get(traceparentHeader)); parent != nil {
+		flags, _ := strconv.ParseUint(parent[3], 16, 8)
+		span.TraceID = parent[1]
+		span.ParentSpanID = parent[2]
+		span.Sampled = flags&1 == 1
+	} else {
+		span.TraceID = randomHex(16)
+	}
+
+	flags := "00"
+	if span.Sampled {
+		flags = "01"
+	}
+	req.Header.Set(traceparentHeader, fmt.Sprintf("00-%s-%s-%s", span.TraceID, span.SpanID, flags))
+
+	return req, span
+}
+
+// endSpan ends the given span of the request with the given context, returning the response with its TraceID set,
+// and passes the span to OnSpan. A nil span, as for a Client without Tracing set, leaves the response as-is.
+func (sc *Client) endSpan(ctx context.Context, span *Span, resp ClientResponse) ClientResponse {
+	if span == nil {
+		return resp
+	}
+
+	span.End = time.Now()
+	if resp.Error != nil {
+		span.Error = resp.Error
+		span.Attributes["error.type"] = fmt.Sprintf("%T", resp.Error)
+	} else {
+		span.Attributes["http.response.status_code"] = strconv.Itoa(resp.StatusCode)
+		if resp.StatusCode >= 400 {
+			span.Attributes["error.type"] = strconv.Itoa(resp.StatusCode)
+		}
+	}
+	if resp.Attempts > 1 {
+		span.Attributes["http.request.resend_count"] = strconv.Itoa(resp.Attempts - 1)
+	}
+
+	if sc.Tracer != nil {
+		sc.Tracer.End(ctx, *span)
+	}
+
+	resp.TraceID = span.TraceID
+
+	if sc.OnSpan != nil {
+		sc.OnSpan(*span)
+	}
+
+	return resp
+}
+
+// randomHex returns the given number of random bytes, hex encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
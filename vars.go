@@ -0,0 +1,187 @@
+package gointegration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// placeholderPattern matches a string which is exactly one placeholder for a variable, such as "{{.userID}}".
+var placeholderPattern = regexp.MustCompile(`^\{\{\s*\.(\w+)\s*\}\}$`)
+
+// Variable is a param value which refers to a value in the Client's variable store, such as an ID captured from an
+// earlier response with CaptureAs. It is replaced by the stored value when the request is made, so that a
+// create-then-get-then-delete flow can pass values between requests:
+//
+//	client.ExecJSON("users.Create", params).CaptureAs("userID", "id")
+//	client.ExecJSON("users.Get", map[string]interface{}{"id": gointegration.Variable("userID")})
+//
+// Variables may be used as top-level param values, or within the maps and slices of a body param.
+//
+// When the Client has Templates set, string param values, and the paths given to ExecRaw, may instead refer to
+// variables with template placeholders, such as "{{.userID}}" or "/users/{{.userID}}/posts", which are rendered by
+// text/template with the variable store as data. A string which is exactly one placeholder is replaced by the stored
+// value as-is, keeping its type. A literal "{{" can then be written as {{"{{"}}.
+type Variable string
+
+// WithTemplates renders template placeholders in strings sent by the Client. Refer to Client.Templates.
+func WithTemplates() Option {
+	return func(sc *Client) error {
+		sc.Templates = true
+		return nil
+	}
+}
+
+// SetVariable stores the given value in the Client's variable store under the given name.
+func (sc *Client) SetVariable(name string, value interface{}) *Client {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.variables == nil {
+		sc.variables = make(map[string]interface{})
+	}
+	sc.variables[name] = value
+
+	return sc
+}
+
+// LoadVariables adds the members of the JSON object in the file at the given path to the Client's variable store,
+// such as fixture data to be referred to by Variable params or placeholders.
+func (sc *Client) LoadVariables(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("LoadVariables: %s", err.Error())
+	}
+
+	var vars map[string]interface{}
+	if err := json.Unmarshal(data, &vars); err != nil {
+		return fmt.Errorf("LoadVariables: unable to parse '%s': %s", path, err.Error())
+	}
+
+	for name, val := range vars {
+		sc.SetVariable(name, val)
+	}
+
+	return nil
+}
+
+// GetVariable returns the value stored in the Client's variable store under the given name, and whether it was set.
+func (sc *Client) GetVariable(name string) (interface{}, bool) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	val, isset := sc.variables[name]
+	return val, isset
+}
+
+// ResolveVariables returns a copy of the given params with every Variable replaced by its stored value, as for the
+// params of Exec, for payloads which are sent by other means, such as the gRPC calls of the gointegration/grpc
+// package.
+func (sc *Client) ResolveVariables(params map[string]interface{}) (map[string]interface{}, error) {
+	return sc.resolveVariables(params)
+}
+
+// resolveVariables returns a copy of the given params with every Variable replaced by its stored value.
+func (sc *Client) resolveVariables(params map[string]interface{}) (map[string]interface{}, error) {
+	if params == nil {
+		return nil, nil
+	}
+
+	out := make(map[string]interface{}, len(params))
+	for name, val := range params {
+		resolved, err := sc.resolveVariable(val)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = resolved
+	}
+
+	return out, nil
+}
+
+// resolveVariable replaces the given value, or any Variable within it, by its stored value.
+func (sc *Client) resolveVariable(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case Variable:
+		stored, isset := sc.GetVariable(string(v))
+		if !isset {
+			return nil, fmt.Errorf("Variable '%s' has not been set", string(v))
+		}
+		return stored, nil
+	case string:
+		if !sc.Templates {
+			return v, nil
+		}
+		if m := placeholderPattern.FindStringSubmatch(v); m != nil {
+			return sc.resolveVariable(Variable(m[1]))
+		}
+		return sc.render(v)
+	case map[string]interface{}:
+		return sc.resolveVariables(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, elem := range v {
+			resolved, err := sc.resolveVariable(elem)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	}
+
+	return val, nil
+}
+
+// Extract returns the value at the given key, as returned by gojson's GetInterface, or nil if it does not exist.
+func (c JSONResponse) Extract(key string) interface{} {
+	if c.Reader == nil || !c.Reader.KeyExists(key) {
+		return nil
+	}
+
+	return c.Reader.GetInterface(key)
+}
+
+// CaptureAs stores the value at the given key in the variable store of the Client that made the request, under the
+// given name, for use as a Variable in later requests. Nothing is stored if the request failed or the key does not
+// exist, in which case a later request using the Variable fails with an error saying so.
+func (c JSONResponse) CaptureAs(name, key string) JSONResponse {
+	if c.Error != nil || c.client == nil || c.Reader == nil || !c.Reader.KeyExists(key) {
+		return c
+	}
+
+	c.client.SetVariable(name, c.Reader.GetInterface(key))
+
+	return c
+}
+
+// render renders any template placeholders in the given string with the variable store as data, if the Client has
+// Templates set.
+func (sc *Client) render(s string) (string, error) {
+	if !sc.Templates || !strings.Contains(s, "{{") {
+		return s, nil
+	}
+
+	tmpl, err := template.New("").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid placeholder in '%s': %s", s, err.Error())
+	}
+
+	sc.mu.RLock()
+	vars := make(map[string]interface{}, len(sc.variables))
+	for name, val := range sc.variables {
+		vars[name] = val
+	}
+	sc.mu.RUnlock()
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("unable to fill placeholders in '%s': %s", s, err.Error())
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,723 @@
+package gointegration
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+)
+
+// XMLNode is a node of a parsed XML document. Elements have a Name, their attributes keyed by local name, their
+// own character data as Text, and their child elements. Attribute and text() nodes returned by Find have a Name
+// of "@name" and "#text" respectively, with their value as Text.
+type XMLNode struct {
+	Name     string
+	Attrs    map[string]string
+	Text     string
+	Children []*XMLNode
+
+	parent *XMLNode
+}
+
+// parseXML parses the given body into a document node, whose only child is the root element. The body is taken to be
+// UTF-8 if it was transcoded already, whatever its encoding declaration says.
+func parseXML(body []byte, transcoded bool) (*XMLNode, error) {
+	doc := &XMLNode{}
+	current := doc
+
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.Strict = false
+	dec.CharsetReader = xmlCharsetReader(transcoded)
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			node := &XMLNode{Name: tok.Name.Local, Attrs: make(map[string]string, len(tok.Attr)), parent: current}
+			for _, attr := range tok.Attr {
+				node.Attrs[attr.Name.Local] = attr.Value
+			}
+			current.Children = append(current.Children, node)
+			current = node
+		case xml.EndElement:
+			if current.parent != nil {
+				current = current.parent
+			}
+		case xml.CharData:
+			current.Text += string(tok)
+		}
+	}
+
+	if len(doc.Children) == 0 {
+		return nil, errors.New("no root element")
+	}
+
+	var trim func(n *XMLNode)
+	trim = func(n *XMLNode) {
+		n.Text = strings.TrimSpace(n.Text)
+		for _, c := range n.Children {
+			trim(c)
+		}
+	}
+	trim(doc)
+
+	return doc, nil
+}
+
+// Value returns the string value of the node: the concatenated text of an element and all of its descendants,
+// or the value of an attribute or text() node.
+func (n *XMLNode) Value() string {
+	if len(n.Children) == 0 {
+		return n.Text
+	}
+
+	var parts []string
+	if n.Text != "" {
+		parts = append(parts, n.Text)
+	}
+	for _, c := range n.Children {
+		if v := c.Value(); v != "" {
+			parts = append(parts, v)
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Find returns the nodes matching the given XPath expression, evaluated relative to this node. A subset of XPath
+// is supported: absolute (/a/b) and relative (a/b) paths, descendants (//b), wildcards (*), the parent (..) and
+// self (.) steps, attributes (@id), text(), and predicates by position ([1], [last()]), by attribute ([@id] and
+// [@id='1']), and by child element ([name] and [name='x']).
+func (n *XMLNode) Find(xpath string) ([]*XMLNode, error) {
+	root := n
+	if strings.HasPrefix(xpath, "/") {
+		for root.parent != nil {
+			root = root.parent
+		}
+	}
+
+	steps, err := parseXPath(xpath)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []*XMLNode{root}
+	for _, s := range steps {
+		nodes = s.apply(nodes)
+	}
+
+	return nodes, nil
+}
+
+// xpathStep is one step of an XPath expression, such as "//item[@id='1']".
+type xpathStep struct {
+	descendant bool
+	name       string
+	predicates []string
+}
+
+var (
+	// xpathStepPattern splits a step into its name and predicates.
+	xpathStepPattern = regexp.MustCompile(`^([^\[]+)((?:\[[^\]]*\])*)$`)
+
+	// xpathPredicatePattern matches each predicate of a step.
+	xpathPredicatePattern = regexp.MustCompile(`\[([^\]]*)\]`)
+)
+
+// parseXPath splits the given XPath expression into steps.
+func parseXPath(xpath string) ([]xpathStep, error) {
+	var steps []xpathStep
+
+	expr := strings.TrimSpace(xpath)
+	if expr == "" || expr == "/" {
+		return steps, nil
+	}
+
+	descendant := false
+	for _, seg := range splitXPath(strings.TrimPrefix(expr, "/")) {
+		if seg == "" {
+			// An empty segment comes from "//", making the next step a descendant step.
+			descendant = true
+			continue
+		}
+
+		m := xpathStepPattern.FindStringSubmatch(seg)
+		if m == nil {
+			return nil, fmt.Errorf("invalid XPath step `%s` in `%s`", seg, xpath)
+		}
+
+		step := xpathStep{descendant: descendant, name: m[1]}
+		for _, p := range xpathPredicatePattern.FindAllStringSubmatch(m[2], -1) {
+			step.predicates = append(step.predicates, strings.TrimSpace(p[1]))
+		}
+
+		steps = append(steps, step)
+		descendant = false
+	}
+
+	if descendant {
+		return nil, fmt.Errorf("invalid XPath `%s`: missing step after //", xpath)
+	}
+
+	return steps, nil
+}
+
+// splitXPath splits an XPath expression on "/", ignoring any inside a predicate.
+func splitXPath(expr string) []string {
+	var out []string
+	depth := 0
+	var quote rune
+	start := 0
+
+	for i, r := range expr {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '[':
+			depth++
+		case r == ']':
+			depth--
+		case r == '/' && depth == 0:
+			out = append(out, expr[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(out, expr[start:])
+}
+
+// apply evaluates the step against each of the given context nodes, returning the matches in document order.
+func (s xpathStep) apply(context []*XMLNode) []*XMLNode {
+	var out []*XMLNode
+	seen := make(map[*XMLNode]bool)
+
+	for _, ctx := range context {
+		candidates := []*XMLNode{ctx}
+		if s.descendant {
+			candidates = descendants(ctx)
+		}
+
+		for _, c := range candidates {
+			for _, match := range s.filter(s.nodes(c)) {
+				if !seen[match] {
+					seen[match] = true
+					out = append(out, match)
+				}
+			}
+		}
+	}
+
+	return out
+}
+
+// nodes returns the nodes the step's name selects from the given node, before predicates are applied.
+func (s xpathStep) nodes(n *XMLNode) []*XMLNode {
+	switch {
+	case s.name == ".":
+		return []*XMLNode{n}
+	case s.name == "..":
+		if n.parent == nil {
+			return nil
+		}
+		return []*XMLNode{n.parent}
+	case s.name == "text()":
+		if n.Text == "" {
+			return nil
+		}
+		return []*XMLNode{{Name: "#text", Text: n.Text, parent: n}}
+	case strings.HasPrefix(s.name, "@"):
+		name := localName(s.name[1:])
+		keys := make([]string, 0, len(n.Attrs))
+		for k := range n.Attrs {
+			if name == "*" || k == name {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		var out []*XMLNode
+		for _, k := range keys {
+			out = append(out, &XMLNode{Name: "@" + k, Text: n.Attrs[k], parent: n})
+		}
+		return out
+	}
+
+	name := localName(s.name)
+	var out []*XMLNode
+	for _, c := range n.Children {
+		if name == "*" || c.Name == name {
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
+
+// filter applies the step's predicates, in order, to the given nodes.
+func (s xpathStep) filter(nodes []*XMLNode) []*XMLNode {
+	for _, p := range s.predicates {
+		if p == "last()" {
+			if len(nodes) == 0 {
+				return nil
+			}
+			nodes = nodes[len(nodes)-1:]
+			continue
+		}
+
+		if i, err := strconv.Atoi(p); err == nil {
+			if i < 1 || i > len(nodes) {
+				return nil
+			}
+			nodes = nodes[i-1 : i]
+			continue
+		}
+
+		var out []*XMLNode
+		for _, n := range nodes {
+			if predicateMatches(n, p) {
+				out = append(out, n)
+			}
+		}
+		nodes = out
+	}
+
+	return nodes
+}
+
+// predicateMatches returns true if the given node satisfies an existence or equality predicate, such as "@id",
+// "@id='1'", "name", or "name='x'".
+func predicateMatches(n *XMLNode, predicate string) bool {
+	key, want, compare := predicate, "", false
+	if i := strings.Index(predicate, "="); i >= 0 {
+		key = strings.TrimSpace(predicate[:i])
+		want = strings.Trim(strings.TrimSpace(predicate[i+1:]), `'"`)
+		compare = true
+	}
+
+	var values []string
+	if strings.HasPrefix(key, "@") {
+		if v, isset := n.Attrs[localName(key[1:])]; isset {
+			values = append(values, v)
+		}
+	} else if key == "text()" || key == "." {
+		values = append(values, n.Value())
+	} else {
+		for _, c := range n.Children {
+			if c.Name == localName(key) {
+				values = append(values, c.Value())
+			}
+		}
+	}
+
+	if !compare {
+		return len(values) > 0
+	}
+
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+
+	return false
+}
+
+// descendants returns the given node and all of its descendant elements, in document order.
+func descendants(n *XMLNode) []*XMLNode {
+	out := []*XMLNode{n}
+	for _, c := range n.Children {
+		out = append(out, descendants(c)...)
+	}
+
+	return out
+}
+
+// localName strips any namespace prefix from the given name.
+func localName(name string) string {
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+
+	return name
+}
+
+// xmlType returns the type of the value of the given node: "element" for elements with child elements, "empty",
+// "int", "float", or "bool" according to its value, and "string" otherwise.
+func xmlType(n *XMLNode) string {
+	if len(n.Children) > 0 {
+		return "element"
+	}
+
+	v := n.Value()
+	switch {
+	case v == "":
+		return "empty"
+	case isInt(v):
+		return "int"
+	case isFloat(v):
+		return "float"
+	case v == "true" || v == "false":
+		return "bool"
+	}
+
+	return "string"
+}
+
+func isInt(v string) bool {
+	_, err := strconv.ParseInt(v, 10, 64)
+	return err == nil
+}
+
+func isFloat(v string) bool {
+	_, err := strconv.ParseFloat(v, 64)
+	return err == nil
+}
+
+// XMLResponse provides everything that ClientResponse does, along with the parsed XML Document of the body,
+// which can be queried with XPath expressions.
+type XMLResponse struct {
+	ClientResponse
+	Document *XMLNode
+}
+
+// ExecXML takes a path specifier and executes the corresponding functionality if found in the loaded swagger doc,
+// parsing the body as XML. If the body is not XML, the Error of the response says why.
+func (sc *Client) ExecXML(specifier string, params map[string]interface{}) XMLResponse {
+	return toXMLResponse(sc.Exec(specifier, params))
+}
+
+// toXMLResponse wraps a ClientResponse in an XMLResponse, parsing the body into the Document.
+func toXMLResponse(resp ClientResponse) XMLResponse {
+	out := XMLResponse{ClientResponse: resp, Document: &XMLNode{}}
+	if resp.Error != nil {
+		return out
+	}
+
+	doc, err := parseXML([]byte(resp.Body), resp.charset != "")
+	if err != nil {
+		out.Error = fmt.Errorf("ExecXML: unable to parse body from URL %s: %s", resp.RequestURL, err.Error())
+		return out
+	}
+
+	out.Document = doc
+	return out
+}
+
+// find returns the nodes matching the given XPath expression in the Document, failing the test if the
+// expression is invalid.
+func (c XMLResponse) find(t *testing.T, xpath string) ([]*XMLNode, bool) {
+	nodes, err := c.Document.Find(xpath)
+	if err != nil {
+		assert.Fail(c.reporter(t), err.Error())
+		return nil, false
+	}
+
+	return nodes, true
+}
+
+// ExpectError is used to assert that a certain error condition has occured.
+func (c XMLResponse) ExpectError(t *testing.T, err error) XMLResponse {
+	// To avoid a panic inside assert, we will handle nil values explicitly
+	if err == nil {
+		if c.Error == nil {
+			return c
+		}
+
+		assert.True(c.reporter(t), false, fmt.Sprintf("expected no error, got error `%v` instead", c.Error))
+		return c
+	}
+
+	if c.Error == nil {
+		assert.True(c.reporter(t), false, fmt.Sprintf("expected error `%v`, had nil instead", err))
+		return c
+	}
+
+	assert.Equal(c.reporter(t), err, c.Error, fmt.Sprintf("expected error with message `%v`, got error with message `%v`", err, c.Error))
+
+	return c
+}
+
+// ExpectErrorIs is used to assert that the error condition that occured wraps the given error, such as ErrTimeout or ErrCanceled.
+func (c XMLResponse) ExpectErrorIs(t *testing.T, err error) XMLResponse {
+	if c.Error == nil {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected error `%v`, had nil instead", err))
+		return c
+	}
+
+	assert.True(c.reporter(t), errors.Is(c.Error, err), fmt.Sprintf("expected error wrapping `%v`, got error with message `%v`", err, c.Error))
+
+	return c
+}
+
+// Expect allows custom assertions to be run.
+// A error returned from the eval function will cause the test to be failed.
+func (c XMLResponse) Expect(t *testing.T, eval func(c XMLResponse) error) XMLResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	err := eval(c)
+
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	assert.Nil(c.reporter(t), err, msg)
+
+	return c
+}
+
+// ExpectStatus asserts that a specific status code was received.
+func (c XMLResponse) ExpectStatus(t *testing.T, status int) XMLResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.Equal(c.reporter(t), status, c.StatusCode, fmt.Sprintf("expected statuscode '%d', got '%d' instead", status, c.StatusCode))
+
+	return c
+}
+
+// ExpectStatusNot asserts that the status code received is not the given status code.
+func (c XMLResponse) ExpectStatusNot(t *testing.T, status int) XMLResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.NotEqual(c.reporter(t), status, c.StatusCode, fmt.Sprintf("expected statuscode other than '%d'", status))
+
+	return c
+}
+
+// ExpectStatusIn asserts that the status code received is one of the given status codes.
+func (c XMLResponse) ExpectStatusIn(t *testing.T, statuses ...int) XMLResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	for _, status := range statuses {
+		if c.StatusCode == status {
+			return c
+		}
+	}
+
+	assert.Fail(c.reporter(t), fmt.Sprintf("expected statuscode in %v, got '%d' instead", statuses, c.StatusCode))
+
+	return c
+}
+
+// ExpectStatusRange asserts that the status code received is between min and max, inclusive.
+func (c XMLResponse) ExpectStatusRange(t *testing.T, min, max int) XMLResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.True(c.reporter(t), c.StatusCode >= min && c.StatusCode <= max, fmt.Sprintf("expected statuscode between '%d' and '%d', got '%d' instead", min, max, c.StatusCode))
+
+	return c
+}
+
+// ExpectRequestTimeUnder asserts that the request took less than the given duration.
+func (c XMLResponse) ExpectRequestTimeUnder(t *testing.T, limit time.Duration) XMLResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	assert.True(c.reporter(t), c.RequestDuration < limit, fmt.Sprintf("expected request to take less than %s, took %s instead", limit, c.RequestDuration))
+
+	return c
+}
+
+// ExpectHeaderValue asserts that the header value at the given key will match the given value.
+func (c XMLResponse) ExpectHeaderValue(t *testing.T, key string, value string) XMLResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	actual, isset := c.header(key)
+	if !isset {
+		assert.True(c.reporter(t), isset, fmt.Sprintf("no header with key '%s' set", key))
+		return c
+	}
+
+	assert.Equal(c.reporter(t), value, actual, fmt.Sprintf("expected header '%s' to have value '%s', got '%s' instead", key, value, actual))
+
+	return c
+}
+
+// ExpectHeaderValueNot asserts that the header value at the given key does not match the given value. A missing header passes.
+func (c XMLResponse) ExpectHeaderValueNot(t *testing.T, key string, value string) XMLResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	actual, isset := c.header(key)
+	if !isset {
+		return c
+	}
+
+	assert.NotEqual(c.reporter(t), value, actual, fmt.Sprintf("expected header '%s' to have a value other than '%s'", key, value))
+
+	return c
+}
+
+// ExpectHeaderValues asserts that the header at the given key was received with exactly the given values, in order.
+func (c XMLResponse) ExpectHeaderValues(t *testing.T, key string, values []string) XMLResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	actual, isset := c.headerValues(key)
+	if !isset {
+		assert.True(c.reporter(t), isset, fmt.Sprintf("no header with key '%s' set", key))
+		return c
+	}
+
+	assert.Equal(c.reporter(t), values, actual, fmt.Sprintf("expected header '%s' to have values '%s', got '%s' instead", key, strings.Join(values, "', '"), strings.Join(actual, "', '")))
+
+	return c
+}
+
+// ExpectType asserts the type of the value of the first node matching the given XPath expression. Types are
+// "element", "empty", "int", "float", "bool", and "string". As with JSONResponse, "number" accepts int or float.
+func (c XMLResponse) ExpectType(t *testing.T, xpath, typ string) XMLResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	nodes, ok := c.find(t, xpath)
+	if !ok {
+		return c
+	}
+
+	actual := "absent"
+	if len(nodes) > 0 {
+		actual = xmlType(nodes[0])
+	}
+
+	// Allow for int or float when it's not important.
+	if typ == "number" && (actual == "int" || actual == "float") {
+		return c
+	}
+
+	assert.Equal(c.reporter(t), typ, actual, fmt.Sprintf("expected value at xpath `%s` to be `%s`, got `%s` instead", xpath, typ, actual))
+
+	return c
+}
+
+// ExpectValue asserts the value of the first node matching the given XPath expression will match the given value.
+// All comparisons are done as string comparisons.
+func (c XMLResponse) ExpectValue(t *testing.T, xpath string, b interface{}) XMLResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	nodes, ok := c.find(t, xpath)
+	if !ok {
+		return c
+	}
+
+	if len(nodes) == 0 {
+		assert.Fail(c.reporter(t), fmt.Sprintf("expected '%v' at xpath `%s`, found no match", b, xpath))
+		return c
+	}
+
+	expected := cast.ToString(b)
+	a := nodes[0].Value()
+	assert.Equal(c.reporter(t), expected, a, fmt.Sprintf("expected '%s' to equal '%s'", expected, a))
+
+	return c
+}
+
+// ExpectValueNot asserts the value of the first node matching the given XPath expression will not match the given value.
+// All comparisons are done as string comparisons, and an expression with no match passes.
+func (c XMLResponse) ExpectValueNot(t *testing.T, xpath string, b interface{}) XMLResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	nodes, ok := c.find(t, xpath)
+	if !ok || len(nodes) == 0 {
+		return c
+	}
+
+	unexpected := cast.ToString(b)
+	assert.NotEqual(c.reporter(t), unexpected, nodes[0].Value(), fmt.Sprintf("expected value at xpath `%s` not to equal '%s'", xpath, unexpected))
+
+	return c
+}
+
+// OptionalValue differs from ExpectValue in that it can only fail if the given XPath expression matches. If nothing matches, the test will pass.
+func (c XMLResponse) OptionalValue(t *testing.T, xpath string, b interface{}) XMLResponse {
+	if nodes, _ := c.Document.Find(xpath); len(nodes) == 0 {
+		return c
+	}
+
+	return c.ExpectValue(t, xpath, b)
+}
+
+// ExpectValueMatch asserts the value of the first node matching the given XPath expression will pass the given regex test.
+func (c XMLResponse) ExpectValueMatch(t *testing.T, xpath string, re *regexp.Regexp) XMLResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	nodes, ok := c.find(t, xpath)
+	if !ok {
+		return c
+	}
+
+	val := ""
+	if len(nodes) > 0 {
+		val = nodes[0].Value()
+	}
+	assert.True(c.reporter(t), re.Match([]byte(val)), fmt.Sprintf("expect value match error: '%s' did not pass the regex test `%s`", val, re.String()))
+
+	return c
+}
+
+// OptionalValueMatch differs from ExpectValueMatch in that it can only fail if the given XPath expression matches. If nothing matches, the test will pass.
+func (c XMLResponse) OptionalValueMatch(t *testing.T, xpath string, re *regexp.Regexp) XMLResponse {
+	if nodes, _ := c.Document.Find(xpath); len(nodes) == 0 {
+		return c
+	}
+
+	return c.ExpectValueMatch(t, xpath, re)
+}
+
+// ExpectValueCount asserts the given XPath expression will match the given number of nodes.
+func (c XMLResponse) ExpectValueCount(t *testing.T, xpath string, count int) XMLResponse {
+	if c.Error != nil {
+		return c
+	}
+
+	nodes, ok := c.find(t, xpath)
+	if !ok {
+		return c
+	}
+
+	assert.Equal(c.reporter(t), count, len(nodes), fmt.Sprintf("expected %d matches at xpath `%s`, got %d instead", count, xpath, len(nodes)))
+
+	return c
+}